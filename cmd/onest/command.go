@@ -0,0 +1,195 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/user"
+	"runtime"
+	"strings"
+
+	"github.com/acgn-org/onest/internal/app"
+	"github.com/acgn-org/onest/internal/config"
+	"github.com/acgn-org/onest/internal/database"
+	"github.com/acgn-org/onest/internal/source"
+	"github.com/acgn-org/onest/repository"
+	"github.com/acgn-org/onest/telegram"
+	"golang.org/x/term"
+)
+
+// command runs without starting http server
+type command struct {
+	// words of the command, e.g. "items export"
+	name    string
+	usage   string
+	summary string
+	run     func(args []string) error
+}
+
+var commands = []command{
+	{name: "version", summary: "print version", run: printVersion},
+	{name: "check-config", summary: "validate config file and env", run: checkConfig},
+	{name: "migrate status", summary: "show schema version and migrations of database", run: migrateStatus},
+	{name: "migrate", summary: "create database tables or apply pending migrations", run: migrate},
+	{name: "login", summary: "authorize Telegram accounts interactively", run: login},
+	{
+		name:    "items export",
+		usage:   "[-ids 1,2] [-downloads] [-format json|yaml] [-o file]",
+		summary: "export item definitions",
+		run:     exportItems,
+	},
+	{
+		name:    "items import",
+		usage:   "[-conflict skip|overwrite|rename] [-dry-run] file",
+		summary: "import item definitions, - reads stdin",
+		run:     importItems,
+	},
+	{
+		name:    "downloads list",
+		usage:   "[-state state] [-item id] [-search text] [-limit n] [-json]",
+		summary: "list downloads",
+		run:     listDownloads,
+	},
+	{name: "downloads reset", usage: "id...", summary: "reset state of downloads", run: resetDownloads},
+}
+
+func usage() {
+	fmt.Fprintln(os.Stderr, "usage: onest [command]")
+	fmt.Fprintln(os.Stderr, "\nstarts the server if no command is given, commands:")
+	for _, cmd := range commands {
+		fmt.Fprintf(os.Stderr, "  %-18s %s\n", cmd.name, cmd.summary)
+		if cmd.usage != "" {
+			fmt.Fprintf(os.Stderr, "  %-18s   %s\n", "", cmd.usage)
+		}
+	}
+}
+
+// runCommand finds the command matching leading words of args and exits with its result
+func runCommand(args []string) {
+	if args[0] == "help" || args[0] == "-h" || args[0] == "--help" {
+		usage()
+		return
+	}
+
+	for _, cmd := range commands {
+		words := strings.Fields(cmd.name)
+		if len(args) < len(words) || strings.Join(args[:len(words)], " ") != cmd.name {
+			continue
+		}
+		if err := cmd.run(args[len(words):]); err != nil {
+			fmt.Fprintf(os.Stderr, "onest %s: %v\n", cmd.name, err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	fmt.Fprintf(os.Stderr, "unknown command '%s'\n\n", strings.Join(args, " "))
+	usage()
+	os.Exit(2)
+}
+
+type validatable interface {
+	Validate() config.ValidationError
+}
+
+// validateComponent validates only scopes used by the command
+func validateComponent(scopes ...validatable) app.Component {
+	return app.Component{Name: "config", Start: func(context.Context) error {
+		var result config.ValidationError
+		for _, scope := range scopes {
+			result = append(result, scope.Validate()...)
+		}
+		if len(result) != 0 {
+			return result
+		}
+		return nil
+	}}
+}
+
+var databaseComponent = app.Component{Name: "database", Start: database.Connect, Stop: database.Close}
+
+// databaseOpenComponent opens the database without migrating
+var databaseOpenComponent = app.Component{Name: "database", Start: database.Open, Stop: database.Close}
+
+var telegramComponent = app.Component{Name: "telegram", Start: source.ConnectTelegram, Stop: source.CloseTelegram}
+
+// withComponents starts components, runs fn and stops them
+func withComponents(fn func() error, components ...app.Component) error {
+	a := app.New(components...)
+	if err := a.Start(context.Background()); err != nil {
+		return err
+	}
+
+	err := fn()
+
+	ctx, cancel := context.WithTimeout(context.Background(), app.StopTimeout)
+	defer cancel()
+	if stopErr := a.Stop(ctx); stopErr != nil && err == nil {
+		err = stopErr
+	}
+	return err
+}
+
+// auditCli records a change made by command line in the transaction of repo
+func auditCli(repo repository.Repository, action, targetType string, targetIDs []uint, before, after any) error {
+	var actor = os.Getenv("USER")
+	if current, err := user.Current(); err == nil {
+		actor = current.Username
+	}
+
+	auditRepo := repository.AuditRepository{Repository: repo}
+	return auditRepo.Create(&repository.AuditLog{
+		ActorType:  repository.AuditActorCli,
+		Actor:      actor,
+		Action:     action,
+		TargetType: targetType,
+		TargetIDs:  targetIDs,
+		Before:     before,
+		After:      after,
+	})
+}
+
+func printVersion([]string) error {
+	fmt.Printf("onest %s %s %s/%s\n", config.VERSION, runtime.Version(), runtime.GOOS, runtime.GOARCH)
+	return nil
+}
+
+// checkConfig reports every invalid key of config file and env
+func checkConfig([]string) error {
+	if err := config.Validate(); err != nil {
+		return fmt.Errorf("%s is invalid:\n%v", config.Pathname(), err)
+	}
+	fmt.Printf("%s is valid\n", config.Pathname())
+	return nil
+}
+
+// login authorizes with TDLib only, accounts are asked one by one, sessions are kept in their data folders
+func login([]string) error {
+	if !term.IsTerminal(int(os.Stdin.Fd())) {
+		return errors.New("a terminal is required, e.g. docker run -it, or log in on the web UI instead")
+	}
+	return withComponents(func() error {
+		telegramConfig := config.Telegram.Get()
+		dataFolders := map[string]string{telegram.DefaultAccount: telegramConfig.DataFolder}
+		for _, account := range telegramConfig.Accounts {
+			dataFolders[account.Name] = account.DataFolder
+		}
+
+		for _, account := range source.Accounts {
+			if err := account.Wait(context.Background()); err != nil {
+				return fmt.Errorf("account %s: %w", account.Name, err)
+			}
+			me, err := account.Telegram.GetMe()
+			if err != nil {
+				return err
+			}
+			name := strings.TrimSpace(me.FirstName + " " + me.LastName)
+			if me.Usernames != nil && len(me.Usernames.ActiveUsernames) != 0 {
+				name += " (@" + me.Usernames.ActiveUsernames[0] + ")"
+			}
+			fmt.Printf("account %s authorized as %s, session saved to %s\n", account.Name, name, dataFolders[account.Name])
+		}
+		return nil
+	}, validateComponent(config.Telegram), telegramComponent)
+}
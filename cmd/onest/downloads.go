@@ -0,0 +1,152 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+	"unicode/utf8"
+
+	"github.com/acgn-org/onest/internal/config"
+	"github.com/acgn-org/onest/internal/database"
+	"github.com/acgn-org/onest/repository"
+	"github.com/gin-gonic/gin/binding"
+)
+
+// downloadState is the same as states of DownloadFilter
+func downloadState(task *repository.DownloadTask) string {
+	switch {
+	case task.Downloaded && task.FatalError:
+		return repository.DownloadStateFatal
+	case task.Downloaded:
+		return repository.DownloadStateDone
+	case task.Downloading:
+		return repository.DownloadStateDownloading
+	case task.Paused:
+		return repository.DownloadStatePaused
+	case task.ErrorAt > 0:
+		return repository.DownloadStateError
+	}
+	return repository.DownloadStateWaiting
+}
+
+func formatSize(size int64) string {
+	const unit = 1024
+	if size < unit {
+		return fmt.Sprintf("%d B", size)
+	}
+	value, exp := float64(size)/unit, 0
+	for ; value >= unit && exp < 3; exp++ {
+		value /= unit
+	}
+	return fmt.Sprintf("%.1f %ciB", value, "KMGT"[exp])
+}
+
+// truncate keeps the first line of s within n runes
+func truncate(s string, n int) string {
+	if i := strings.IndexAny(s, "\r\n"); i >= 0 {
+		s = s[:i]
+	}
+	if utf8.RuneCountInString(s) <= n {
+		return s
+	}
+	return string([]rune(s)[:n-1]) + "…"
+}
+
+func listDownloads(args []string) error {
+	fs := flag.NewFlagSet("downloads list", flag.ExitOnError)
+	var filter repository.DownloadFilter
+	fs.StringVar(&filter.State, "state", "", "queued, waiting, paused, downloading, done, error or fatal")
+	fs.UintVar(&filter.ItemID, "item", 0, "id of item")
+	fs.StringVar(&filter.Search, "search", "", "text of message contains")
+	fs.StringVar(&filter.Sort, "sort", "queue", "queue, id, date, priority or size")
+	fs.StringVar(&filter.Cursor, "cursor", "", "next cursor of last page")
+	fs.IntVar(&filter.Limit, "limit", 50, "max number of downloads")
+	asJson := fs.Bool("json", false, "print the page as json")
+	_ = fs.Parse(args)
+
+	if err := binding.Validator.ValidateStruct(&filter); err != nil {
+		return err
+	}
+
+	return withComponents(func() error {
+		downloadRepo := database.NewRepository[repository.DownloadRepository]()
+		page, err := downloadRepo.GetPage(&filter)
+		if err != nil {
+			return err
+		}
+
+		if *asJson {
+			encoder := json.NewEncoder(os.Stdout)
+			encoder.SetIndent("", "  ")
+			return encoder.Encode(page)
+		}
+
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "ID\tITEM\tSTATE\tPRIORITY\tSIZE\tDATE\tTEXT")
+		for _, task := range page.Items {
+			fmt.Fprintf(w, "%d\t%d\t%s\t%d\t%s\t%s\t%s\n",
+				task.ID, task.ItemID, downloadState(&task), task.Priority, formatSize(task.Size),
+				time.Unix(int64(task.Date), 0).Format(time.DateOnly), truncate(task.Text, 60),
+			)
+		}
+		if err := w.Flush(); err != nil {
+			return err
+		}
+		fmt.Fprintf(os.Stderr, "%d of %d downloads", len(page.Items), page.Total)
+		if page.NextCursor != "" {
+			fmt.Fprintf(os.Stderr, ", next page: -cursor %s", page.NextCursor)
+		}
+		fmt.Fprintln(os.Stderr)
+		return nil
+	}, validateComponent(config.Database), databaseComponent)
+}
+
+// resetDownloads clears downloaded and error state, a running server picks them up in its next round of task control
+func resetDownloads(args []string) error {
+	if len(args) == 0 {
+		return errors.New("ids of downloads are required")
+	}
+	ids, err := parseIDs(args)
+	if err != nil {
+		return err
+	}
+
+	return withComponents(func() error {
+		downloadRepo := database.BeginRepository[repository.DownloadRepository]()
+		defer downloadRepo.Rollback()
+
+		before, err := downloadRepo.GetByID(ids...)
+		if err != nil {
+			return err
+		} else if len(before) != len(ids) {
+			return errors.New("some of the downloads not found")
+		}
+
+		if err := downloadRepo.UpdateResetDownloadStateByIDs(ids...); err != nil {
+			return err
+		}
+		companionRepo := repository.CompanionRepository{Repository: downloadRepo.Repository}
+		if err := companionRepo.ResetByDownloadID(ids...); err != nil {
+			return err
+		}
+
+		after, err := downloadRepo.GetByID(ids...)
+		if err != nil {
+			return err
+		}
+		if err := auditCli(downloadRepo.Repository, "download.bulk.reset", repository.AuditTargetDownload, ids, before, after); err != nil {
+			return err
+		}
+
+		if err := downloadRepo.Commit().Error; err != nil {
+			return err
+		}
+		fmt.Printf("%d downloads reset\n", len(ids))
+		return nil
+	}, validateComponent(config.Database), databaseComponent)
+}
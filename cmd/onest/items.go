@@ -0,0 +1,178 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/acgn-org/onest/internal/config"
+	"github.com/acgn-org/onest/internal/database"
+	"github.com/acgn-org/onest/internal/queue"
+	"github.com/acgn-org/onest/repository"
+	"github.com/gin-gonic/gin/binding"
+	"gopkg.in/yaml.v3"
+)
+
+func parseIDs(values []string) ([]uint, error) {
+	var ids = make([]uint, 0, len(values))
+	for _, value := range values {
+		id, err := strconv.ParseUint(value, 10, 0)
+		if err != nil || id == 0 {
+			return nil, fmt.Errorf("invalid id '%s'", value)
+		}
+		ids = append(ids, uint(id))
+	}
+	return ids, nil
+}
+
+func exportItems(args []string) error {
+	fs := flag.NewFlagSet("items export", flag.ExitOnError)
+	ids := fs.String("ids", "", "comma separated ids of items, all items if empty")
+	downloads := fs.Bool("downloads", false, "include downloads")
+	format := fs.String("format", "", "json or yaml, detected from extension of output by default")
+	output := fs.String("o", "-", "output file, - writes stdout")
+	_ = fs.Parse(args)
+
+	var itemIDs []uint
+	if *ids != "" {
+		var err error
+		if itemIDs, err = parseIDs(strings.Split(*ids, ",")); err != nil {
+			return err
+		}
+	}
+	if *format == "" {
+		switch filepath.Ext(*output) {
+		case ".yaml", ".yml":
+			*format = "yaml"
+		default:
+			*format = "json"
+		}
+	} else if *format != "json" && *format != "yaml" {
+		return fmt.Errorf("unsupported format '%s'", *format)
+	}
+
+	return withComponents(func() error {
+		itemRepo := database.NewRepository[repository.ItemRepository]()
+		export, err := queue.ExportItems(itemRepo, itemIDs, *downloads)
+		if err != nil {
+			return err
+		}
+
+		var data []byte
+		if *format == "yaml" {
+			data, err = yaml.Marshal(export)
+		} else {
+			data, err = json.MarshalIndent(export, "", "  ")
+			data = append(data, '\n')
+		}
+		if err != nil {
+			return err
+		}
+
+		if *output == "-" {
+			_, err = os.Stdout.Write(data)
+			return err
+		}
+		if err := os.WriteFile(*output, data, config.FilePerm); err != nil {
+			return err
+		}
+		fmt.Fprintf(os.Stderr, "%d items exported to %s\n", len(export.Items), *output)
+		return nil
+	}, validateComponent(config.Database), databaseComponent)
+}
+
+func importItems(args []string) error {
+	fs := flag.NewFlagSet("items import", flag.ExitOnError)
+	conflict := fs.String("conflict", repository.ImportConflictSkip, "action on item of the same channel and name, skip, overwrite or rename")
+	dryRun := fs.Bool("dry-run", false, "report changes without importing")
+	_ = fs.Parse(args)
+
+	switch *conflict {
+	case repository.ImportConflictSkip, repository.ImportConflictOverwrite, repository.ImportConflictRename:
+	default:
+		return fmt.Errorf("unsupported conflict action '%s'", *conflict)
+	}
+	if fs.NArg() != 1 {
+		return errors.New("exactly one file is required")
+	}
+
+	var data []byte
+	var err error
+	if fs.Arg(0) == "-" {
+		data, err = io.ReadAll(os.Stdin)
+	} else {
+		data, err = os.ReadFile(fs.Arg(0))
+	}
+	if err != nil {
+		return err
+	}
+
+	// json is parsed as yaml as well
+	var export repository.ItemExport
+	if err := yaml.Unmarshal(data, &export); err != nil {
+		return err
+	}
+	if err := binding.Validator.ValidateStruct(&export); err != nil {
+		return err
+	}
+
+	return withComponents(func() error {
+		report, ok, err := queue.NewItemImportReport(&export, *dryRun)
+		if err != nil {
+			return err
+		} else if !ok {
+			printImportReport(report)
+			return errors.New("some of the items are invalid, nothing imported")
+		}
+
+		if *dryRun {
+			if err := queue.ImportItems(database.NewRepository[repository.ItemRepository](), &export, *conflict, report); err != nil {
+				return err
+			}
+			printImportReport(report)
+			return nil
+		}
+
+		itemRepo := database.BeginRepository[repository.ItemRepository]()
+		defer itemRepo.Rollback()
+
+		if err := queue.ImportItems(itemRepo, &export, *conflict, report); err != nil {
+			return err
+		}
+		if err := auditCli(itemRepo.Repository, "item.import", repository.AuditTargetItem, report.ItemIDs(), nil, report.Items); err != nil {
+			return err
+		}
+		if err := itemRepo.Commit().Error; err != nil {
+			return err
+		}
+		report.Imported = true
+
+		printImportReport(report)
+		return nil
+	}, validateComponent(config.Database), databaseComponent)
+}
+
+func printImportReport(report *queue.ItemImportReport) {
+	for _, result := range report.Items {
+		line := fmt.Sprintf("%d\t%s\t%s", result.Index, result.Action, result.Name)
+		if result.Error != "" {
+			line += "\t" + result.Error
+		} else if result.ItemID != 0 {
+			line += fmt.Sprintf("\titem %d, %d downloads created", result.ItemID, result.Downloads)
+		} else if result.Action != repository.ImportConflictSkip {
+			line += fmt.Sprintf("\t%d downloads created", result.Downloads)
+		}
+		fmt.Println(line)
+	}
+	if report.DryRun {
+		fmt.Println("dry run, nothing imported")
+	} else if report.Imported {
+		fmt.Printf("%d items imported\n", len(report.ItemIDs()))
+	}
+}
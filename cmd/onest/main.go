@@ -1,12 +1,19 @@
 package main
 
 import (
+	"os"
+
 	"github.com/acgn-org/onest/internal/server"
 	"github.com/acgn-org/onest/web"
 	log "github.com/sirupsen/logrus"
 )
 
 func main() {
+	if len(os.Args) > 1 {
+		runCommand(os.Args[1:])
+		return
+	}
+
 	engine := server.NewEngine()
 
 	fs, err := web.Fs()
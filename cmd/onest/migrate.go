@@ -0,0 +1,70 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"github.com/acgn-org/onest/internal/config"
+	"github.com/acgn-org/onest/internal/database"
+)
+
+func migrate([]string) error {
+	return withComponents(func() error {
+		status, err := database.Status()
+		if err != nil {
+			return err
+		}
+		if len(status.Pending) == 0 && status.Version <= status.Latest {
+			fmt.Printf("database is up to date at version %d\n", status.Version)
+			return nil
+		}
+		if err := database.Migrate(context.Background()); err != nil {
+			return err
+		}
+		if status.Version == 0 {
+			fmt.Printf("database set up at version %d\n", status.Latest)
+			return nil
+		}
+		for _, migration := range status.Pending {
+			fmt.Printf("applied %d %s\n", migration.Version, migration.Name)
+		}
+		fmt.Printf("database migrated to version %d\n", status.Latest)
+		return nil
+	}, validateComponent(config.Database), databaseOpenComponent)
+}
+
+// migrateStatus works with databases newer than the binary as well, which are refused on start
+func migrateStatus([]string) error {
+	return withComponents(func() error {
+		status, err := database.Status()
+		if err != nil {
+			return err
+		}
+
+		switch {
+		case status.Version == 0:
+			fmt.Printf("database is not versioned, migrate sets up tables at version %d\n", status.Latest)
+		case status.Version > status.Latest:
+			fmt.Printf("database is at version %d, newer than %d of this binary, please upgrade onest\n", status.Version, status.Latest)
+		default:
+			fmt.Printf("database is at version %d, this binary is at %d\n", status.Version, status.Latest)
+		}
+		if status.Version == 0 {
+			return nil
+		}
+
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "VERSION\tNAME\tAPPLIED")
+		for _, migration := range status.Applied {
+			fmt.Fprintf(w, "%d\t%s\t%s\n", migration.Version, migration.Name,
+				time.Unix(migration.AppliedAt, 0).Format(time.DateTime))
+		}
+		for _, migration := range status.Pending {
+			fmt.Fprintf(w, "%d\t%s\t%s\n", migration.Version, migration.Name, "pending")
+		}
+		return w.Flush()
+	}, validateComponent(config.Database), databaseOpenComponent)
+}
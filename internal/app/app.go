@@ -0,0 +1,94 @@
+// Package app starts components of the application in order, and stops them in reverse order
+package app
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/acgn-org/onest/internal/logfield"
+)
+
+// StopTimeout limits the time of stopping all components
+const StopTimeout = time.Minute
+
+type Component struct {
+	Name  string
+	Start func(ctx context.Context) error
+	// optional
+	Stop func(ctx context.Context) error
+}
+
+type App struct {
+	logger     logfield.LoggerWithFields
+	components []Component
+	// number of components started
+	started int
+}
+
+func New(components ...Component) *App {
+	return &App{
+		logger:     logfield.New(logfield.ComApp),
+		components: components,
+	}
+}
+
+// Start starts components in order, components already started are stopped on failure
+func (a *App) Start(ctx context.Context) error {
+	for _, component := range a.components {
+		a.logger.WithAction("start").Debugf("starting %s", component.Name)
+		if err := component.Start(ctx); err != nil {
+			err = fmt.Errorf("start %s failed: %w", component.Name, err)
+
+			stopCtx, cancel := context.WithTimeout(context.Background(), StopTimeout)
+			defer cancel()
+			return errors.Join(err, a.Stop(stopCtx))
+		}
+		a.started++
+	}
+	return nil
+}
+
+// Stop stops started components in reverse order, all of them are stopped even if some failed
+func (a *App) Stop(ctx context.Context) error {
+	logger := a.logger.WithAction("stop")
+	var errs []error
+	for ; a.started > 0; a.started-- {
+		component := a.components[a.started-1]
+		if component.Stop == nil {
+			continue
+		}
+		logger.Debugf("stopping %s", component.Name)
+		if err := component.Stop(ctx); err != nil {
+			logger.Errorf("stop %s failed: %v", component.Name, err)
+			errs = append(errs, fmt.Errorf("stop %s failed: %w", component.Name, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Run starts components and stops them on SIGINT or SIGTERM
+func (a *App) Run() {
+	quit := make(chan os.Signal, 1)
+	signal.Notify(quit, os.Interrupt, syscall.SIGTERM)
+	defer signal.Stop(quit)
+
+	if err := a.Start(context.Background()); err != nil {
+		a.logger.WithAction("start").Fatalln(err)
+	}
+
+	sig := <-quit
+	a.logger.WithAction("stop").Infof("%s received, shutting down...", sig)
+
+	ctx, cancel := context.WithTimeout(context.Background(), StopTimeout)
+	defer cancel()
+	if err := a.Stop(ctx); err != nil {
+		a.logger.WithAction("stop").Errorln("shutdown incomplete:", err)
+		return
+	}
+	a.logger.WithAction("stop").Infoln("stopped")
+}
@@ -0,0 +1,198 @@
+package auth
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/acgn-org/onest/internal/config"
+	"github.com/acgn-org/onest/internal/database"
+	"github.com/acgn-org/onest/internal/logfield"
+	"github.com/acgn-org/onest/repository"
+	"github.com/gin-gonic/gin"
+	"golang.org/x/crypto/bcrypt"
+	"gorm.io/gorm"
+)
+
+const (
+	SessionCookie = "onest_session"
+	// prefix of api tokens, helps secret scanners
+	TokenPrefix = "onest_"
+)
+
+const (
+	PrincipalSession = "session"
+	PrincipalToken   = "token"
+)
+
+const (
+	// grants every scope, sessions of the admin account have it
+	ScopeAdmin          = "admin"
+	ScopeRead           = "read"
+	ScopeDownloadsWrite = "downloads:write"
+	ScopeItemsWrite     = "items:write"
+	ScopeTelegramRead   = "telegram:read"
+	ScopeLogsRead       = "logs:read"
+	ScopeMetricsRead    = "metrics:read"
+)
+
+// last used time of tokens is saved at most once in the interval
+const tokenLastUsedInterval = time.Minute
+
+const principalKey = "auth:principal"
+
+var ErrUnauthenticated = errors.New("unauthenticated")
+
+type Principal struct {
+	Type   string   `json:"type"`
+	ID     uint     `json:"id"`
+	Name   string   `json:"name"`
+	Scopes []string `json:"scopes"`
+}
+
+func (p Principal) HasScope(scope string) bool {
+	for _, s := range p.Scopes {
+		if s == scope || s == ScopeAdmin {
+			return true
+		}
+	}
+	return false
+}
+
+func HashPassword(password string) (string, error) {
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	return string(hash), err
+}
+
+func CheckPassword(username, password string) bool {
+	conf := config.Auth.Get()
+	usernameOk := subtle.ConstantTimeCompare([]byte(username), []byte(conf.Username)) == 1
+	passwordOk := conf.PasswordHash != "" && bcrypt.CompareHashAndPassword([]byte(conf.PasswordHash), []byte(password)) == nil
+	return usernameOk && passwordOk
+}
+
+func NewSecret() (string, error) {
+	var buf = make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(buf), nil
+}
+
+// HashSecret is used for session and token lookup, secrets have enough entropy to skip salting
+func HashSecret(secret string) string {
+	sum := sha256.Sum256([]byte(secret))
+	return hex.EncodeToString(sum[:])
+}
+
+// EnsurePassword generates a password for the admin account on first start with auth enabled
+func EnsurePassword() error {
+	conf := config.Auth.Get()
+	if !conf.Enabled || conf.PasswordHash != "" {
+		return nil
+	}
+
+	password, err := NewSecret()
+	if err != nil {
+		return err
+	}
+	password = password[:16]
+	conf.PasswordHash, err = HashPassword(password)
+	if err != nil {
+		return err
+	}
+	if err := config.Auth.Save(conf); err != nil {
+		return err
+	}
+	// logs are kept and served by logtee, the password is printed out of them
+	logfield.New(logfield.ComAuth).WithAction("init").Warnf("generated password of '%s' is printed on stderr, please change it after login", conf.Username)
+	fmt.Fprintf(os.Stderr, "generated password of '%s': %s\n", conf.Username, password)
+	return nil
+}
+
+func NewSession() (string, *repository.Session, error) {
+	secret, err := NewSecret()
+	if err != nil {
+		return "", nil, err
+	}
+	return secret, &repository.Session{
+		TokenHash: HashSecret(secret),
+		ExpiresAt: time.Now().Add(time.Duration(config.Auth.Get().SessionTTL) * time.Hour).Unix(),
+	}, nil
+}
+
+func NewToken(name string, scopes []string) (string, *repository.ApiToken, error) {
+	secret, err := NewSecret()
+	if err != nil {
+		return "", nil, err
+	}
+	secret = TokenPrefix + secret
+	return secret, &repository.ApiToken{
+		Name:      name,
+		TokenHash: HashSecret(secret),
+		Scopes:    scopes,
+	}, nil
+}
+
+func bearerToken(req *http.Request) string {
+	header := req.Header.Get("Authorization")
+	if len(header) > 7 && strings.EqualFold(header[:7], "bearer ") {
+		return strings.TrimSpace(header[7:])
+	}
+	return ""
+}
+
+// Authenticate resolves the principal from bearer token or session cookie
+func Authenticate(req *http.Request) (*Principal, error) {
+	authRepo := database.NewRepository[repository.AuthRepository]()
+
+	if token := bearerToken(req); token != "" {
+		model, err := authRepo.FirstToken(HashSecret(token))
+		if err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return nil, ErrUnauthenticated
+			}
+			return nil, err
+		}
+		if now := time.Now(); now.Sub(time.Unix(model.LastUsedAt, 0)) > tokenLastUsedInterval {
+			if err := authRepo.UpdateTokenLastUsed(model.ID, now.Unix()); err != nil {
+				logfield.New(logfield.ComAuth).WithAction("token").Warnln("save last used time failed:", err)
+			}
+		}
+		return &Principal{Type: PrincipalToken, ID: model.ID, Name: model.Name, Scopes: model.Scopes}, nil
+	}
+
+	if cookie, err := req.Cookie(SessionCookie); err == nil && cookie.Value != "" {
+		session, err := authRepo.FirstValidSession(HashSecret(cookie.Value))
+		if err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return nil, ErrUnauthenticated
+			}
+			return nil, err
+		}
+		return &Principal{Type: PrincipalSession, ID: session.ID, Name: config.Auth.Get().Username, Scopes: []string{ScopeAdmin}}, nil
+	}
+
+	return nil, ErrUnauthenticated
+}
+
+func SetPrincipal(ctx *gin.Context, principal *Principal) {
+	ctx.Set(principalKey, principal)
+}
+
+// GetPrincipal returns nil when auth is disabled
+func GetPrincipal(ctx *gin.Context) *Principal {
+	val, ok := ctx.Get(principalKey)
+	if !ok {
+		return nil
+	}
+	return val.(*Principal)
+}
@@ -0,0 +1,15 @@
+package config
+
+type _Auth struct {
+	Enabled  bool   `yaml:"enabled"`
+	Username string `yaml:"username" binding:"required"`
+	// bcrypt hash, generated on first start if empty
+	PasswordHash string `yaml:"password_hash" config:"secret"`
+	// hours
+	SessionTTL uint `yaml:"session_ttl" binding:"min=1"`
+}
+
+var Auth = LoadScoped("auth", &_Auth{
+	Username:   "admin",
+	SessionTTL: 24 * 7,
+})
@@ -3,6 +3,7 @@ package config
 import (
 	"fmt"
 	"os"
+	"reflect"
 	"strings"
 	"sync"
 	"sync/atomic"
@@ -30,18 +31,20 @@ func Pathname() string {
 	return pathname
 }
 
+// LoadConfigFile replaces loaded file content, keys removed from file are dropped as well
 func LoadConfigFile(logger logfield.LoggerWithFields) error {
-	kFileLock.Lock()
-	defer kFileLock.Unlock()
-
-	err := kFile.Load(file.Provider(Pathname()), yaml.Parser())
+	var k = koanf.New(".")
+	err := k.Load(file.Provider(Pathname()), yaml.Parser())
 	if err != nil {
-		if os.IsNotExist(err) {
-			logger.Warnln("config file not found, skipping...")
-			return nil
+		if !os.IsNotExist(err) {
+			return err
 		}
-		return err
+		logger.Warnln("config file not found, skipping...")
 	}
+
+	kFileLock.Lock()
+	defer kFileLock.Unlock()
+	kFile = k
 	return nil
 }
 
@@ -54,10 +57,21 @@ var loadConfigFileOnce = sync.OnceFunc(func() {
 })
 
 type ScopedConfig[T any] struct {
-	logger log.FieldLogger
-	scope  string
-	kEnv   *koanf.Koanf
-	value  *atomic.Value
+	logger   log.FieldLogger
+	scope    string
+	defaults *T
+	kEnv     *koanf.Koanf
+	value    *atomic.Value
+	// reported by Validate, defaults are used instead
+	loadErr error
+	// secrets referenced by file in yaml, never saved
+	fileRefs atomic.Pointer[map[string]bool]
+
+	// serializes Update
+	updateLock sync.Mutex
+
+	subscribersLock sync.RWMutex
+	subscribers     []func()
 }
 
 func (c *ScopedConfig[T]) Get() T {
@@ -66,7 +80,7 @@ func (c *ScopedConfig[T]) Get() T {
 
 func (c *ScopedConfig[T]) Save(value T) error {
 	kRaw := koanf.New(".")
-	err := kRaw.Load(structs.Provider(c.value, "yaml"), nil)
+	err := kRaw.Load(structs.Provider(c.Get(), "yaml"), nil)
 	if err != nil {
 		return err
 	}
@@ -83,15 +97,19 @@ func (c *ScopedConfig[T]) Save(value T) error {
 	var kFileChanged bool
 	for _, key := range kNew.Keys() {
 		val := kNew.Get(key)
-		if val != kRaw.Get(key) {
+		if !reflect.DeepEqual(val, kRaw.Get(key)) {
 			globalKey := c.scope + "." + key
 
 			if c.kEnv.Get(key) != nil {
 				c.logger.Warnf("%s was set by environment, change is not saved to file and will not take effect on next startup", globalKey)
 				continue
 			}
+			if c.FileRef(key) {
+				c.logger.Warnf("%s is read from file, change is not saved and will not take effect on next startup", globalKey)
+				continue
+			}
 
-			if kFile.Get(globalKey) != val {
+			if !reflect.DeepEqual(kFile.Get(globalKey), val) {
 				kFileChanged = true
 				err := kFile.Set(c.scope+"."+key, val)
 				if err != nil {
@@ -113,34 +131,53 @@ func (c *ScopedConfig[T]) Save(value T) error {
 	}
 
 	c.value.Store(value)
+	c.notify()
 	return nil
 }
 
-// LoadScoped scope is used in both loading from kFile and env
-func LoadScoped[T any](scope string, defaults *T) *ScopedConfig[T] {
-	logger := logfield.New(logfield.ComConfig).WithAction("load:" + scope)
+// OnChange registers fn called after config changed, fn reads the new value with Get
+func (c *ScopedConfig[T]) OnChange(fn func()) {
+	c.subscribersLock.Lock()
+	defer c.subscribersLock.Unlock()
+	c.subscribers = append(c.subscribers, fn)
+}
 
-	var conf T
+func (c *ScopedConfig[T]) notify() {
+	c.subscribersLock.RLock()
+	defer c.subscribersLock.RUnlock()
+	for _, fn := range c.subscribers {
+		fn()
+	}
+}
+
+// loadScoped merges defaults, file and env of scope, secrets referenced by file in yaml are returned as fileRefs
+func loadScoped[T any](scope string, defaults *T) (conf T, kEnv *koanf.Koanf, fileRefs map[string]bool, err error) {
 	var k = koanf.New(".")
+	var secrets = secretKeys(reflect.TypeOf(conf))
 
 	// defaults
 	if defaults != nil {
 		err := k.Load(structs.Provider(defaults, "yaml"), nil)
 		if err != nil {
-			logger.Fatalln("load defaults failed:", err)
+			return conf, nil, nil, fmt.Errorf("load defaults failed: %w", err)
 		}
 	}
 
 	// from file
 	loadConfigFileOnce()
 	kFileLock.RLock()
-	defer kFileLock.RUnlock()
-	if err := k.Merge(kFile.Cut(scope)); err != nil {
-		logger.Fatalln("merge from file failed:", err)
+	kScope := kFile.Cut(scope)
+	kFileLock.RUnlock()
+	fileRefs, err = resolveFileRefs(kScope, secrets)
+	if err != nil {
+		return conf, nil, nil, err
+	}
+	if err := k.Merge(kScope); err != nil {
+		return conf, nil, nil, fmt.Errorf("merge from file failed: %w", err)
 	}
 
 	// from env
-	var kEnv = koanf.New(".")
+	kEnv = koanf.New(".")
 	var prefix = fmt.Sprintf(
 		"%s_%s_",
 		EnvPrefix,
@@ -149,24 +186,47 @@ func LoadScoped[T any](scope string, defaults *T) *ScopedConfig[T] {
 	if err := kEnv.Load(env.Provider(prefix, ".", func(s string) string {
 		return strings.ToLower(strings.TrimPrefix(s, prefix))
 	}), nil); err != nil {
-		logger.Fatalln("load from env failed:", err)
+		return conf, nil, nil, fmt.Errorf("load from env failed: %w", err)
+	}
+	if err := resolveEnvFiles(kEnv, secrets); err != nil {
+		return conf, nil, nil, err
 	}
 	if err := k.Merge(kEnv); err != nil {
-		logger.Fatalln("merge from env failed:", err)
+		return conf, nil, nil, fmt.Errorf("merge from env failed: %w", err)
 	}
 
 	if err := k.UnmarshalWithConf("", &conf, koanf.UnmarshalConf{
 		Tag: "yaml",
 	}); err != nil {
-		logger.Fatalln("unmarshal failed:", err)
+		return conf, nil, nil, fmt.Errorf("unmarshal failed: %w", err)
+	}
+	return conf, kEnv, fileRefs, nil
+}
+
+// LoadScoped scope is used in both loading from kFile and env
+func LoadScoped[T any](scope string, defaults *T) *ScopedConfig[T] {
+	logger := logfield.New(logfield.ComConfig).WithAction("load:" + scope)
+
+	conf, kEnv, fileRefs, err := loadScoped(scope, defaults)
+	if err != nil {
+		logger.Errorln(err)
+		if defaults != nil {
+			conf = *defaults
+		}
+		kEnv = koanf.New(".")
 	}
 
 	var value atomic.Value
 	value.Store(conf)
-	return &ScopedConfig[T]{
-		logger: logger,
-		scope:  scope,
-		kEnv:   kEnv,
-		value:  &value,
+	c := &ScopedConfig[T]{
+		logger:   logger,
+		scope:    scope,
+		defaults: defaults,
+		kEnv:     kEnv,
+		value:    &value,
+		loadErr:  err,
 	}
+	c.fileRefs.Store(&fileRefs)
+	registerScope(c)
+	return c
 }
@@ -0,0 +1,23 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/acgn-org/onest/internal/logfield"
+)
+
+// loadTestScope loads scope from content written as the config file
+func loadTestScope[T any](t *testing.T, scope, content string, defaults *T) *ScopedConfig[T] {
+	t.Helper()
+	pathname := filepath.Join(t.TempDir(), "config.yaml")
+	if err := os.WriteFile(pathname, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv(EnvConfig, pathname)
+	if err := LoadConfigFile(logfield.New(logfield.ComConfig)); err != nil {
+		t.Fatal(err)
+	}
+	return LoadScoped(scope, defaults)
+}
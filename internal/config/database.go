@@ -1,14 +1,15 @@
 package config
 
 type _Database struct {
-	Type     string `yaml:"type"`
+	Type     string `yaml:"type" binding:"oneof=sqlite mysql postgres"`
 	Host     string `yaml:"host"`
 	Port     int    `yaml:"port"`
 	User     string `yaml:"user"`
-	Password string `yaml:"password"`
+	Password string `yaml:"password" config:"secret"`
 	Database string `yaml:"database"`
-	SSLMode  string `yaml:"ssl_mode"`
-	DBFile   string `yaml:"db_file"`
+	// tls of mysql, or sslmode of postgres
+	SSLMode string `yaml:"ssl_mode"`
+	DBFile  string `yaml:"db_file"`
 }
 
 var Database = LoadScoped("database", &_Database{
@@ -0,0 +1,146 @@
+package config
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// SecretMask replaces values of secret fields, patching with it keeps the value
+const SecretMask = "******"
+
+var ErrInvalid = errors.New("invalid config")
+
+// Field describes a config key, options are given by the config tag, e.g. `config:"secret,live"`
+type Field struct {
+	Key   string `json:"key"`
+	Value any    `json:"value"`
+	// value is masked
+	Secret bool `json:"secret"`
+	// default, file, env or file_ref
+	Source string `json:"source"`
+	// set by environment or read from file, can not be changed at runtime
+	EnvLocked bool `json:"env_locked"`
+	// takes effect without restart
+	Live bool `json:"live"`
+}
+
+type fieldOf struct {
+	index  int
+	key    string
+	secret bool
+	live   bool
+}
+
+func fieldsOf(t reflect.Type) []fieldOf {
+	var fields []fieldOf
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		key, _, _ := strings.Cut(f.Tag.Get("yaml"), ",")
+		if key == "" || key == "-" {
+			continue
+		}
+		options := "," + f.Tag.Get("config") + ","
+		fields = append(fields, fieldOf{
+			index:  i,
+			key:    key,
+			secret: strings.Contains(options, ",secret,"),
+			live:   strings.Contains(options, ",live,"),
+		})
+	}
+	return fields
+}
+
+func (c *ScopedConfig[T]) Scope() string {
+	return c.scope
+}
+
+// EnvLocked reports whether key is set by environment
+func (c *ScopedConfig[T]) EnvLocked(key string) bool {
+	return c.kEnv.Get(key) != nil
+}
+
+// Fields describes current values with secrets masked
+func (c *ScopedConfig[T]) Fields() []Field {
+	value := reflect.ValueOf(c.Get())
+	var result []Field
+	for _, f := range fieldsOf(value.Type()) {
+		field := Field{
+			Key:       f.key,
+			Value:     value.Field(f.index).Interface(),
+			Secret:    f.secret,
+			Source:    c.Source(f.key),
+			EnvLocked: c.EnvLocked(f.key) || c.FileRef(f.key),
+			Live:      f.live,
+		}
+		if f.secret {
+			if value.Field(f.index).IsZero() {
+				field.Value = ""
+			} else {
+				field.Value = SecretMask
+			}
+		}
+		result = append(result, field)
+	}
+	return result
+}
+
+// Patch returns a copy of current config with values keyed by yaml name applied
+func (c *ScopedConfig[T]) Patch(values map[string]json.RawMessage) (T, bool, error) {
+	var conf = c.Get()
+	value := reflect.ValueOf(&conf).Elem()
+
+	var fields = make(map[string]fieldOf)
+	for _, f := range fieldsOf(value.Type()) {
+		fields[f.key] = f
+	}
+
+	var restart bool
+	for key, raw := range values {
+		f, ok := fields[key]
+		if !ok {
+			return conf, false, fmt.Errorf("%w: unknown key %s.%s", ErrInvalid, c.scope, key)
+		}
+		if f.secret {
+			var masked string
+			if json.Unmarshal(raw, &masked) == nil && masked == SecretMask {
+				continue
+			}
+		}
+
+		target := reflect.New(value.Field(f.index).Type())
+		if err := json.Unmarshal(raw, target.Interface()); err != nil {
+			return conf, false, fmt.Errorf("%w: %s.%s: %v", ErrInvalid, c.scope, key, err)
+		}
+		if reflect.DeepEqual(target.Elem().Interface(), value.Field(f.index).Interface()) {
+			continue
+		}
+		if c.EnvLocked(key) {
+			return conf, false, fmt.Errorf("%w: %s.%s is set by environment", ErrInvalid, c.scope, key)
+		} else if c.FileRef(key) {
+			return conf, false, fmt.Errorf("%w: %s.%s is read from file", ErrInvalid, c.scope, key)
+		}
+		value.Field(f.index).Set(target.Elem())
+		if !f.live {
+			restart = true
+		}
+	}
+	return conf, restart, nil
+}
+
+// Update patches, validates and saves config
+func (c *ScopedConfig[T]) Update(values map[string]json.RawMessage) (restart bool, err error) {
+	c.updateLock.Lock()
+	defer c.updateLock.Unlock()
+
+	conf, restart, err := c.Patch(values)
+	if err != nil {
+		return false, err
+	}
+	if errs := c.validate(conf); errs != nil {
+		return false, fmt.Errorf("%w: %v", ErrInvalid, errs)
+	}
+	return restart, c.Save(conf)
+}
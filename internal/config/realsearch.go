@@ -7,8 +7,8 @@ import (
 )
 
 type _RealSearch struct {
-	Timeout uint8  `yaml:"timeout"`
-	BaseUrl string `yaml:"base_url"`
+	Timeout uint8  `yaml:"timeout" binding:"min=1"`
+	BaseUrl string `yaml:"base_url" binding:"required,url"`
 }
 
 var RealSearch = LoadScoped("realsearch", &_RealSearch{
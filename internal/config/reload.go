@@ -0,0 +1,106 @@
+package config
+
+import (
+	"errors"
+	"os"
+	"os/signal"
+	"reflect"
+	"sync"
+	"syscall"
+
+	"github.com/acgn-org/onest/internal/logfield"
+	"github.com/knadh/koanf/providers/file"
+)
+
+type reloadable interface {
+	Scope() string
+	Validate() ValidationError
+	reload() (bool, error)
+}
+
+var (
+	scopesLock sync.Mutex
+	scopes     []reloadable
+)
+
+// reloadLock serializes reloads triggered by both file watcher and signal
+var reloadLock sync.Mutex
+
+func registerScope(c reloadable) {
+	scopesLock.Lock()
+	defer scopesLock.Unlock()
+	scopes = append(scopes, c)
+}
+
+// reload merges defaults, file and env again, value is swapped and subscribers notified if changed
+func (c *ScopedConfig[T]) reload() (bool, error) {
+	conf, _, fileRefs, err := loadScoped(c.scope, c.defaults)
+	if err != nil {
+		return false, err
+	}
+	// invalid config is not applied
+	if errs := c.validate(conf); errs != nil {
+		return false, errs
+	}
+
+	c.updateLock.Lock()
+	defer c.updateLock.Unlock()
+	c.fileRefs.Store(&fileRefs)
+	if reflect.DeepEqual(conf, c.Get()) {
+		return false, nil
+	}
+	c.value.Store(conf)
+	c.notify()
+	return true, nil
+}
+
+// Reload reads config file again and applies changes of every scope
+func Reload() error {
+	reloadLock.Lock()
+	defer reloadLock.Unlock()
+
+	logger := logfield.New(logfield.ComConfig).WithAction("reload")
+	if err := LoadConfigFile(logger); err != nil {
+		return err
+	}
+
+	scopesLock.Lock()
+	defer scopesLock.Unlock()
+	var errs []error
+	for _, c := range scopes {
+		changed, err := c.reload()
+		if err != nil {
+			errs = append(errs, err)
+			logger.Errorf("reload %s failed, keeping current config:\n%v", c.Scope(), err)
+		} else if changed {
+			logger.Infof("%s reloaded", c.Scope())
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Watch reloads config on change of config file or SIGHUP
+func Watch() {
+	logger := logfield.New(logfield.ComConfig).WithAction("watch")
+
+	err := file.Provider(Pathname()).Watch(func(_ interface{}, err error) {
+		if err != nil {
+			logger.Warnln("watch config file failed:", err)
+			return
+		}
+		logger.Debugln("config file changed")
+		_ = Reload()
+	})
+	if err != nil {
+		logger.Warnln("config file is not watched, send SIGHUP to reload:", err)
+	}
+
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	go func() {
+		for range hup {
+			logger.Infoln("SIGHUP received")
+			_ = Reload()
+		}
+	}()
+}
@@ -0,0 +1,80 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"reflect"
+	"strings"
+
+	"github.com/knadh/koanf/v2"
+)
+
+// FileRefPrefix marks a secret in yaml read from file, e.g. `api_hash: file:/run/secrets/api_hash`
+const FileRefPrefix = "file:"
+
+// EnvFileSuffix marks a secret in env read from file, e.g. ONEST_TELEGRAM_API_HASH_FILE
+const EnvFileSuffix = "_file"
+
+func secretKeys(t reflect.Type) []string {
+	var keys []string
+	for _, f := range fieldsOf(t) {
+		if f.secret {
+			keys = append(keys, f.key)
+		}
+	}
+	return keys
+}
+
+// readSecretFile trims the trailing line break, which is added by most editors
+func readSecretFile(pathname string) (string, error) {
+	data, err := os.ReadFile(pathname)
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimRight(string(data), "\r\n"), nil
+}
+
+// resolveFileRefs replaces file references of secrets in k, keys resolved are returned
+func resolveFileRefs(k *koanf.Koanf, keys []string) (map[string]bool, error) {
+	var refs = make(map[string]bool)
+	for _, key := range keys {
+		value, ok := k.Get(key).(string)
+		if !ok || !strings.HasPrefix(value, FileRefPrefix) {
+			continue
+		}
+		secret, err := readSecretFile(strings.TrimPrefix(value, FileRefPrefix))
+		if err != nil {
+			return nil, fmt.Errorf("read %s from file failed: %w", key, err)
+		}
+		if err := k.Set(key, secret); err != nil {
+			return nil, err
+		}
+		refs[key] = true
+	}
+	return refs, nil
+}
+
+// resolveEnvFiles replaces <KEY>_FILE of secrets in kEnv with content of the file as <KEY>
+func resolveEnvFiles(kEnv *koanf.Koanf, keys []string) error {
+	for _, key := range keys {
+		pathname := kEnv.String(key + EnvFileSuffix)
+		if pathname == "" {
+			continue
+		}
+		secret, err := readSecretFile(pathname)
+		if err != nil {
+			return fmt.Errorf("read %s from file of env failed: %w", key, err)
+		}
+		kEnv.Delete(key + EnvFileSuffix)
+		if err := kEnv.Set(key, secret); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// FileRef reports whether key is a secret read from file referenced in yaml
+func (c *ScopedConfig[T]) FileRef(key string) bool {
+	refs := c.fileRefs.Load()
+	return refs != nil && (*refs)[key]
+}
@@ -0,0 +1,85 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+type testSecretScope struct {
+	Name  string `yaml:"name"`
+	Token string `yaml:"token" config:"secret"`
+	Key   string `yaml:"key" config:"secret"`
+	// not a secret, references are kept as is
+	Note string `yaml:"note"`
+}
+
+func writeSecret(t *testing.T, content string) string {
+	t.Helper()
+	pathname := filepath.Join(t.TempDir(), "secret")
+	if err := os.WriteFile(pathname, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return pathname
+}
+
+func TestSecretFileRefs(t *testing.T) {
+	tokenFile := writeSecret(t, "s3cret\r\n")
+	t.Setenv("ONEST_TESTSECRET_KEY_FILE", writeSecret(t, "k3y\n"))
+
+	c := loadTestScope(t, "testsecret", `
+testsecret:
+  name: onest
+  token: file:`+tokenFile+`
+  note: file:/not/read
+`, &testSecretScope{})
+
+	if c.Validate() != nil {
+		t.Fatal(c.Validate())
+	}
+	conf := c.Get()
+	if conf.Token != "s3cret" || conf.Key != "k3y" || conf.Note != "file:/not/read" {
+		t.Errorf("got %+v", conf)
+	}
+	for key, want := range map[string]string{
+		"name":  SourceFile,
+		"token": SourceFileRef,
+		"key":   SourceEnv,
+		"note":  SourceFile,
+	} {
+		if got := c.Source(key); got != want {
+			t.Errorf("source of %s got %s, want %s", key, got, want)
+		}
+	}
+
+	// secrets read from file are never written back
+	conf.Name = "renamed"
+	conf.Token = "changed"
+	if err := c.Save(conf); err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(Pathname())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if content := string(data); !strings.Contains(content, "name: renamed") ||
+		!strings.Contains(content, "token: file:"+tokenFile) || strings.Contains(content, "changed") {
+		t.Errorf("saved config file:\n%s", content)
+	}
+}
+
+func TestSecretFileRefMissing(t *testing.T) {
+	c := loadTestScope(t, "testsecretmissing", `
+testsecretmissing:
+  token: file:`+filepath.Join(t.TempDir(), "missing")+`
+`, &testSecretScope{Name: "default"})
+
+	got := c.Validate()
+	if len(got) != 1 || got[0].Key != "testsecretmissing" || !strings.Contains(got[0].Message, "read token from file failed") {
+		t.Fatalf("got %v, want an error of reading token", got)
+	}
+	if c.Get().Name != "default" {
+		t.Errorf("got %+v, want defaults", c.Get())
+	}
+}
@@ -9,11 +9,13 @@ import (
 
 type _Server struct {
 	Host        string `yaml:"host"`
-	Port        uint16 `yaml:"port"`
-	Timeout     uint   `yaml:"timeout"`
-	LogLevel    string `yaml:"log_level"`
-	LogRingSize int    `yaml:"log_ring_size"`
-	FilePerm    string `yaml:"file_perm"`
+	Port        uint16 `yaml:"port" binding:"min=1"`
+	Timeout     uint   `yaml:"timeout" config:"live" binding:"min=1"`
+	LogLevel    string `yaml:"log_level" config:"live" binding:"loglevel"`
+	LogRingSize int    `yaml:"log_ring_size" binding:"min=1"`
+	FilePerm    string `yaml:"file_perm" binding:"filemode"`
+	// X-Forwarded-For is only taken from these addresses, client ip is the remote address if empty
+	TrustedProxies []string `yaml:"trusted_proxies" binding:"dive,ip|cidr"`
 }
 
 var Server = LoadScoped("server", &_Server{
@@ -27,16 +29,18 @@ var Server = LoadScoped("server", &_Server{
 
 var FilePerm os.FileMode
 
+// invalid values are reported by Validate
 func init() {
-	lv, err := log.ParseLevel(Server.Get().LogLevel)
-	if err != nil {
-		log.Fatalln("failed to parse log level:", err)
+	applyLogLevel()
+	Server.OnChange(applyLogLevel)
+
+	if perm, err := strconv.ParseUint(Server.Get().FilePerm, 8, 32); err == nil {
+		FilePerm = os.FileMode(perm)
 	}
-	log.SetLevel(lv)
+}
 
-	perm, err := strconv.ParseUint(Server.Get().FilePerm, 8, 32)
-	if err != nil {
-		log.Fatalf("invalid file perm '%s'", Server.Get().FilePerm)
+func applyLogLevel() {
+	if lv, err := log.ParseLevel(Server.Get().LogLevel); err == nil {
+		log.SetLevel(lv)
 	}
-	FilePerm = os.FileMode(perm)
 }
@@ -1,14 +1,41 @@
 package config
 
-import "github.com/acgn-org/onest/internal/logfield"
+import (
+	"github.com/acgn-org/onest/internal/logfield"
+	"github.com/acgn-org/onest/telegram/tdconfig"
+)
 
 type _Telegram struct {
-	ApiId               int32  `yaml:"api_id"`
-	ApiHash             string `yaml:"api_hash"`
-	DataFolder          string `yaml:"data_folder"`
-	MaxParallelDownload uint8  `yaml:"max_parallel_download"`
-	MaxDownloadError    uint32 `yaml:"max_download_error"`
-	ScanThresholdDays   uint16 `yaml:"scan_threshold_days"`
+	ApiId               int32  `yaml:"api_id" binding:"required"`
+	ApiHash             string `yaml:"api_hash" config:"secret" binding:"required"`
+	DataFolder          string `yaml:"data_folder" binding:"required"`
+	MaxParallelDownload uint8  `yaml:"max_parallel_download" config:"live" binding:"min=1"`
+	MaxDownloadError    uint32 `yaml:"max_download_error" config:"live" binding:"min=1"`
+	ScanThresholdDays   uint16 `yaml:"scan_threshold_days" config:"live" binding:"min=1"`
+	// urls of http, socks5 or mtproto proxies, the first reachable is used, HTTP_PROXY is used if empty
+	Proxy []string `yaml:"proxy" config:"secret" binding:"dive,proxyurl"`
+	// more accounts scanning and downloading along with the one above, api id, hash and proxies are shared
+	Accounts []TelegramAccount `yaml:"accounts" binding:"unique=Name,dive"`
+}
+
+type TelegramAccount struct {
+	// the account above is named default
+	Name                string `yaml:"name" binding:"required,ne=default"`
+	DataFolder          string `yaml:"data_folder" binding:"required"`
+	MaxParallelDownload uint8  `yaml:"max_parallel_download" binding:"min=1"`
+}
+
+// MaxParallelDownloadOf account, 0 if the account is removed from config
+func (c _Telegram) MaxParallelDownloadOf(account string) uint8 {
+	if account == tdconfig.DefaultAccount {
+		return c.MaxParallelDownload
+	}
+	for _, a := range c.Accounts {
+		if a.Name == account {
+			return a.MaxParallelDownload
+		}
+	}
+	return 0
 }
 
 var Telegram = LoadScoped("telegram", &_Telegram{
@@ -0,0 +1,179 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/acgn-org/onest/telegram/tdconfig"
+	"github.com/go-playground/validator/v10"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	SourceDefault = "default"
+	SourceFile    = "file"
+	SourceEnv     = "env"
+	// secret read from file referenced in yaml
+	SourceFileRef = "file_ref"
+)
+
+// validate checks rules in binding tags, same as forms of the api
+var validate = func() *validator.Validate {
+	v := validator.New()
+	v.SetTagName("binding")
+	v.RegisterTagNameFunc(func(f reflect.StructField) string {
+		key, _, _ := strings.Cut(f.Tag.Get("yaml"), ",")
+		return key
+	})
+	_ = v.RegisterValidation("filemode", func(fl validator.FieldLevel) bool {
+		_, err := strconv.ParseUint(fl.Field().String(), 8, 32)
+		return err == nil
+	})
+	_ = v.RegisterValidation("loglevel", func(fl validator.FieldLevel) bool {
+		_, err := log.ParseLevel(fl.Field().String())
+		return err == nil
+	})
+	_ = v.RegisterValidation("proxyurl", func(fl validator.FieldLevel) bool {
+		_, err := tdconfig.ParseProxyUrl(fl.Field().String())
+		return err == nil
+	})
+	return v
+}()
+
+type FieldError struct {
+	// with scope, e.g. server.port
+	Key string `json:"key"`
+	// empty if unknown, e.g. failed to load
+	Source string `json:"source,omitempty"`
+	// omitted for secrets
+	Value   any    `json:"value,omitempty"`
+	Message string `json:"message"`
+}
+
+func (e FieldError) Error() string {
+	var key = e.Key
+	if e.Source != "" {
+		key += " (" + e.Source + ")"
+	}
+	if value, ok := e.Value.(string); ok {
+		return fmt.Sprintf("%s: %s, got %q", key, e.Message, value)
+	} else if e.Value != nil {
+		return fmt.Sprintf("%s: %s, got %v", key, e.Message, e.Value)
+	}
+	return fmt.Sprintf("%s: %s", key, e.Message)
+}
+
+// ValidationError reports every invalid key at once
+type ValidationError []FieldError
+
+func (e ValidationError) Error() string {
+	var lines = make([]string, len(e))
+	for i, fieldError := range e {
+		lines[i] = fieldError.Error()
+	}
+	return strings.Join(lines, "\n")
+}
+
+func ruleMessage(fe validator.FieldError) string {
+	switch fe.Tag() {
+	case "required":
+		return "is required"
+	case "min":
+		return "must be at least " + fe.Param()
+	case "max":
+		return "must be at most " + fe.Param()
+	case "oneof":
+		return "must be one of " + strings.Join(strings.Fields(fe.Param()), ", ")
+	case "url":
+		return "must be a url"
+	case "filemode":
+		return "must be an octal file mode"
+	case "loglevel":
+		return "must be a log level"
+	case "ne":
+		return "must not be " + fe.Param()
+	case "unique":
+		return "must be unique by " + strings.ToLower(fe.Param())
+	case "ip|cidr":
+		return "must be an ip or cidr"
+	case "proxyurl":
+		return "must be a proxy url of http, socks5, mtproto or tg scheme"
+	}
+	return fmt.Sprintf("failed on rule '%s'", fe.Tag())
+}
+
+// Source tells where the value of key comes from
+func (c *ScopedConfig[T]) Source(key string) string {
+	if c.EnvLocked(key) {
+		return SourceEnv
+	}
+	if c.FileRef(key) {
+		return SourceFileRef
+	}
+	kFileLock.RLock()
+	defer kFileLock.RUnlock()
+	if kFile.Exists(c.scope + "." + key) {
+		return SourceFile
+	}
+	return SourceDefault
+}
+
+func (c *ScopedConfig[T]) validate(conf T) ValidationError {
+	err := validate.Struct(conf)
+	if err == nil {
+		return nil
+	}
+	var fieldErrors validator.ValidationErrors
+	if !errors.As(err, &fieldErrors) {
+		return ValidationError{{Key: c.scope, Source: SourceDefault, Message: err.Error()}}
+	}
+
+	var secrets = make(map[string]bool)
+	for _, f := range fieldsOf(reflect.TypeOf(conf)) {
+		secrets[f.key] = f.secret
+	}
+	var result = make(ValidationError, len(fieldErrors))
+	for i, fe := range fieldErrors {
+		// nested fields are reported as e.g. accounts[0].name, and checked as the key of top level
+		_, field, _ := strings.Cut(fe.Namespace(), ".")
+		key, _, _ := strings.Cut(field, "[")
+		key, _, _ = strings.Cut(key, ".")
+		result[i] = FieldError{
+			Key:     c.scope + "." + field,
+			Source:  c.Source(key),
+			Message: ruleMessage(fe),
+		}
+		if !secrets[key] {
+			result[i].Value = fe.Value()
+		}
+	}
+	return result
+}
+
+// Validate checks current value, including failure of loading
+func (c *ScopedConfig[T]) Validate() ValidationError {
+	if c.loadErr != nil {
+		// errors of decoding are listed in lines
+		message := strings.Join(strings.Fields(c.loadErr.Error()), " ")
+		return ValidationError{{Key: c.scope, Message: message}}
+	}
+	return c.validate(c.Get())
+}
+
+// Validate checks every scope loaded
+func Validate() error {
+	scopesLock.Lock()
+	defer scopesLock.Unlock()
+
+	var result ValidationError
+	for _, c := range scopes {
+		result = append(result, c.Validate()...)
+	}
+	if len(result) != 0 {
+		return result
+	}
+	return nil
+}
@@ -0,0 +1,92 @@
+package config
+
+import (
+	"errors"
+	"testing"
+)
+
+type testValidateScope struct {
+	Name    string   `yaml:"name" binding:"required"`
+	Port    uint16   `yaml:"port" binding:"min=1"`
+	Level   string   `yaml:"level" binding:"loglevel"`
+	Token   string   `yaml:"token" config:"secret" binding:"omitempty,url"`
+	Proxies []string `yaml:"proxies" binding:"dive,ip|cidr"`
+}
+
+func TestValidate(t *testing.T) {
+	c := loadTestScope(t, "testvalidate", `
+testvalidate:
+  port: 0
+  level: loud
+  token: not a url
+  proxies: [10.0.0.1, 10.0.0.0/8, proxy]
+`, &testValidateScope{Port: 80, Level: "info"})
+
+	want := map[string]FieldError{
+		"testvalidate.name":  {Source: SourceDefault, Message: "is required", Value: ""},
+		"testvalidate.port":  {Source: SourceFile, Message: "must be at least 1", Value: uint16(0)},
+		"testvalidate.level": {Source: SourceFile, Message: "must be a log level", Value: "loud"},
+		// value of secrets is omitted
+		"testvalidate.token":      {Source: SourceFile, Message: "must be a url"},
+		"testvalidate.proxies[2]": {Source: SourceFile, Message: "must be an ip or cidr", Value: "proxy"},
+	}
+	got := c.Validate()
+	if len(got) != len(want) {
+		t.Fatalf("got %d errors, want %d: %v", len(got), len(want), got)
+	}
+	for _, fieldError := range got {
+		w, ok := want[fieldError.Key]
+		if !ok {
+			t.Errorf("unexpected error %v", fieldError)
+			continue
+		}
+		if fieldError.Source != w.Source || fieldError.Message != w.Message || fieldError.Value != w.Value {
+			t.Errorf("got %#v, want %#v", fieldError, w)
+		}
+	}
+
+	// every scope is reported at once
+	var validationError ValidationError
+	if err := Validate(); !errors.As(err, &validationError) {
+		t.Fatalf("got %v, want ValidationError", err)
+	}
+	var reported int
+	for _, fieldError := range validationError {
+		if _, ok := want[fieldError.Key]; ok {
+			reported++
+		}
+	}
+	if reported != len(want) {
+		t.Errorf("Validate reported %d errors of the scope, want %d", reported, len(want))
+	}
+}
+
+func TestValidateLoadError(t *testing.T) {
+	c := loadTestScope(t, "testvalidateload", `
+testvalidateload:
+  name: onest
+  port: eighty
+`, &testValidateScope{Port: 80, Level: "info"})
+
+	got := c.Validate()
+	if len(got) != 1 || got[0].Key != "testvalidateload" {
+		t.Fatalf("got %v, want a single error of the scope", got)
+	}
+	// defaults are used instead
+	if c.Get().Port != 80 {
+		t.Errorf("got port %d, want default 80", c.Get().Port)
+	}
+}
+
+func TestValidateValid(t *testing.T) {
+	c := loadTestScope(t, "testvalidateok", `
+testvalidateok:
+  name: onest
+  token: https://example.com/
+  proxies: [127.0.0.1]
+`, &testValidateScope{Port: 80, Level: "info"})
+
+	if got := c.Validate(); got != nil {
+		t.Errorf("got %v, want no error", got)
+	}
+}
@@ -3,22 +3,40 @@ package database
 import (
 	"context"
 	"fmt"
+	"net"
+	"net/url"
+	"strconv"
 
 	"github.com/acgn-org/onest/internal/config"
 	"github.com/acgn-org/onest/internal/logfield"
 	"github.com/acgn-org/onest/repository"
 	"gorm.io/driver/mysql"
+	"gorm.io/driver/postgres"
 	"gorm.io/driver/sqlite"
 	"gorm.io/gorm"
 )
 
 var DB *gorm.DB
 
-func init() {
+// Connect opens the database and applies pending migrations
+func Connect(ctx context.Context) error {
+	if err := Open(ctx); err != nil {
+		return err
+	}
+	if err := Migrate(ctx); err != nil {
+		return fmt.Errorf("migrate failed: %w", err)
+	}
+	return nil
+}
+
+// Open opens the database only, e.g. to show status of migrations
+func Open(_ context.Context) error {
 	logger := logfield.New(logfield.ComDatabase)
 
 	conf := &gorm.Config{
 		SkipDefaultTransaction: true,
+		// e.g. gorm.ErrDuplicatedKey is returned for every dialect
+		TranslateError: true,
 		Logger: &Logger{
 			Entry: logger.Entry,
 		},
@@ -42,16 +60,41 @@ func init() {
 			databaseConfig.Database,
 			databaseConfig.SSLMode,
 		)), conf)
+	case "postgres":
+		port := databaseConfig.Port
+		if port == 0 {
+			port = 5432
+		}
+		// an url keeps credentials with spaces or quotes as is
+		dsn := url.URL{
+			Scheme: "postgres",
+			User:   url.UserPassword(databaseConfig.User, databaseConfig.Password),
+			Host:   net.JoinHostPort(databaseConfig.Host, strconv.Itoa(port)),
+			Path:   "/" + databaseConfig.Database,
+		}
+		if databaseConfig.SSLMode != "" {
+			dsn.RawQuery = url.Values{"sslmode": {databaseConfig.SSLMode}}.Encode()
+		}
+		DB, err = gorm.Open(postgres.Open(dsn.String()), conf)
 	default:
-		logger.Fatalf("unsupported database type: %s", databaseConfig.Type)
+		return fmt.Errorf("unsupported database type: %s", databaseConfig.Type)
 	}
 	if err != nil {
-		logger.Fatalln("failed:", err)
+		return err
 	}
+	logger.Debugln("connected")
+	return nil
+}
 
-	if err := repository.AutoMigrate(DB); err != nil {
-		logger.WithAction("migrate").Fatalln("failed:", err)
+func Close(_ context.Context) error {
+	if DB == nil {
+		return nil
+	}
+	sqlDB, err := DB.DB()
+	if err != nil {
+		return err
 	}
+	return sqlDB.Close()
 }
 
 func Begin() *gorm.DB {
@@ -19,15 +19,15 @@ func (l *Logger) LogMode(gormlogger.LogLevel) gormlogger.Interface {
 }
 
 func (l *Logger) Info(ctx context.Context, s string, args ...interface{}) {
-	l.Entry.WithContext(ctx).Infof(s, args)
+	l.Entry.WithContext(ctx).Infof(s, args...)
 }
 
 func (l *Logger) Warn(ctx context.Context, s string, args ...interface{}) {
-	l.Entry.WithContext(ctx).Warnf(s, args)
+	l.Entry.WithContext(ctx).Warnf(s, args...)
 }
 
 func (l *Logger) Error(ctx context.Context, s string, args ...interface{}) {
-	l.Entry.WithContext(ctx).Errorf(s, args)
+	l.Entry.WithContext(ctx).Errorf(s, args...)
 }
 
 func (l *Logger) Trace(ctx context.Context, begin time.Time, fc func() (string, int64), err error) {
@@ -47,5 +47,5 @@ func (l *Logger) Trace(ctx context.Context, begin time.Time, fc func() (string,
 		return
 	}
 
-	logger.Tracef(sql)
+	logger.Traceln(sql)
 }
@@ -0,0 +1,104 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/acgn-org/onest/internal/logfield"
+	"github.com/acgn-org/onest/repository"
+	"gorm.io/gorm"
+)
+
+var ErrSchemaNewer = errors.New("database schema is newer than this binary")
+
+type SchemaStatus struct {
+	// the latest version applied, 0 if the database is not versioned yet
+	Version uint
+	// version of this binary
+	Latest  uint
+	Applied []repository.SchemaMigration
+	Pending []repository.Migration
+}
+
+// Status compares migrations applied to the database with the ones of this binary
+func Status() (*SchemaStatus, error) {
+	applied, err := NewRepository[repository.MigrationRepository]().Applied()
+	if err != nil {
+		return nil, err
+	}
+
+	status := &SchemaStatus{
+		Latest:  repository.SchemaVersion(),
+		Applied: applied,
+	}
+	var done = make(map[uint]bool, len(applied))
+	for _, migration := range applied {
+		done[migration.Version] = true
+		status.Version = max(status.Version, migration.Version)
+	}
+	for _, migration := range repository.Migrations {
+		if !done[migration.Version] {
+			status.Pending = append(status.Pending, migration)
+		}
+	}
+	return status, nil
+}
+
+// Migrate applies pending migrations in order, a database newer than this binary is refused
+func Migrate(_ context.Context) error {
+	logger := logfield.New(logfield.ComDatabase).WithAction("migrate")
+
+	status, err := Status()
+	if err != nil {
+		return err
+	}
+	if status.Version > status.Latest {
+		return fmt.Errorf("%w: version %d, this binary supports up to %d, please upgrade onest", ErrSchemaNewer, status.Version, status.Latest)
+	}
+
+	if status.Version == 0 {
+		// created, or upgraded from releases before versioning
+		err := DB.Transaction(func(tx *gorm.DB) error {
+			if err := repository.AutoMigrate(tx); err != nil {
+				return err
+			}
+			migrationRepo := repository.MigrationRepository{Repository: repository.Repository{DB: tx}}
+			return migrationRepo.Create(repository.Migrations...)
+		})
+		if err != nil {
+			return err
+		}
+		logger.Infof("schema set up at version %d", status.Latest)
+		return nil
+	}
+
+	dialect := DB.Dialector.Name()
+	for _, migration := range status.Pending {
+		err := DB.Transaction(func(tx *gorm.DB) error {
+			if migration.SQL != nil {
+				statements, ok := migration.SQL[dialect]
+				if !ok {
+					return fmt.Errorf("no statements for %s", dialect)
+				}
+				for _, statement := range statements {
+					if err := tx.Exec(statement).Error; err != nil {
+						return err
+					}
+				}
+			}
+			if migration.Run != nil {
+				if err := migration.Run(tx); err != nil {
+					return err
+				}
+			}
+			migrationRepo := repository.MigrationRepository{Repository: repository.Repository{DB: tx}}
+			return migrationRepo.Create(migration)
+		})
+		if err != nil {
+			return fmt.Errorf("migration %d %s failed: %w", migration.Version, migration.Name, err)
+		}
+		logger.Infof("migrated to version %d: %s", migration.Version, migration.Name)
+	}
+	return nil
+}
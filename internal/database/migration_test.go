@@ -0,0 +1,98 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"testing"
+
+	"github.com/acgn-org/onest/repository"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+func openTestDB(t *testing.T) {
+	t.Helper()
+	db, err := gorm.Open(sqlite.Open(filepath.Join(t.TempDir(), "onest.sqlite")), &gorm.Config{
+		Logger: logger.Default.LogMode(logger.Silent),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	DB = db
+	t.Cleanup(func() {
+		if sqlDB, err := db.DB(); err == nil {
+			_ = sqlDB.Close()
+		}
+		DB = nil
+	})
+}
+
+func TestMigrateFresh(t *testing.T) {
+	openTestDB(t)
+	if err := Migrate(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	status, err := Status()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if status.Version != repository.SchemaVersion() || len(status.Pending) != 0 || len(status.Applied) != len(repository.Migrations) {
+		t.Errorf("got version %d with %d pending, %d applied", status.Version, len(status.Pending), len(status.Applied))
+	}
+	// nothing to do the second time
+	if err := Migrate(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestMigratePending(t *testing.T) {
+	openTestDB(t)
+	if err := Migrate(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	// back to the baseline
+	for _, statement := range []string{
+		"DROP INDEX idx_audit_logs_actor_id",
+		"ALTER TABLE audit_logs DROP COLUMN actor_id",
+		"ALTER TABLE companions DROP COLUMN retries",
+		"ALTER TABLE companions DROP COLUMN retry_at",
+		"DELETE FROM schema_migrations WHERE version > 1",
+	} {
+		if err := DB.Exec(statement).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := Migrate(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	status, err := Status()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if status.Version != repository.SchemaVersion() || len(status.Pending) != 0 {
+		t.Errorf("got version %d with %d pending", status.Version, len(status.Pending))
+	}
+	migrator := DB.Migrator()
+	if !migrator.HasColumn(&repository.Companion{}, "retry_at") || !migrator.HasColumn(&repository.AuditLog{}, "actor_id") ||
+		!migrator.HasIndex(&repository.AuditLog{}, "idx_audit_logs_actor_id") {
+		t.Error("columns of pending migrations are missing")
+	}
+}
+
+func TestMigrateRefusesNewerSchema(t *testing.T) {
+	openTestDB(t)
+	if err := Migrate(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	migrationRepo := NewRepository[repository.MigrationRepository]()
+	if err := migrationRepo.Create(repository.Migration{Version: repository.SchemaVersion() + 1, Name: "from the future"}); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := Migrate(context.Background()); !errors.Is(err, ErrSchemaNewer) {
+		t.Errorf("got error %v, want %v", err, ErrSchemaNewer)
+	}
+}
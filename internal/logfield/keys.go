@@ -15,4 +15,6 @@ const (
 	ComTask            = "queue:task"
 	ComQueueSupervisor = "queue:supervisor"
 	ComLogTee          = "logtee"
+	ComAuth            = "auth"
+	ComApp             = "app"
 )
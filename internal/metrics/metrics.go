@@ -0,0 +1,87 @@
+package metrics
+
+import (
+	"net/http"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const namespace = "onest"
+
+// Registry is served at /metrics, collectors of other packages are registered to it on init
+var Registry = prometheus.NewRegistry()
+
+var (
+	DownloadedBytes = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "downloaded_bytes_total",
+		Help:      "Bytes of videos downloaded by tasks.",
+	})
+	DownloadsCompleted = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "downloads_completed_total",
+		Help:      "Downloads moved to target path.",
+	})
+	TaskErrors = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "task_errors_total",
+		Help:      "Errors of download tasks, a task is retried until it turns fatal.",
+	})
+	TaskFatals = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "task_fatals_total",
+		Help:      "Download tasks turned fatal.",
+	})
+
+	FloodWaits = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "telegram_flood_waits_total",
+		Help:      "Requests delayed by flood control of telegram.",
+	}, []string{"account"})
+	FloodWaitSeconds = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "telegram_flood_wait_seconds_total",
+		Help:      "Seconds waited for flood control of telegram.",
+	}, []string{"account"})
+
+	ScanDuration = prometheus.NewHistogram(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "scan_duration_seconds",
+		Help:      "Duration of scanning channel histories for new downloads.",
+		Buckets:   []float64{0.1, 0.5, 1, 5, 10, 30, 60, 300},
+	})
+	ScanMatches = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "scan_matches_total",
+		Help:      "Messages matched by items and created as downloads on scan.",
+	})
+
+	HTTPRequestDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "http_request_duration_seconds",
+		Help:      "Latency of api requests, websockets are measured until closed.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"method", "route", "code"})
+)
+
+func init() {
+	Registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		DownloadedBytes,
+		DownloadsCompleted,
+		TaskErrors,
+		TaskFatals,
+		FloodWaits,
+		FloodWaitSeconds,
+		ScanDuration,
+		ScanMatches,
+		HTTPRequestDuration,
+	)
+}
+
+func Handler() http.Handler {
+	return promhttp.HandlerFor(Registry, promhttp.HandlerOpts{Registry: Registry})
+}
@@ -0,0 +1,253 @@
+package queue
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"path"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/acgn-org/onest/internal/config"
+	"github.com/acgn-org/onest/internal/database"
+	"github.com/acgn-org/onest/internal/logfield"
+	"github.com/acgn-org/onest/internal/source"
+	"github.com/acgn-org/onest/repository"
+	"github.com/acgn-org/onest/tools"
+	"github.com/zelenin/go-tdlib/client"
+	"gorm.io/gorm"
+)
+
+// tdlib message id of server messages is shifted by 20 bits
+const messageIDShift = 20
+
+type CompanionMatcher struct {
+	repository.CompanionRule
+	regexp *regexp.Regexp
+}
+
+func CompileCompanionRules(rules []repository.CompanionRule) ([]CompanionMatcher, error) {
+	matchers := make([]CompanionMatcher, len(rules))
+	for i, rule := range rules {
+		if rule.MsgWindow <= 0 && rule.TimeWindow <= 0 {
+			return nil, fmt.Errorf("companion rule %d has no window", i)
+		}
+		reg, err := regexp.Compile(rule.Regexp)
+		if err != nil {
+			return nil, fmt.Errorf("compile companion rule %d failed: %w", i, err)
+		}
+		matchers[i] = CompanionMatcher{
+			CompanionRule: rule,
+			regexp:        reg,
+		}
+	}
+	return matchers, nil
+}
+
+// matchCompanions attaches document messages to the nearest preceding download of the item
+func matchCompanions(repo repository.Repository, account *source.Account, item *repository.Item, matchers []CompanionMatcher, messages []*client.Message) (int, error) {
+	downloadRepo := repository.DownloadRepository{Repository: repo}
+	companionRepo := repository.CompanionRepository{Repository: repo}
+
+	var created int
+	for _, msg := range messages {
+		document, ok := account.Telegram.GetMessageDocument(msg)
+		if !ok {
+			continue
+		}
+		for _, matcher := range matchers {
+			if !matcher.regexp.MatchString(document.Document.FileName) {
+				continue
+			}
+
+			var minMsgID = msg.Id
+			if matcher.MsgWindow > 0 {
+				minMsgID = msg.Id - matcher.MsgWindow<<messageIDShift
+			}
+			var minDate int32 = math.MaxInt32
+			if matcher.TimeWindow > 0 {
+				minDate = msg.Date - matcher.TimeWindow
+			}
+
+			download, err := downloadRepo.FirstPrecedingByItemID(item.ID, msg.Id, minMsgID, minDate)
+			if err != nil {
+				if errors.Is(err, gorm.ErrRecordNotFound) {
+					break
+				}
+				return created, err
+			}
+
+			if err := companionRepo.Create(&repository.Companion{
+				DownloadID: download.ID,
+				MsgID:      msg.Id,
+				FileName:   document.Document.FileName,
+				Size:       document.Document.Document.Size,
+			}); err != nil {
+				return created, err
+			}
+			created++
+			break
+		}
+	}
+	return created, nil
+}
+
+// companionSuffix returns the part after the base name of the video, e.g. '.ass'
+func companionSuffix(videoPath, companionPath string) (string, bool) {
+	base := strings.TrimSuffix(videoPath, path.Ext(videoPath))
+	if !strings.HasPrefix(companionPath, base+".") {
+		return "", false
+	}
+	return strings.TrimPrefix(companionPath, base), true
+}
+
+func companionPath(videoPath string, companion *repository.Companion) string {
+	ext := path.Ext(companion.FileName)
+	target := sidecarPath(videoPath, ext)
+	if _, err := os.Stat(target); err == nil {
+		target = sidecarPath(videoPath, fmt.Sprintf(".%d%s", companion.ID, ext))
+	}
+	return target
+}
+
+// companionFile is a companion document being downloaded by TDLib of the account
+type companionFile struct {
+	account *source.Account
+	fileID  int32
+}
+
+// companion id => companionFile, completion is noticed by updates of the file
+var companionDownloads sync.Map
+
+func companionsInFlight() bool {
+	var ok bool
+	companionDownloads.Range(func(_, _ any) bool {
+		ok = true
+		return false
+	})
+	return ok
+}
+
+// isCompanionFile reports whether the file of the account is a companion being downloaded
+func isCompanionFile(account *source.Account, fileID int32) bool {
+	var ok bool
+	companionDownloads.Range(func(_, value any) bool {
+		ok = value.(companionFile) == companionFile{account: account, fileID: fileID}
+		return !ok
+	})
+	return ok
+}
+
+// companionBackoff is the delay before retrying a companion failed retries times before
+func companionBackoff(retries int) time.Duration {
+	return time.Minute << min(retries, 8)
+}
+
+// downloadCompanion starts downloading the document in background, the file returned may be completed already
+func downloadCompanion(ctx context.Context, companion *repository.CompanionWithChannelID) (*companionFile, *client.File, error) {
+	if value, ok := companionDownloads.Load(companion.ID); ok {
+		if download := value.(companionFile); download.account.Ready() {
+			file, err := download.account.Telegram.DownloadFile(download.fileID, 1, false)
+			return &download, file, err
+		}
+	}
+
+	account, err := source.AccountForChannel(ctx, companion.ChannelID, companion.ItemAccount)
+	if err != nil {
+		return nil, nil, err
+	}
+	msg, err := account.Telegram.GetMessage(ctx, companion.ChannelID, companion.MsgID)
+	if err != nil {
+		return nil, nil, err
+	}
+	document, ok := account.Telegram.GetMessageDocument(msg)
+	if !ok {
+		return nil, nil, errors.New("no document found")
+	}
+
+	download := companionFile{account: account, fileID: document.Document.Document.Id}
+	file, err := account.Telegram.DownloadFile(download.fileID, 1, false)
+	return &download, file, err
+}
+
+func placeCompanion(companion *repository.CompanionWithChannelID, file *client.File) (string, error) {
+	target := companionPath(companion.VideoPath, &companion.Companion)
+	if err := tools.EnsureDirectory(path.Dir(target), config.FilePerm); err != nil {
+		return "", err
+	}
+	if err := tools.MoveFile(file.Local.Path, target, config.FilePerm); err != nil && !errors.Is(err, tools.ErrRemoveSource) {
+		return "", err
+	}
+	return target, nil
+}
+
+// ProcessPendingCompanions starts downloading companions of completed downloads, and places those completed.
+// It returns the number of companions placed or being downloaded.
+func ProcessPendingCompanions(ctx context.Context) (int, error) {
+	logger := logfield.New(logfield.ComQueue).WithAction("companion")
+
+	companionRepo := database.NewRepository[repository.CompanionRepository]()
+	companions, err := companionRepo.GetPending(16, time.Now().Unix())
+	if err != nil {
+		return 0, err
+	}
+
+	// downloads of companions no longer pending are forgotten, e.g. the video was deleted
+	var pending = make(map[uint]bool, len(companions))
+	for _, companion := range companions {
+		pending[companion.ID] = true
+	}
+	companionDownloads.Range(func(key, _ any) bool {
+		if !pending[key.(uint)] {
+			companionDownloads.Delete(key)
+		}
+		return true
+	})
+
+	var active int
+	for _, companion := range companions {
+		logger := logger.WithField("companion", companion.ID)
+
+		download, file, err := downloadCompanion(ctx, &companion)
+		if errors.Is(err, source.ErrNoAccountReady) || errors.Is(err, context.DeadlineExceeded) {
+			// retried on the next round
+			logger.Debugln("download companion skipped:", err)
+			continue
+		} else if err != nil {
+			companionDownloads.Delete(companion.ID)
+			retryAt := time.Now().Add(companionBackoff(companion.Retries))
+			logger.Warnf("download companion failed, retry at %s: %v", retryAt.Format(time.DateTime), err)
+			if err := companionRepo.UpdateError(companion.ID, err.Error(), companion.Retries+1, retryAt.Unix()); err != nil {
+				logger.Errorln("save companion error failed:", err)
+			}
+			continue
+		}
+		active++
+		if !file.Local.IsDownloadingCompleted {
+			companionDownloads.Store(companion.ID, *download)
+			continue
+		}
+		companionDownloads.Delete(companion.ID)
+
+		target, err := placeCompanion(&companion, file)
+		if err != nil {
+			retryAt := time.Now().Add(companionBackoff(companion.Retries))
+			logger.Warnf("place companion failed, retry at %s: %v", retryAt.Format(time.DateTime), err)
+			if err := companionRepo.UpdateError(companion.ID, err.Error(), companion.Retries+1, retryAt.Unix()); err != nil {
+				logger.Errorln("save companion error failed:", err)
+			}
+			continue
+		}
+
+		if err := companionRepo.UpdateComplete(companion.ID, target); err != nil {
+			logger.Errorln("save companion state failed:", err)
+			continue
+		}
+		logger.Debugf("placed '%s' as '%s'", companion.FileName, target)
+	}
+	return active, nil
+}
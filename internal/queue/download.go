@@ -3,32 +3,20 @@ package queue
 import (
 	"container/list"
 	"context"
+	"errors"
 	"fmt"
-	"regexp"
 	"time"
 
 	"github.com/acgn-org/onest/internal/config"
 	"github.com/acgn-org/onest/internal/database"
 	"github.com/acgn-org/onest/internal/logfield"
+	"github.com/acgn-org/onest/internal/metrics"
 	"github.com/acgn-org/onest/internal/source"
 	"github.com/acgn-org/onest/repository"
-	"github.com/acgn-org/onest/tools"
+	"github.com/prometheus/client_golang/prometheus"
 	"github.com/zelenin/go-tdlib/client"
 )
 
-func GetDownloading() ([]repository.DownloadTask, error) {
-	taskIds := queue.AllKeys()
-	tasks, err := database.NewRepository[repository.DownloadRepository]().GetByID(taskIds...)
-	if err != nil {
-		return nil, err
-	}
-
-	if len(tasks) != 0 {
-		MigrateDownloadTaskInfo(tasks)
-	}
-	return tasks, nil
-}
-
 func MigrateDownloadTaskInfo(tasks []repository.DownloadTask) {
 	for i, task := range tasks {
 		taskQueue, ok := queue.Load(task.ID)
@@ -48,16 +36,16 @@ func MigrateDownloadTaskInfo(tasks []repository.DownloadTask) {
 	}
 }
 
-// create task and start
-func startDownload(ctx context.Context, channelId int64, download repository.Download) error {
+// create task and start with account
+func startDownload(ctx context.Context, account *source.Account, channelId int64, download repository.Download) error {
 	ctx, cancel := context.WithTimeout(context.Background(), time.Second*10)
 	defer cancel()
 
 	downloadRepo := database.BeginRepository[repository.DownloadRepository]()
 	defer downloadRepo.Rollback()
 
-	if !download.Downloading {
-		if err := downloadRepo.SetDownloading(download.ID); err != nil {
+	if !download.Downloading || download.Account != account.Name {
+		if err := downloadRepo.SetDownloading(download.ID, account.Name); err != nil {
 			return err
 		}
 	}
@@ -65,8 +53,9 @@ func startDownload(ctx context.Context, channelId int64, download repository.Dow
 		return err
 	}
 
-	task, err := NewTask(ctx, channelId, download)
+	task, err := NewTask(ctx, account, channelId, download)
 	queue.Store(download.ID, task)
+	PublishTaskEvent(TaskEvent{Type: TaskEventStarted, ID: download.ID, File: task.File()})
 	if err != nil {
 		return err
 	}
@@ -74,7 +63,28 @@ func startDownload(ctx context.Context, channelId int64, download repository.Dow
 	return nil
 }
 
-func ScanAndCreateNewDownloadTasks(processBefore *int64, channelId ...int64) (int, error) {
+// scanAccount returns the ready account scanning the item, items of accounts removed from config are bound to another one
+func scanAccount(ctx context.Context, itemRepo repository.ItemRepository, item *repository.Item) (*source.Account, error) {
+	account, err := source.GetReadyAccount(item.Account)
+	if !errors.Is(err, source.ErrAccountNotFound) {
+		return account, err
+	}
+	account, err = source.AccountForChannel(ctx, item.ChannelID, "")
+	if err != nil {
+		return nil, err
+	}
+	if err := itemRepo.UpdateAccount(item.ID, account.Name); err != nil {
+		return nil, err
+	}
+	item.Account = account.Name
+	return account, nil
+}
+
+// ScanAndCreateNewDownloadTasks scans items of the account, or items of all ready accounts if account is nil.
+// Items of accounts removed from config are scanned along with the default account.
+func ScanAndCreateNewDownloadTasks(account *source.Account, processBefore *int64, channelId ...int64) (int, error) {
+	defer prometheus.NewTimer(metrics.ScanDuration).ObserveDuration()
+
 	itemRepo := database.BeginRepository[repository.ItemRepository]()
 	defer itemRepo.Rollback()
 
@@ -82,37 +92,62 @@ func ScanAndCreateNewDownloadTasks(processBefore *int64, channelId ...int64) (in
 
 	logger := logfield.New(logfield.ComQueue).WithAction("add downloads with message")
 
-	items, err := itemRepo.GetForUpdates(int32(time.Now().Add(-time.Duration(config.Telegram.Get().ScanThresholdDays)*time.Hour*24).Unix()), channelId...)
+	var accounts, exceptAccounts []string
+	if account != nil && account != source.Accounts[0] {
+		accounts = []string{account.Name}
+	} else if account != nil {
+		for _, other := range source.Accounts[1:] {
+			exceptAccounts = append(exceptAccounts, other.Name)
+		}
+	}
+
+	items, err := itemRepo.GetForUpdates(int32(time.Now().Add(-time.Duration(config.Telegram.Get().ScanThresholdDays)*time.Hour*24).Unix()), accounts, exceptAccounts, channelId...)
 	if err != nil {
 		return 0, err
 	}
 
 	var created int
+	var queuedIDs []uint
 	for _, item := range items {
 		if processBefore != nil && item.Process >= *processBefore {
 			continue
 		}
 
 		logger := logger.WithField("item", item.Name)
+
+		// a failed statement aborts the whole transaction of postgres until rolled back to a savepoint
 		savepoint := fmt.Sprintf("sp%d", item.ID)
+		if err := itemRepo.DB.SavePoint(savepoint).Error; err != nil {
+			logger.Errorln("save transaction point failed:", err)
+			continue
+		}
+
+		itemAccount, err := scanAccount(context.Background(), itemRepo, &item)
+		if err != nil {
+			logger.Debugln("skipped, no account to scan:", err)
+			itemRepo.DB.RollbackTo(savepoint)
+			continue
+		}
+
 		var latest *client.Message
 		var fromMessageID int64 = 0
 
-		itemRegexp, err := regexp.Compile(item.Regexp)
+		matcher, err := NewMatcher(&item)
 		if err != nil {
-			logger.Errorf("compile regexp '%s' failed: %v", item.Regexp, err)
+			logger.Errorln("create matcher failed:", err)
 			continue
 		}
 
-		if err := itemRepo.DB.SavePoint(savepoint).Error; err != nil {
-			logger.Errorln("save transaction point failed:", err)
+		companionMatchers, err := CompileCompanionRules(item.CompanionRules)
+		if err != nil {
+			logger.Errorln("compile companion rules failed:", err)
 			continue
 		}
 
 		// fetch all new messages, list => *client.Message
 		messageList := list.New()
 	fetchMessage:
-		messages, err := source.Telegram.GetHistory(context.Background(), item.ChannelID, fromMessageID, 99)
+		messages, err := itemAccount.Telegram.GetHistory(context.Background(), item.ChannelID, fromMessageID, 99)
 		if err != nil {
 			logger.Errorf("get chat %d history failed: %v", item.ChannelID, err)
 			continue
@@ -149,11 +184,15 @@ func ScanAndCreateNewDownloadTasks(processBefore *int64, channelId ...int64) (in
 		// match messages
 		el := messageList.Front()
 		newDateEnd := item.DateEnd
+		var documents []*client.Message
 		for el != nil {
 			next := el.Next()
 			msg := el.Value.(*client.Message)
 			videoContent, ok := msg.Content.(*client.MessageVideo)
-			if !ok || tools.ConvertPatternRegexp(videoContent.Caption.Text, itemRegexp, item.MatchPattern) != item.MatchContent {
+			if !ok || !matcher.Match(videoContent) {
+				if len(companionMatchers) != 0 && msg.Content.MessageContentType() == client.TypeMessageDocument {
+					documents = append(documents, msg)
+				}
 				messageList.Remove(el)
 			} else if msg.Date > newDateEnd {
 				newDateEnd = msg.Date
@@ -162,6 +201,7 @@ func ScanAndCreateNewDownloadTasks(processBefore *int64, channelId ...int64) (in
 		}
 
 		// create download models
+		var downloads []repository.Download
 		if messageList.Len() > 0 {
 			if newDateEnd != item.DateEnd {
 				if err := itemRepo.UpdateDateEnd(item.ID, newDateEnd); err != nil {
@@ -171,18 +211,39 @@ func ScanAndCreateNewDownloadTasks(processBefore *int64, channelId ...int64) (in
 				}
 			}
 
-			created += messageList.Len()
 			messages := make([]*client.Message, 0, messageList.Len())
 			for el := messageList.Front(); el != nil; el = el.Next() {
 				messages = append(messages, el.Value.(*client.Message))
 			}
-			if _, err := downloadRepo.CreateWithMessages(item.ID, item.Priority, messages); err != nil {
+			if downloads, err = downloadRepo.CreateWithMessages(item.ID, item.Priority, messages); err != nil {
 				logger.Errorln("save download tasks to database failed:", err)
 				itemRepo.DB.RollbackTo(savepoint)
 				continue
 			}
 		}
+
+		// attach companions to downloads
+		if len(documents) != 0 {
+			companions, err := matchCompanions(itemRepo.Repository, itemAccount, &item, companionMatchers, documents)
+			if err != nil {
+				logger.Errorln("save companions to database failed:", err)
+				itemRepo.DB.RollbackTo(savepoint)
+				continue
+			}
+			logger.Debugf("%d companions attached", companions)
+		}
+
+		// counted once all inserts of the item succeed
+		created += len(downloads)
+		for _, download := range downloads {
+			queuedIDs = append(queuedIDs, download.ID)
+		}
 	}
 
-	return created, itemRepo.Commit().Error
+	if err := itemRepo.Commit().Error; err != nil {
+		return 0, err
+	}
+	PublishTasksQueued(queuedIDs...)
+	metrics.ScanMatches.Add(float64(created))
+	return created, nil
 }
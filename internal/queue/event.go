@@ -0,0 +1,153 @@
+package queue
+
+import (
+	"sync"
+	"time"
+
+	"github.com/zelenin/go-tdlib/client"
+)
+
+const (
+	TaskEventQueued    = "queued"
+	TaskEventStarted   = "started"
+	TaskEventProgress  = "progress"
+	TaskEventCompleted = "completed"
+	TaskEventError     = "error"
+	TaskEventFatal     = "fatal"
+	TaskEventRemoved   = "removed"
+)
+
+// progress events of a task are sent at most once in the interval
+const taskProgressInterval = time.Second
+
+type TaskEvent struct {
+	Type string `json:"type"`
+	ID   uint   `json:"id"`
+	At   int64  `json:"at"`
+
+	File  *client.File `json:"file,omitempty"`
+	Path  string       `json:"path,omitempty"`
+	Error string       `json:"error,omitempty"`
+}
+
+type _TaskEvents struct {
+	lock        sync.RWMutex
+	subscribers map[*taskEventSubscriber]struct{}
+
+	// Download.ID => time.Time
+	progressAt sync.Map
+}
+
+var taskEvents = &_TaskEvents{
+	subscribers: make(map[*taskEventSubscriber]struct{}),
+}
+
+// taskEventSubscriber queues events until the receiver takes them,
+// a progress event not taken yet is replaced by the next progress event of the same task
+type taskEventSubscriber struct {
+	ch     chan TaskEvent
+	notify chan struct{}
+	done   chan struct{}
+
+	lock  sync.Mutex
+	queue []TaskEvent
+	// Download.ID => index in queue of its progress event
+	progress map[uint]int
+}
+
+func (s *taskEventSubscriber) push(event TaskEvent) {
+	s.lock.Lock()
+	if i, ok := s.progress[event.ID]; ok && event.Type == TaskEventProgress {
+		s.queue[i] = event
+	} else {
+		if event.Type == TaskEventProgress {
+			s.progress[event.ID] = len(s.queue)
+		} else {
+			// later progress events must not be moved before this one
+			delete(s.progress, event.ID)
+		}
+		s.queue = append(s.queue, event)
+	}
+	s.lock.Unlock()
+
+	select {
+	case s.notify <- struct{}{}:
+	default:
+	}
+}
+
+func (s *taskEventSubscriber) run() {
+	defer close(s.ch)
+	for {
+		select {
+		case <-s.notify:
+		case <-s.done:
+			return
+		}
+
+		s.lock.Lock()
+		queue := s.queue
+		s.queue, s.progress = nil, make(map[uint]int)
+		s.lock.Unlock()
+
+		for _, event := range queue {
+			select {
+			case s.ch <- event:
+			case <-s.done:
+				return
+			}
+		}
+	}
+}
+
+// SubscribeTaskEvents returns a channel receiving events in order, state events are never dropped,
+// progress events of a task are coalesced while the receiver is too slow
+func SubscribeTaskEvents() (<-chan TaskEvent, func()) {
+	s := &taskEventSubscriber{
+		ch:       make(chan TaskEvent, 256),
+		notify:   make(chan struct{}, 1),
+		done:     make(chan struct{}),
+		progress: make(map[uint]int),
+	}
+	go s.run()
+
+	taskEvents.lock.Lock()
+	taskEvents.subscribers[s] = struct{}{}
+	taskEvents.lock.Unlock()
+
+	var once sync.Once
+	return s.ch, func() {
+		once.Do(func() {
+			taskEvents.lock.Lock()
+			delete(taskEvents.subscribers, s)
+			taskEvents.lock.Unlock()
+			close(s.done)
+		})
+	}
+}
+
+func PublishTaskEvent(event TaskEvent) {
+	now := time.Now()
+	switch event.Type {
+	case TaskEventProgress:
+		if at, ok := taskEvents.progressAt.Load(event.ID); ok && now.Sub(at.(time.Time)) < taskProgressInterval {
+			return
+		}
+		taskEvents.progressAt.Store(event.ID, now)
+	case TaskEventCompleted, TaskEventFatal, TaskEventRemoved:
+		taskEvents.progressAt.Delete(event.ID)
+	}
+	event.At = now.Unix()
+
+	taskEvents.lock.RLock()
+	defer taskEvents.lock.RUnlock()
+	for s := range taskEvents.subscribers {
+		s.push(event)
+	}
+}
+
+func PublishTasksQueued(ids ...uint) {
+	for _, id := range ids {
+		PublishTaskEvent(TaskEvent{Type: TaskEventQueued, ID: id})
+	}
+}
@@ -0,0 +1,47 @@
+package queue
+
+import (
+	"testing"
+	"time"
+)
+
+func TestTaskEventsSlowSubscriber(t *testing.T) {
+	events, unsubscribe := SubscribeTaskEvents()
+	defer unsubscribe()
+
+	// fill far beyond the channel buffer before receiving anything
+	const tasks = 1000
+	for id := uint(1); id <= tasks; id++ {
+		PublishTaskEvent(TaskEvent{Type: TaskEventStarted, ID: id})
+	}
+	for id := uint(1); id <= tasks; id++ {
+		PublishTaskEvent(TaskEvent{Type: TaskEventCompleted, ID: id})
+	}
+
+	timeout := time.After(5 * time.Second)
+	for want := 0; want < 2*tasks; want++ {
+		select {
+		case event := <-events:
+			wantType, wantID := TaskEventStarted, uint(want+1)
+			if want >= tasks {
+				wantType, wantID = TaskEventCompleted, uint(want-tasks+1)
+			}
+			if event.Type != wantType || event.ID != wantID {
+				t.Fatalf("event %d: got %s %d, want %s %d", want, event.Type, event.ID, wantType, wantID)
+			}
+		case <-timeout:
+			t.Fatalf("received %d of %d events", want, 2*tasks)
+		}
+	}
+}
+
+func TestTaskEventsCoalesceProgress(t *testing.T) {
+	s := &taskEventSubscriber{progress: make(map[uint]int), notify: make(chan struct{}, 1)}
+	s.push(TaskEvent{Type: TaskEventProgress, ID: 1, At: 1})
+	s.push(TaskEvent{Type: TaskEventProgress, ID: 1, At: 2})
+	s.push(TaskEvent{Type: TaskEventCompleted, ID: 1})
+	s.push(TaskEvent{Type: TaskEventProgress, ID: 1, At: 3})
+	if len(s.queue) != 3 || s.queue[0].At != 2 || s.queue[1].Type != TaskEventCompleted || s.queue[2].At != 3 {
+		t.Fatalf("unexpected queue: %+v", s.queue)
+	}
+}
@@ -0,0 +1,233 @@
+package queue
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/acgn-org/onest/repository"
+	"gorm.io/gorm"
+)
+
+var ErrItemsNotFound = errors.New("some of the items not found")
+
+// ExportItems exports items of ids, or all items if ids is empty
+func ExportItems(itemRepo repository.ItemRepository, ids []uint, withDownloads bool) (*repository.ItemExport, error) {
+	items, err := itemRepo.GetForExport(ids...)
+	if err != nil {
+		return nil, err
+	} else if len(ids) != 0 && len(items) != len(ids) {
+		return nil, ErrItemsNotFound
+	}
+
+	var downloadsOfItem = make(map[uint][]repository.Download)
+	if withDownloads && len(items) != 0 {
+		var itemIDs = make([]uint, len(items))
+		for i, item := range items {
+			itemIDs[i] = item.ID
+		}
+		downloadRepo := repository.DownloadRepository{Repository: itemRepo.Repository}
+		downloads, err := downloadRepo.GetByItemIDs(itemIDs...)
+		if err != nil {
+			return nil, err
+		}
+		for _, download := range downloads {
+			downloadsOfItem[download.ItemID] = append(downloadsOfItem[download.ItemID], download)
+		}
+	}
+
+	export := repository.ItemExport{
+		Version:    repository.ItemExportVersion,
+		ExportedAt: time.Now().Unix(),
+		Items:      make([]repository.ItemDefinition, len(items)),
+	}
+	for i, item := range items {
+		export.Items[i] = repository.NewItemDefinition(&item, downloadsOfItem[item.ID])
+	}
+	return &export, nil
+}
+
+type ItemImportResult struct {
+	Index  int    `json:"index"`
+	Name   string `json:"name"`
+	Action string `json:"action"`
+	ItemID uint   `json:"item_id,omitempty"`
+	// number of downloads created
+	Downloads int64  `json:"downloads"`
+	Error     string `json:"error,omitempty"`
+}
+
+type ItemImportReport struct {
+	DryRun   bool               `json:"dry_run"`
+	Imported bool               `json:"imported"`
+	Items    []ItemImportResult `json:"items"`
+}
+
+// ItemIDs returns ids of items created or changed
+func (report *ItemImportReport) ItemIDs() []uint {
+	var ids = make([]uint, 0, len(report.Items))
+	for _, result := range report.Items {
+		if result.Action != repository.ImportConflictSkip {
+			ids = append(ids, result.ItemID)
+		}
+	}
+	return ids
+}
+
+// uniqueItemName appends a number to name until no item of the channel uses it, nor names in taken
+func uniqueItemName(itemRepo repository.ItemRepository, channelID int64, name string, taken map[string]struct{}) (string, error) {
+	names, err := itemRepo.GetNamesByChannelAndPrefix(channelID, name)
+	if err != nil {
+		return "", err
+	}
+	var used = make(map[string]struct{}, len(names)+len(taken))
+	for _, name := range names {
+		used[name] = struct{}{}
+	}
+	for name := range taken {
+		used[name] = struct{}{}
+	}
+	for i := 2; ; i++ {
+		candidate := fmt.Sprintf("%s (%d)", name, i)
+		if _, ok := used[candidate]; !ok {
+			return candidate, nil
+		}
+	}
+}
+
+// NewItemImportReport checks version and every item of export, ok is false if any item is invalid
+func NewItemImportReport(export *repository.ItemExport, dryRun bool) (report *ItemImportReport, ok bool, err error) {
+	if export.Version > repository.ItemExportVersion {
+		return nil, false, fmt.Errorf("unsupported export version %d", export.Version)
+	}
+
+	report = &ItemImportReport{
+		DryRun: dryRun,
+		Items:  make([]ItemImportResult, len(export.Items)),
+	}
+	ok = true
+	for i, definition := range export.Items {
+		report.Items[i] = ItemImportResult{Index: i, Name: definition.Name}
+		item := definition.Item()
+		if err := ValidateItem(&item); err != nil {
+			report.Items[i].Action = "invalid"
+			report.Items[i].Error = err.Error()
+			ok = false
+		}
+	}
+	return report, ok, nil
+}
+
+// ImportItems imports items of export in the transaction of itemRepo, results are written to report.
+// A dry run only reads, see planImportItems.
+func ImportItems(itemRepo repository.ItemRepository, export *repository.ItemExport, conflict string, report *ItemImportReport) error {
+	if report.DryRun {
+		return planImportItems(itemRepo, export, conflict, report)
+	}
+
+	downloadRepo := repository.DownloadRepository{Repository: itemRepo.Repository}
+
+	for i, definition := range export.Items {
+		result := &report.Items[i]
+		item := definition.Item()
+
+		existing, err := itemRepo.FirstByChannelAndNameForUpdates(item.ChannelID, item.Name)
+		if err == nil {
+			switch conflict {
+			case repository.ImportConflictSkip:
+				result.Action = repository.ImportConflictSkip
+				result.ItemID = existing.ID
+				continue
+			case repository.ImportConflictOverwrite:
+				item.ID = existing.ID
+				err = itemRepo.UpdateDefinition(&item)
+			case repository.ImportConflictRename:
+				item.Name, err = uniqueItemName(itemRepo, item.ChannelID, item.Name, nil)
+				if err == nil {
+					err = itemRepo.Create(&item)
+				}
+			}
+			result.Action = conflict
+		} else if errors.Is(err, gorm.ErrRecordNotFound) {
+			err = itemRepo.Create(&item)
+			result.Action = "create"
+		}
+		if err != nil {
+			return err
+		}
+		result.ItemID = item.ID
+		result.Name = item.Name
+
+		result.Downloads, err = downloadRepo.CreateAllSkipExisting(definition.DownloadModels(item.ID))
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// planImportItems reports what ImportItems would do without writing or locking, items to be created have no id
+func planImportItems(itemRepo repository.ItemRepository, export *repository.ItemExport, conflict string, report *ItemImportReport) error {
+	downloadRepo := repository.DownloadRepository{Repository: itemRepo.Repository}
+
+	// names of items to be created by channel, they are conflicts of later definitions
+	var planned = make(map[int64]map[string]struct{})
+	for i, definition := range export.Items {
+		result := &report.Items[i]
+		item := definition.Item()
+
+		var existingID uint
+		_, exists := planned[item.ChannelID][item.Name]
+		if !exists {
+			existing, err := itemRepo.FirstByChannelAndName(item.ChannelID, item.Name)
+			if err == nil {
+				existingID, exists = existing.ID, true
+			} else if !errors.Is(err, gorm.ErrRecordNotFound) {
+				return err
+			}
+		}
+
+		result.Action = "create"
+		if exists {
+			result.Action = conflict
+			switch conflict {
+			case repository.ImportConflictSkip:
+				result.ItemID = existingID
+				continue
+			case repository.ImportConflictRename:
+				var err error
+				item.Name, err = uniqueItemName(itemRepo, item.ChannelID, item.Name, planned[item.ChannelID])
+				if err != nil {
+					return err
+				}
+				existingID = 0
+			}
+		}
+		result.ItemID = existingID
+		result.Name = item.Name
+		if existingID == 0 {
+			if planned[item.ChannelID] == nil {
+				planned[item.ChannelID] = make(map[string]struct{})
+			}
+			planned[item.ChannelID][item.Name] = struct{}{}
+		}
+
+		var msgIDs = make([]int64, 0, len(definition.Downloads))
+		var seen = make(map[int64]struct{}, len(definition.Downloads))
+		for _, download := range definition.Downloads {
+			if _, ok := seen[download.MsgID]; !ok {
+				seen[download.MsgID] = struct{}{}
+				msgIDs = append(msgIDs, download.MsgID)
+			}
+		}
+		result.Downloads = int64(len(msgIDs))
+		if existingID != 0 {
+			added, err := downloadRepo.CountByItemIDAndMsgIDs(existingID, msgIDs)
+			if err != nil {
+				return err
+			}
+			result.Downloads -= added
+		}
+	}
+	return nil
+}
@@ -0,0 +1,145 @@
+package queue
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/acgn-org/onest/repository"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+func newTestItemRepository(t *testing.T) repository.ItemRepository {
+	t.Helper()
+	db, err := gorm.Open(sqlite.Open(filepath.Join(t.TempDir(), "onest.sqlite")), &gorm.Config{
+		Logger: logger.Default.LogMode(logger.Silent),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := repository.AutoMigrate(db); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		if sqlDB, err := db.DB(); err == nil {
+			_ = sqlDB.Close()
+		}
+	})
+	return repository.ItemRepository{Repository: repository.Repository{DB: db}}
+}
+
+func testItemDefinition(name string, msgIDs ...int64) repository.ItemDefinition {
+	definition := repository.ItemDefinition{
+		ChannelID:    1,
+		Name:         name,
+		Regexp:       `(\d+)`,
+		Pattern:      "E$1",
+		MatchPattern: "$1",
+		MatchContent: "01",
+		DateEnd:      100,
+		Priority:     1,
+		TargetPath:   "/target",
+	}
+	for _, msgID := range msgIDs {
+		definition.Downloads = append(definition.Downloads, repository.DownloadDefinition{MsgID: msgID, Priority: 1})
+	}
+	return definition
+}
+
+func TestImportItemsDryRun(t *testing.T) {
+	itemRepo := newTestItemRepository(t)
+	existing := testItemDefinition("a", 1).Item()
+	if err := itemRepo.Create(&existing); err != nil {
+		t.Fatal(err)
+	}
+	downloadRepo := repository.DownloadRepository{Repository: itemRepo.Repository}
+	if _, err := downloadRepo.CreateAllSkipExisting(testItemDefinition("a", 1).DownloadModels(existing.ID)); err != nil {
+		t.Fatal(err)
+	}
+
+	export := repository.ItemExport{
+		Version: repository.ItemExportVersion,
+		Items: []repository.ItemDefinition{
+			testItemDefinition("a", 1, 2),
+			testItemDefinition("b", 1, 1),
+			testItemDefinition("b"),
+		},
+	}
+	for conflict, want := range map[string][]ItemImportResult{
+		repository.ImportConflictSkip: {
+			{Index: 0, Name: "a", Action: "skip", ItemID: existing.ID},
+			{Index: 1, Name: "b", Action: "create", Downloads: 1},
+			{Index: 2, Name: "b", Action: "skip"},
+		},
+		repository.ImportConflictOverwrite: {
+			{Index: 0, Name: "a", Action: "overwrite", ItemID: existing.ID, Downloads: 1},
+			{Index: 1, Name: "b", Action: "create", Downloads: 1},
+			{Index: 2, Name: "b", Action: "overwrite"},
+		},
+		repository.ImportConflictRename: {
+			{Index: 0, Name: "a (2)", Action: "rename", Downloads: 2},
+			{Index: 1, Name: "b", Action: "create", Downloads: 1},
+			{Index: 2, Name: "b (2)", Action: "rename"},
+		},
+	} {
+		report, ok, err := NewItemImportReport(&export, true)
+		if err != nil || !ok {
+			t.Fatalf("%s: got %v, %v", conflict, ok, err)
+		}
+		if err := ImportItems(itemRepo, &export, conflict, report); err != nil {
+			t.Fatalf("%s: %v", conflict, err)
+		}
+		for i, result := range report.Items {
+			if result != want[i] {
+				t.Errorf("%s: item %d got %+v, want %+v", conflict, i, result, want[i])
+			}
+		}
+	}
+
+	var items, downloads int64
+	if err := itemRepo.DB.Model(&repository.Item{}).Count(&items).Error; err != nil {
+		t.Fatal(err)
+	}
+	if err := itemRepo.DB.Model(&repository.Download{}).Count(&downloads).Error; err != nil {
+		t.Fatal(err)
+	}
+	if items != 1 || downloads != 1 {
+		t.Errorf("dry run wrote %d items and %d downloads, want 1 and 1", items, downloads)
+	}
+}
+
+func TestImportItemsOverwriteKeepsState(t *testing.T) {
+	itemRepo := newTestItemRepository(t)
+	existing := testItemDefinition("a").Item()
+	existing.Process = 500
+	existing.DateEnd = 200
+	existing.Account = "backup"
+	if err := itemRepo.Create(&existing); err != nil {
+		t.Fatal(err)
+	}
+
+	definition := testItemDefinition("a")
+	definition.Pattern = "S01E$1"
+	definition.Process = 10
+	definition.Account = ""
+	export := repository.ItemExport{Version: repository.ItemExportVersion, Items: []repository.ItemDefinition{definition}}
+	report, ok, err := NewItemImportReport(&export, false)
+	if err != nil || !ok {
+		t.Fatalf("got %v, %v", ok, err)
+	}
+	if err := ImportItems(itemRepo, &export, repository.ImportConflictOverwrite, report); err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := itemRepo.FirstItemByID(existing.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Pattern != "S01E$1" {
+		t.Errorf("got pattern %q, want it overwritten", got.Pattern)
+	}
+	if got.Process != 500 || got.DateEnd != 200 || got.Account != "backup" {
+		t.Errorf("state is overwritten: process %d, date end %d, account %q", got.Process, got.DateEnd, got.Account)
+	}
+}
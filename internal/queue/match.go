@@ -0,0 +1,170 @@
+package queue
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/acgn-org/onest/repository"
+	"github.com/acgn-org/onest/tools"
+	"github.com/zelenin/go-tdlib/client"
+)
+
+type matchCondition struct {
+	repository.MatchCondition
+	regexp *regexp.Regexp
+}
+
+func (c matchCondition) inRange(value int64) bool {
+	return value >= c.Min && (c.Max == 0 || value <= c.Max)
+}
+
+func (c matchCondition) Match(video *client.MessageVideo) bool {
+	switch c.Type {
+	case repository.MatchCaption:
+		return c.regexp.MatchString(video.Caption.Text)
+	case repository.MatchFileName:
+		return c.regexp.MatchString(video.Video.FileName)
+	case repository.MatchHashtag:
+		for _, field := range strings.Fields(video.Caption.Text) {
+			if strings.HasPrefix(field, "#") && c.regexp.MatchString(strings.TrimPrefix(field, "#")) {
+				return true
+			}
+		}
+		return false
+	case repository.MatchSize:
+		return c.inRange(video.Video.Video.Size)
+	case repository.MatchDuration:
+		return c.inRange(int64(video.Video.Duration))
+	case repository.MatchResolution:
+		return c.inRange(int64(video.Video.Height))
+	default:
+		return false
+	}
+}
+
+func compileMatchConditions(conditions []repository.MatchCondition) ([]matchCondition, error) {
+	compiled := make([]matchCondition, len(conditions))
+	for i, condition := range conditions {
+		compiled[i].MatchCondition = condition
+		switch condition.Type {
+		case repository.MatchCaption, repository.MatchFileName, repository.MatchHashtag:
+			reg, err := regexp.Compile(condition.Regexp)
+			if err != nil {
+				return nil, fmt.Errorf("compile regexp of %s condition %d failed: %w", condition.Type, i, err)
+			}
+			compiled[i].regexp = reg
+		case repository.MatchSize, repository.MatchDuration, repository.MatchResolution:
+			if condition.Max != 0 && condition.Max < condition.Min {
+				return nil, fmt.Errorf("%s condition %d has max less than min", condition.Type, i)
+			}
+		default:
+			return nil, fmt.Errorf("unknown condition type '%s'", condition.Type)
+		}
+	}
+	return compiled, nil
+}
+
+// Matcher decides whether a video message belongs to the item
+type Matcher struct {
+	regexp       *regexp.Regexp
+	matchPattern string
+	matchContent string
+
+	orMode  bool
+	include []matchCondition
+	exclude []matchCondition
+}
+
+func NewMatcher(item *repository.Item) (*Matcher, error) {
+	itemRegexp, err := regexp.Compile(item.Regexp)
+	if err != nil {
+		return nil, fmt.Errorf("compile regexp '%s' failed: %w", item.Regexp, err)
+	}
+
+	matcher := Matcher{
+		regexp:       itemRegexp,
+		matchPattern: item.MatchPattern,
+		matchContent: item.MatchContent,
+	}
+	if item.MatchRules != nil {
+		if err := matcher.setRules(item.MatchRules); err != nil {
+			return nil, err
+		}
+	}
+	return &matcher, nil
+}
+
+// CompileMatchRules validates rules without an item
+func CompileMatchRules(rules *repository.MatchRules) error {
+	if rules == nil {
+		return nil
+	}
+	var matcher Matcher
+	return matcher.setRules(rules)
+}
+
+func (m *Matcher) setRules(rules *repository.MatchRules) error {
+	var err error
+	switch rules.Mode {
+	case "", repository.MatchModeAnd:
+	case repository.MatchModeOr:
+		m.orMode = true
+	default:
+		return fmt.Errorf("unknown match mode '%s'", rules.Mode)
+	}
+	if m.include, err = compileMatchConditions(rules.Include); err != nil {
+		return err
+	}
+	if m.exclude, err = compileMatchConditions(rules.Exclude); err != nil {
+		return err
+	}
+	return nil
+}
+
+func (m *Matcher) Match(video *client.MessageVideo) bool {
+	if tools.ConvertPatternRegexp(video.Caption.Text, m.regexp, m.matchPattern) != m.matchContent {
+		return false
+	}
+
+	for _, condition := range m.exclude {
+		if condition.Match(video) {
+			return false
+		}
+	}
+
+	if len(m.include) == 0 {
+		return true
+	}
+	for _, condition := range m.include {
+		if condition.Match(video) {
+			if m.orMode {
+				return true
+			}
+		} else if !m.orMode {
+			return false
+		}
+	}
+	return !m.orMode
+}
+
+// ValidateItem checks every regexp, pattern and rule of the item
+func ValidateItem(item *repository.Item) error {
+	matcher, err := NewMatcher(item)
+	if err != nil {
+		return err
+	}
+	if err := tools.CheckPattern(matcher.regexp, item.Pattern); err != nil {
+		return err
+	}
+	if err := tools.CheckPattern(matcher.regexp, item.MatchPattern); err != nil {
+		return err
+	}
+	if item.EpisodePattern != "" {
+		if err := tools.CheckPattern(matcher.regexp, item.EpisodePattern); err != nil {
+			return err
+		}
+	}
+	_, err = CompileCompanionRules(item.CompanionRules)
+	return err
+}
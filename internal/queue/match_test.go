@@ -0,0 +1,103 @@
+package queue
+
+import (
+	"testing"
+
+	"github.com/acgn-org/onest/repository"
+	"github.com/zelenin/go-tdlib/client"
+)
+
+func testVideo(caption, fileName string, size int64, duration, height int32) *client.MessageVideo {
+	return &client.MessageVideo{
+		Caption: &client.FormattedText{Text: caption},
+		Video: &client.Video{
+			FileName: fileName,
+			Duration: duration,
+			Height:   height,
+			Video:    &client.File{Size: size},
+		},
+	}
+}
+
+func TestMatcher(t *testing.T) {
+	const mb = 1 << 20
+	item := repository.Item{
+		Regexp:       `^\[(\w+)\]`,
+		MatchPattern: "$1",
+		MatchContent: "Sub",
+	}
+	for _, c := range []struct {
+		name  string
+		rules *repository.MatchRules
+		video *client.MessageVideo
+		want  bool
+	}{
+		{"pattern only", nil, testVideo("[Sub] 01", "01.mp4", mb, 60, 720), true},
+		{"pattern mismatch", nil, testVideo("[Raw] 01", "01.mp4", mb, 60, 720), false},
+		{"and include", &repository.MatchRules{Include: []repository.MatchCondition{
+			{Type: repository.MatchFileName, Regexp: `\.mkv$`},
+			{Type: repository.MatchResolution, Min: 1080},
+		}}, testVideo("[Sub] 01", "01.mkv", mb, 60, 720), false},
+		{"or include", &repository.MatchRules{Mode: repository.MatchModeOr, Include: []repository.MatchCondition{
+			{Type: repository.MatchFileName, Regexp: `\.mkv$`},
+			{Type: repository.MatchResolution, Min: 1080},
+		}}, testVideo("[Sub] 01", "01.mkv", mb, 60, 720), true},
+		{"or include none", &repository.MatchRules{Mode: repository.MatchModeOr, Include: []repository.MatchCondition{
+			{Type: repository.MatchFileName, Regexp: `\.mkv$`},
+		}}, testVideo("[Sub] 01", "01.mp4", mb, 60, 720), false},
+		{"exclude hashtag", &repository.MatchRules{Exclude: []repository.MatchCondition{
+			{Type: repository.MatchHashtag, Regexp: `^preview$`},
+		}}, testVideo("[Sub] 01 #preview", "01.mp4", mb, 60, 720), false},
+		{"exclude hashtag in word", &repository.MatchRules{Exclude: []repository.MatchCondition{
+			{Type: repository.MatchHashtag, Regexp: `^preview$`},
+		}}, testVideo("[Sub] 01 no#preview", "01.mp4", mb, 60, 720), true},
+		{"size range", &repository.MatchRules{Include: []repository.MatchCondition{
+			{Type: repository.MatchSize, Min: mb, Max: 2 * mb},
+		}}, testVideo("[Sub] 01", "01.mp4", 2*mb, 60, 720), true},
+		{"size unlimited max", &repository.MatchRules{Include: []repository.MatchCondition{
+			{Type: repository.MatchSize, Min: 2 * mb},
+		}}, testVideo("[Sub] 01", "01.mp4", 3*mb, 60, 720), true},
+		{"duration too short", &repository.MatchRules{Include: []repository.MatchCondition{
+			{Type: repository.MatchDuration, Min: 600},
+		}}, testVideo("[Sub] 01", "01.mp4", mb, 60, 720), false},
+		{"caption excluded over include", &repository.MatchRules{
+			Include: []repository.MatchCondition{{Type: repository.MatchCaption, Regexp: `01`}},
+			Exclude: []repository.MatchCondition{{Type: repository.MatchCaption, Regexp: `(?i)trailer`}},
+		}, testVideo("[Sub] 01 Trailer", "01.mp4", mb, 60, 720), false},
+	} {
+		item := item
+		item.MatchRules = c.rules
+		matcher, err := NewMatcher(&item)
+		if err != nil {
+			t.Fatalf("%s: %v", c.name, err)
+		}
+		if got := matcher.Match(c.video); got != c.want {
+			t.Errorf("%s: got %v, want %v", c.name, got, c.want)
+		}
+	}
+}
+
+func TestCompileMatchRules(t *testing.T) {
+	for _, c := range []struct {
+		name  string
+		rules *repository.MatchRules
+		ok    bool
+	}{
+		{"nil", nil, true},
+		{"empty", &repository.MatchRules{}, true},
+		{"unknown mode", &repository.MatchRules{Mode: "xor"}, false},
+		{"invalid regexp", &repository.MatchRules{Include: []repository.MatchCondition{
+			{Type: repository.MatchCaption, Regexp: `(`},
+		}}, false},
+		{"max less than min", &repository.MatchRules{Exclude: []repository.MatchCondition{
+			{Type: repository.MatchSize, Min: 2, Max: 1},
+		}}, false},
+		{"unknown type", &repository.MatchRules{Include: []repository.MatchCondition{
+			{Type: "audio"},
+		}}, false},
+	} {
+		if err := CompileMatchRules(c.rules); (err == nil) != c.ok {
+			t.Errorf("%s: got error %v", c.name, err)
+		}
+	}
+}
@@ -0,0 +1,71 @@
+package queue
+
+import (
+	"context"
+	"strconv"
+	"time"
+
+	"github.com/acgn-org/onest/internal/database"
+	"github.com/acgn-org/onest/internal/logfield"
+	"github.com/acgn-org/onest/internal/metrics"
+	"github.com/acgn-org/onest/internal/source"
+	"github.com/acgn-org/onest/repository"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var (
+	queueLengthDesc = prometheus.NewDesc("onest_queue_length",
+		"Download tasks in queue.", nil, nil)
+	queueTasksDesc = prometheus.NewDesc("onest_queue_tasks",
+		"Download tasks in queue by telegram account.", []string{"account"}, nil)
+	activeDownloadsDesc = prometheus.NewDesc("onest_downloads_active",
+		"Tasks in queue with file downloading by TDLib.", nil, nil)
+	itemWaitingDesc = prometheus.NewDesc("onest_item_waiting_downloads",
+		"Downloads not started yet by item.", []string{"item_id", "item"}, nil)
+)
+
+// collector reads state of queue and database on scrape
+type collector struct{}
+
+func (collector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- queueLengthDesc
+	ch <- queueTasksDesc
+	ch <- activeDownloadsDesc
+	ch <- itemWaitingDesc
+}
+
+func (collector) Collect(ch chan<- prometheus.Metric) {
+	ch <- prometheus.MustNewConstMetric(queueLengthDesc, prometheus.GaugeValue, float64(queue.Len()))
+
+	var active int
+	queue.Range(func(_ uint, task *DownloadTask) bool {
+		if state := task.state.Load(); state != nil && state.File.Local.IsDownloadingActive {
+			active++
+		}
+		return true
+	})
+	ch <- prometheus.MustNewConstMetric(activeDownloadsDesc, prometheus.GaugeValue, float64(active))
+
+	running := RunningByAccount()
+	for _, account := range source.Accounts {
+		ch <- prometheus.MustNewConstMetric(queueTasksDesc, prometheus.GaugeValue, float64(running[account.Name]), account.Name)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*5)
+	defer cancel()
+	downloadRepo := database.NewRepository[repository.DownloadRepository]()
+	downloadRepo.DB = downloadRepo.DB.WithContext(ctx)
+	counts, err := downloadRepo.CountWaitingByItem()
+	if err != nil {
+		logfield.New(logfield.ComQueue).WithAction("metrics").Warnln("count waiting downloads failed:", err)
+		return
+	}
+	for _, count := range counts {
+		ch <- prometheus.MustNewConstMetric(itemWaitingDesc, prometheus.GaugeValue, float64(count.Count),
+			strconv.FormatUint(uint64(count.ItemID), 10), count.Name)
+	}
+}
+
+func init() {
+	metrics.Registry.MustRegister(collector{})
+}
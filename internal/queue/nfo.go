@@ -0,0 +1,91 @@
+package queue
+
+import (
+	"context"
+	"encoding/xml"
+	"os"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/acgn-org/onest/internal/config"
+	"github.com/acgn-org/onest/internal/source"
+	"github.com/acgn-org/onest/repository"
+	"github.com/acgn-org/onest/tools"
+	log "github.com/sirupsen/logrus"
+)
+
+// sidecarExts are files placed next to the video with the same base name, they follow the video on rename
+var sidecarExts = []string{".nfo"}
+
+func sidecarPath(videoPath, ext string) string {
+	return strings.TrimSuffix(videoPath, path.Ext(videoPath)) + ext
+}
+
+// NfoEpisode is the kodi / jellyfin compatible episode metadata
+type NfoEpisode struct {
+	XMLName       xml.Name `xml:"episodedetails"`
+	Title         string   `xml:"title"`
+	ShowTitle     string   `xml:"showtitle"`
+	OriginalTitle string   `xml:"originaltitle,omitempty"`
+	Season        string   `xml:"season,omitempty"`
+	Episode       string   `xml:"episode,omitempty"`
+	Aired         string   `xml:"aired,omitempty"`
+	Plot          string   `xml:"plot,omitempty"`
+}
+
+// NewNfoEpisode collects metadata of the episode, the message link is skipped if not available, e.g. of private channels
+func NewNfoEpisode(ctx context.Context, logger log.FieldLogger, account *source.Account, item *repository.Item, download *repository.Download, videoPath string) (*NfoEpisode, error) {
+	nfo := NfoEpisode{
+		Title:     strings.TrimSuffix(path.Base(videoPath), path.Ext(videoPath)),
+		ShowTitle: item.Name,
+		Plot:      download.Text,
+	}
+
+	if download.Date != 0 {
+		nfo.Aired = time.Unix(int64(download.Date), 0).Format(time.DateOnly)
+	}
+
+	if item.EpisodePattern != "" {
+		episode, err := tools.ConvertPatternRegexpString(download.Text, item.Regexp, item.EpisodePattern)
+		if err != nil {
+			return nil, err
+		}
+		if episode = strings.TrimSpace(episode); episode != "" {
+			nfo.Season = "1"
+			nfo.Episode = episode
+		}
+	}
+
+	if item.RealSearchID != 0 {
+		remote, err := source.RealSearch.GetTimeMachineItemRaws(item.RealSearchID)
+		if err != nil {
+			return nil, err
+		}
+		if remote.Item.NameCN != "" {
+			nfo.ShowTitle = remote.Item.NameCN
+		}
+		nfo.OriginalTitle = remote.Item.NameEN
+	}
+
+	link, err := account.Telegram.GetMessageLink(ctx, item.ChannelID, download.MsgID)
+	if err != nil {
+		logger.Debugln("skip message link of nfo:", err)
+	} else {
+		if nfo.Plot != "" {
+			nfo.Plot += "\n\n"
+		}
+		nfo.Plot += link
+	}
+
+	return &nfo, nil
+}
+
+func (nfo NfoEpisode) Write(videoPath string) error {
+	data, err := xml.MarshalIndent(nfo, "", "  ")
+	if err != nil {
+		return err
+	}
+	data = append([]byte(xml.Header), data...)
+	return os.WriteFile(sidecarPath(videoPath, ".nfo"), data, config.FilePerm)
+}
@@ -5,36 +5,116 @@ import (
 	"sync"
 	"sync/atomic"
 
-	"github.com/acgn-org/onest/internal/config"
 	"github.com/acgn-org/onest/internal/database"
 	"github.com/acgn-org/onest/internal/logfield"
 	"github.com/acgn-org/onest/internal/source"
 	"github.com/acgn-org/onest/repository"
 )
 
-func init() {
-	logger := logfield.New(logfield.ComQueue).WithAction("init")
+// Start waits for authorization of telegram accounts in background.
+// Every account resumes its downloads interrupted last time and scans its items once authorized,
+// and task control runs once the default account is authorized.
+func Start(_ context.Context) error {
+	ctx, cancel := context.WithCancel(context.Background())
+	stopSupervisor = cancel
+
+	instance := newSupervisor(ctx)
+	for _, account := range source.Accounts {
+		supervisorWorkers.Add(1)
+		go func() {
+			defer supervisorWorkers.Done()
+			if err := account.Wait(ctx); err != nil {
+				if ctx.Err() == nil {
+					releaseDownloads(account)
+				}
+				return
+			}
+			resumeDownloads(ctx, account)
+			instance.WorkerAccount(account)
+		}()
+	}
 
-	downloadRepo := database.NewRepository[repository.DownloadRepository]()
+	supervisorWorkers.Add(1)
+	go func() {
+		defer supervisorWorkers.Done()
+		if err := source.WaitTelegram(ctx); err != nil {
+			return
+		}
+		instance.Start()
+	}()
+	return nil
+}
 
-	// resume downloads
+// resumedBy reports whether the download interrupted is resumed by account,
+// downloads of accounts removed from config are resumed along with the default account
+func resumedBy(account *source.Account, download *repository.DownloadWithChannelID) bool {
+	served, err := source.GetAccount(download.Account)
+	if err != nil {
+		served = source.Accounts[0]
+	}
+	return served == account
+}
 
+func resumeDownloads(ctx context.Context, account *source.Account) {
+	logger := logfield.New(logfield.ComQueue).WithAction("init").WithField("account", account.Name)
+
+	downloadRepo := database.NewRepository[repository.DownloadRepository]()
 	downloadingSlice, err := downloadRepo.GetDownloading()
 	if err != nil {
-		logger.Fatalln("load downloading failed:", err)
+		logger.Errorln("load downloading failed:", err)
+		return
 	}
 
+	var released []uint
 	for _, repo := range downloadingSlice {
+		if !resumedBy(account, &repo) {
+			continue
+		}
+		resumeAccount := account
+		if !account.CanSee(ctx, repo.ChannelID) {
+			if resumeAccount, err = source.AccountForChannel(ctx, repo.ChannelID, repo.ItemAccount); err != nil {
+				logger.Warnf("download %d is released, no account can see channel %d", repo.ID, repo.ChannelID)
+				released = append(released, repo.ID)
+				continue
+			}
+		}
 		logger.Debugf("resuming download %d", repo.ID)
-		err := startDownload(context.Background(), repo.ChannelID, repo.Download)
+		err := startDownload(ctx, resumeAccount, repo.ChannelID, repo.Download)
 		if err != nil {
 			logger.Errorln("resume download failed:", err)
 		}
 	}
+	if len(released) != 0 {
+		if err := downloadRepo.UpdateWaitingByIDs(released...); err != nil {
+			logger.Errorln("release downloads failed:", err)
+		}
+	}
+}
 
-	// run supervisor
+// releaseDownloads lets downloads of the account failed to authorize be started by others
+func releaseDownloads(account *source.Account) {
+	logger := logfield.New(logfield.ComQueue).WithAction("init").WithField("account", account.Name)
 
-	supervisor()
+	downloadRepo := database.NewRepository[repository.DownloadRepository]()
+	downloadingSlice, err := downloadRepo.GetDownloading()
+	if err != nil {
+		logger.Errorln("load downloading failed:", err)
+		return
+	}
+	var ids []uint
+	for _, repo := range downloadingSlice {
+		if resumedBy(account, &repo) {
+			ids = append(ids, repo.ID)
+		}
+	}
+	if len(ids) == 0 {
+		return
+	}
+	if err := downloadRepo.UpdateWaitingByIDs(ids...); err != nil {
+		logger.Errorln("release downloads failed:", err)
+		return
+	}
+	logger.Infof("%d downloads are released to other accounts", len(ids))
 }
 
 type _Queue struct {
@@ -47,13 +127,7 @@ type _Queue struct {
 var queue = &_Queue{}
 
 func (q *_Queue) AllKeys() []uint {
-	maxParallel := int32(config.Telegram.Get().MaxParallelDownload)
-	mapLen := q.queueLength.Load()
-	if mapLen < maxParallel {
-		mapLen = maxParallel
-	}
-
-	ids := make([]uint, 0, mapLen)
+	ids := make([]uint, 0, q.queueLength.Load())
 	q.queue.Range(func(key, value interface{}) bool {
 		ids = append(ids, key.(uint))
 		return true
@@ -65,6 +139,18 @@ func (q *_Queue) Len() int32 {
 	return q.queueLength.Load()
 }
 
+// LenOf counts tasks downloading with account
+func (q *_Queue) LenOf(account *source.Account) int {
+	var n int
+	q.Range(func(_ uint, task *DownloadTask) bool {
+		if task.account == account {
+			n++
+		}
+		return true
+	})
+	return n
+}
+
 func (q *_Queue) Load(key uint) (*DownloadTask, bool) {
 	val, ok := q.queue.Load(key)
 	if !ok {
@@ -104,15 +190,30 @@ func (q *_Queue) Range(f func(key uint, value *DownloadTask) bool) {
 	})
 }
 
+// RunningByAccount counts tasks in queue by name of account
+func RunningByAccount() map[string]int {
+	var running = make(map[string]int, len(source.Accounts))
+	queue.Range(func(_ uint, task *DownloadTask) bool {
+		running[task.account.Name]++
+		return true
+	})
+	return running
+}
+
 // should call after ensured addLock is locked and queue is empty
 func (q *_Queue) clean() error {
 	q.queue.Clear()
 	q.queueLength.Store(int32(0))
-	if err := source.Telegram.RemoveAllDownloads(); err != nil {
-		return err
-	}
-	if err := source.Telegram.CleanDownloadDirectory(); err != nil {
-		return err
+	for _, account := range source.Accounts {
+		if !account.Ready() {
+			continue
+		}
+		if err := account.Telegram.RemoveAllDownloads(); err != nil {
+			return err
+		}
+		if err := account.Telegram.CleanDownloadDirectory(); err != nil {
+			return err
+		}
 	}
 	return nil
 }
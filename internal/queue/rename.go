@@ -0,0 +1,188 @@
+package queue
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path"
+
+	"github.com/acgn-org/onest/internal/config"
+	"github.com/acgn-org/onest/internal/logfield"
+	"github.com/acgn-org/onest/repository"
+	"github.com/acgn-org/onest/tools"
+	log "github.com/sirupsen/logrus"
+)
+
+// RenderTargetPath returns the full path of a downloaded file under the current rules of the item
+func RenderTargetPath(item *repository.Item, text, ext string) (string, error) {
+	targetName, err := tools.ConvertPatternRegexpString(text, item.Regexp, item.Pattern)
+	if err != nil {
+		return "", err
+	}
+	return path.Join(item.TargetPath, targetName) + ext, nil
+}
+
+type RenameStep struct {
+	DownloadID  uint   `json:"download_id"`
+	CompanionID uint   `json:"companion_id,omitempty"`
+	From        string `json:"from"`
+	To          string `json:"to"`
+	Conflict    string `json:"conflict,omitempty"`
+}
+
+type RenamePlan struct {
+	ItemID uint         `json:"item_id"`
+	Steps  []RenameStep `json:"steps"`
+	// downloads already placed at the rendered path
+	Unchanged int `json:"unchanged"`
+	// downloads completed before paths were recorded
+	Unknown []uint `json:"unknown"`
+	// identifies the plan previewed, executing requires it unchanged
+	Hash string `json:"hash"`
+}
+
+func (plan RenamePlan) HasConflict() bool {
+	for _, step := range plan.Steps {
+		if step.Conflict != "" {
+			return true
+		}
+	}
+	return false
+}
+
+func PlanRename(item *repository.Item, downloads []repository.Download, companions []repository.Companion) (*RenamePlan, error) {
+	plan := RenamePlan{
+		ItemID:  item.ID,
+		Steps:   make([]RenameStep, 0),
+		Unknown: make([]uint, 0),
+	}
+
+	companionsByDownload := make(map[uint][]repository.Companion, len(downloads))
+	for _, companion := range companions {
+		companionsByDownload[companion.DownloadID] = append(companionsByDownload[companion.DownloadID], companion)
+	}
+
+	targets := make(map[string]uint, len(downloads))
+	addStep := func(step RenameStep) {
+		if step.From == step.To {
+			targets[step.To] = step.DownloadID
+			return
+		}
+		if id, ok := targets[step.To]; ok {
+			step.Conflict = fmt.Sprintf("target is shared with download %d", id)
+		} else if _, err := os.Stat(step.From); err != nil {
+			step.Conflict = fmt.Sprintf("source is not accessible: %v", err)
+		} else if _, err := os.Stat(step.To); err == nil {
+			step.Conflict = "target already exists"
+		} else if !os.IsNotExist(err) {
+			step.Conflict = fmt.Sprintf("target is not accessible: %v", err)
+		}
+		targets[step.To] = step.DownloadID
+		plan.Steps = append(plan.Steps, step)
+	}
+
+	for _, download := range downloads {
+		if download.Path == "" {
+			plan.Unknown = append(plan.Unknown, download.ID)
+			continue
+		}
+
+		to, err := RenderTargetPath(item, download.Text, path.Ext(download.Path))
+		if err != nil {
+			return nil, err
+		}
+		if to == download.Path {
+			plan.Unchanged++
+		}
+		addStep(RenameStep{
+			DownloadID: download.ID,
+			From:       download.Path,
+			To:         to,
+		})
+
+		for _, companion := range companionsByDownload[download.ID] {
+			suffix, ok := companionSuffix(download.Path, companion.Path)
+			if !ok {
+				continue
+			}
+			addStep(RenameStep{
+				DownloadID:  download.ID,
+				CompanionID: companion.ID,
+				From:        companion.Path,
+				To:          sidecarPath(to, suffix),
+			})
+		}
+	}
+
+	data, err := json.Marshal(plan)
+	if err != nil {
+		return nil, err
+	}
+	hash := sha256.Sum256(data)
+	plan.Hash = hex.EncodeToString(hash[:])
+	return &plan, nil
+}
+
+// ExecuteRename moves files as planned, moves are reverted if any step fails.
+// The returned revert func can be used to undo the moves when later operations failed.
+func ExecuteRename(plan *RenamePlan) (revert func(), err error) {
+	if plan.HasConflict() {
+		return nil, errors.New("rename plan contains conflicts")
+	}
+
+	logger := logfield.New(logfield.ComQueue).WithAction("rename").WithField("item", plan.ItemID)
+
+	var done = make([]RenameStep, 0, len(plan.Steps))
+	// targets of steps whose source is left, reverted by removing the copy
+	var copied = make(map[string]bool)
+	revert = func() {
+		for i := len(done) - 1; i >= 0; i-- {
+			step := done[i]
+			if copied[step.To] {
+				if err := os.Remove(step.To); err != nil {
+					logger.Errorf("remove copy '%s' failed: %v", step.To, err)
+				}
+			} else if err := tools.MoveFileExclusive(step.To, step.From, config.FilePerm); err != nil {
+				logger.Errorf("revert '%s' to '%s' failed: %v", step.To, step.From, err)
+			}
+			moveSidecars(logger, step.To, step.From)
+		}
+	}
+
+	for _, step := range plan.Steps {
+		if err := tools.EnsureDirectory(path.Dir(step.To), config.FilePerm); err != nil {
+			revert()
+			return nil, err
+		}
+		if err := tools.MoveFileExclusive(step.From, step.To, config.FilePerm); err != nil {
+			if errors.Is(err, tools.ErrRemoveSource) {
+				logger.Warnf("'%s' is copied but not removed: %v", step.From, err)
+				copied[step.To] = true
+			} else {
+				revert()
+				return nil, fmt.Errorf("move '%s' to '%s' failed: %w", step.From, step.To, err)
+			}
+		}
+		logger.Debugf("moved '%s' to '%s'", step.From, step.To)
+		done = append(done, step)
+		moveSidecars(logger, step.From, step.To)
+	}
+
+	return revert, nil
+}
+
+// moveSidecars is best-effort, sidecars could be regenerated
+func moveSidecars(logger log.FieldLogger, from, to string) {
+	for _, ext := range sidecarExts {
+		sidecarFrom := sidecarPath(from, ext)
+		if _, err := os.Stat(sidecarFrom); err != nil {
+			continue
+		}
+		if err := tools.MoveFileExclusive(sidecarFrom, sidecarPath(to, ext), config.FilePerm); err != nil {
+			logger.Warnf("move sidecar '%s' failed: %v", sidecarFrom, err)
+		}
+	}
+}
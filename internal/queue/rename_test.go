@@ -0,0 +1,195 @@
+package queue
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/acgn-org/onest/internal/config"
+	"github.com/acgn-org/onest/repository"
+)
+
+func TestPlanRename(t *testing.T) {
+	dir := t.TempDir()
+	touch := func(name string) string {
+		t.Helper()
+		name = filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(name), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(name, nil, 0o644); err != nil {
+			t.Fatal(err)
+		}
+		return name
+	}
+
+	item := &repository.Item{
+		ID:         1,
+		Regexp:     `(\d+)`,
+		Pattern:    "E$1",
+		TargetPath: filepath.Join(dir, "new"),
+	}
+	downloads := []repository.Download{
+		{ID: 1, Text: "episode 01", Path: touch("old/1.mkv")},
+		// renders the same target as download 1
+		{ID: 2, Text: "episode 01 v2", Path: touch("old/2.mkv")},
+		{ID: 3, Text: "episode 03", Path: touch("old/3.mkv")},
+		{ID: 4, Text: "episode 04", Path: filepath.Join(dir, "old/4.mkv")},
+		{ID: 5, Text: "episode 05", Path: touch("new/E05.mkv")},
+		{ID: 6, Text: "episode 06"},
+	}
+	touch("new/E03.mkv")
+	companions := []repository.Companion{
+		{ID: 1, DownloadID: 1, Path: touch("old/1.zh.ass")},
+		// not a sidecar of the video, left in place
+		{ID: 2, DownloadID: 1, Path: touch("old/other.ass")},
+	}
+
+	plan, err := PlanRename(item, downloads, companions)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []struct {
+		downloadID, companionID uint
+		to, conflict            string
+	}{
+		{1, 0, "new/E01.mkv", ""},
+		{1, 1, "new/E01.zh.ass", ""},
+		{2, 0, "new/E01.mkv", "target is shared with download 1"},
+		{3, 0, "new/E03.mkv", "target already exists"},
+		{4, 0, "new/E04.mkv", "source is not accessible"},
+	}
+	if len(plan.Steps) != len(want) {
+		t.Fatalf("got %d steps, want %d: %+v", len(plan.Steps), len(want), plan.Steps)
+	}
+	for i, step := range plan.Steps {
+		w := want[i]
+		if step.DownloadID != w.downloadID || step.CompanionID != w.companionID || step.To != filepath.Join(dir, w.to) {
+			t.Errorf("step %d got %+v, want %+v", i, step, w)
+		}
+		if !strings.HasPrefix(step.Conflict, w.conflict) || (w.conflict == "") != (step.Conflict == "") {
+			t.Errorf("step %d got conflict %q, want %q", i, step.Conflict, w.conflict)
+		}
+	}
+	if plan.Unchanged != 1 {
+		t.Errorf("got %d unchanged, want 1", plan.Unchanged)
+	}
+	if len(plan.Unknown) != 1 || plan.Unknown[0] != 6 {
+		t.Errorf("got unknown %v, want [6]", plan.Unknown)
+	}
+	if !plan.HasConflict() {
+		t.Error("plan has no conflict")
+	}
+	if _, err := ExecuteRename(plan); err == nil {
+		t.Error("plan with conflicts is executed")
+	}
+}
+
+func TestExecuteRenameRevert(t *testing.T) {
+	config.FilePerm = 0o755
+	dir := t.TempDir()
+	from := filepath.Join(dir, "old", "1.mkv")
+	if err := os.MkdirAll(filepath.Dir(from), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(from, []byte("video"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(sidecarPath(from, ".nfo"), []byte("nfo"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	item := &repository.Item{ID: 1, Regexp: `(\d+)`, Pattern: "E$1", TargetPath: filepath.Join(dir, "new")}
+	plan, err := PlanRename(item, []repository.Download{{ID: 1, Text: "episode 01", Path: from}}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	revert, err := ExecuteRename(plan)
+	if err != nil {
+		t.Fatal(err)
+	}
+	to := filepath.Join(dir, "new", "E01.mkv")
+	for _, name := range []string{to, sidecarPath(to, ".nfo")} {
+		if _, err := os.Stat(name); err != nil {
+			t.Errorf("%s is not moved: %v", name, err)
+		}
+	}
+
+	revert()
+	for _, name := range []string{from, sidecarPath(from, ".nfo")} {
+		if _, err := os.Stat(name); err != nil {
+			t.Errorf("%s is not reverted: %v", name, err)
+		}
+	}
+	if _, err := os.Stat(to); !os.IsNotExist(err) {
+		t.Errorf("%s is left after revert: %v", to, err)
+	}
+}
+
+func TestExecuteRenameTargetCreatedAfterPlan(t *testing.T) {
+	config.FilePerm = 0o755
+	dir := t.TempDir()
+	var downloads []repository.Download
+	for _, name := range []string{"1", "2"} {
+		from := filepath.Join(dir, name+".mkv")
+		if err := os.WriteFile(from, []byte(name), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		downloads = append(downloads, repository.Download{ID: uint(len(downloads) + 1), Text: "episode 0" + name, Path: from})
+	}
+
+	item := &repository.Item{ID: 1, Regexp: `(\d+)`, Pattern: "E$1", TargetPath: filepath.Join(dir, "new")}
+	plan, err := PlanRename(item, downloads, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if plan.HasConflict() {
+		t.Fatalf("unexpected conflict: %+v", plan.Steps)
+	}
+	if err := os.MkdirAll(filepath.Join(dir, "new"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	created := filepath.Join(dir, "new", "E02.mkv")
+	if err := os.WriteFile(created, []byte("created"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := ExecuteRename(plan); !errors.Is(err, os.ErrExist) {
+		t.Fatalf("got error %v, want %v", err, os.ErrExist)
+	}
+	if data, _ := os.ReadFile(created); string(data) != "created" {
+		t.Errorf("target created after planning is overwritten: %q", data)
+	}
+	// the first step is reverted
+	if data, _ := os.ReadFile(filepath.Join(dir, "1.mkv")); string(data) != "1" {
+		t.Errorf("first move is not reverted: %q", data)
+	}
+}
+
+func TestPlanRenameHash(t *testing.T) {
+	item := &repository.Item{ID: 1, Regexp: `(\d+)`, Pattern: "E$1", TargetPath: "/new"}
+	downloads := []repository.Download{{ID: 1, Text: "episode 01", Path: "/new/E01.mkv"}}
+	plan, err := PlanRename(item, downloads, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	again, err := PlanRename(item, downloads, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if plan.Hash == "" || plan.Hash != again.Hash {
+		t.Errorf("hash of the same plan got %q and %q", plan.Hash, again.Hash)
+	}
+
+	item.Pattern = "S01E$1"
+	changed, err := PlanRename(item, downloads, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if changed.Hash == plan.Hash {
+		t.Error("hash is unchanged after the plan changed")
+	}
+}
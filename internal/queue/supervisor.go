@@ -2,12 +2,14 @@ package queue
 
 import (
 	"context"
+	"sync"
 	"sync/atomic"
 	"time"
 
 	"github.com/acgn-org/onest/internal/config"
 	"github.com/acgn-org/onest/internal/database"
 	"github.com/acgn-org/onest/internal/logfield"
+	"github.com/acgn-org/onest/internal/metrics"
 	"github.com/acgn-org/onest/internal/source"
 	"github.com/acgn-org/onest/repository"
 	"github.com/zelenin/go-tdlib/client"
@@ -22,31 +24,59 @@ func TryActivateTaskControl() {
 	}
 }
 
-func supervisor() {
-	instance := _Supervisor{
+var (
+	stopSupervisor    context.CancelFunc
+	supervisorWorkers sync.WaitGroup
+)
+
+func newSupervisor(ctx context.Context) _Supervisor {
+	return _Supervisor{
 		logger:  logfield.New(logfield.ComQueueSupervisor),
 		Cleaned: &atomic.Bool{},
+		ctx:     ctx,
+	}
+}
+
+// Start runs task control worker in background
+func (s _Supervisor) Start() {
+	supervisorWorkers.Add(1)
+	go func() {
+		defer supervisorWorkers.Done()
+		s.WorkerTaskControl()
+	}()
+
+	// apply changes of parallel downloads
+	config.Telegram.OnChange(TryActivateTaskControl)
+}
+
+// Stop stops supervisor workers, and waits for tasks being completed by them
+func Stop(ctx context.Context) error {
+	if stopSupervisor == nil {
+		return nil
+	}
+	stopSupervisor()
+
+	done := make(chan struct{})
+	go func() {
+		supervisorWorkers.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
 	}
-	go instance.WorkerTaskControl()
-	go instance.WorkerListen()
 }
 
 type _Supervisor struct {
 	logger  logfield.LoggerWithFields
 	Cleaned *atomic.Bool
+	// canceled on stop
+	ctx context.Context
 }
 
 func (s _Supervisor) WorkerTaskControl() {
-	// scan on start up
-	s.logger.Debugln("scanning all histories")
-	scanned, err := ScanAndCreateNewDownloadTasks(nil)
-	scanLogger := s.logger.WithAction("scan")
-	if err != nil {
-		scanLogger.Warnln("scan history of all channels failed:", err)
-	} else {
-		scanLogger.Debugf("%d tasks created", scanned)
-	}
-
 	s.logger.Debugln("task control worker started")
 	var slowDown bool
 	for {
@@ -56,6 +86,9 @@ func (s _Supervisor) WorkerTaskControl() {
 		}
 
 		select {
+		case <-s.ctx.Done():
+			s.logger.Debugln("task control worker stopped")
+			return
 		case <-time.After(sleep):
 		case <-_ActivateTaskControl:
 			s.logger.Debugln("activate task control by signal")
@@ -101,21 +134,52 @@ func (s _Supervisor) TaskControl() (slowDown bool) {
 		return true
 	})
 
-	// maintain number of parallel downloads
-	numToDownload := int(config.Telegram.Get().MaxParallelDownload) - int(queue.Len())
+	// download companions of completed tasks
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	companions, err := ProcessPendingCompanions(ctx)
+	cancel()
+	if err != nil {
+		s.logger.Errorln("process companions failed:", err)
+	} else if companions > 0 {
+		s.Cleaned.Store(false)
+	}
+
+	// maintain number of parallel downloads of every account
+	var slots = make(map[*source.Account]int, len(source.Accounts))
+	var numToDownload int
+	for _, account := range source.Accounts {
+		if !account.Ready() {
+			continue
+		}
+		if n := account.MaxParallelDownload() - queue.LenOf(account); n > 0 {
+			slots[account] = n
+			numToDownload += n
+		}
+	}
 	if numToDownload > 0 {
+		// downloads of channels not seen by accounts with free slots are skipped, so more are loaded
+		limit := numToDownload * 4
 		downloadRepo := database.NewRepository[repository.DownloadRepository]()
-		repos, err := downloadRepo.GetForDownload(&numToDownload)
+		repos, err := downloadRepo.GetForDownload(&limit)
 		if err != nil {
 			s.logger.Errorln("load download task from database failed:", err)
 		} else if len(repos) != 0 {
 			s.Cleaned.Store(false)
 			for _, repo := range repos {
-				if err := startDownload(context.Background(), repo.ChannelID, repo.Download); err != nil {
+				if numToDownload == 0 {
+					break
+				}
+				account := pickAccount(s.ctx, slots, &repo)
+				if account == nil {
+					continue
+				}
+				slots[account]--
+				numToDownload--
+				if err := startDownload(context.Background(), account, repo.ChannelID, repo.Download); err != nil {
 					s.logger.Errorln("error occurred while start download task:", err)
 				}
 			}
-		} else if queue.Len() == 0 {
+		} else if queue.Len() == 0 && !companionsInFlight() {
 			queue.addLock.Lock()
 			defer queue.addLock.Unlock()
 			if queue.Len() == 0 {
@@ -135,12 +199,56 @@ func (s _Supervisor) TaskControl() (slowDown bool) {
 	return false
 }
 
-func (s _Supervisor) WorkerListen() {
-	listener := source.Telegram.GetListener()
+// pickAccount returns the account has most free slots among those can see the channel, the account of item is preferred on ties
+func pickAccount(ctx context.Context, slots map[*source.Account]int, download *repository.DownloadWithChannelID) *source.Account {
+	var picked *source.Account
+	for _, account := range source.Accounts {
+		n := slots[account]
+		if n <= 0 {
+			continue
+		}
+		if picked != nil && (n < slots[picked] || n == slots[picked] && account.Name != download.ItemAccount) {
+			continue
+		}
+		if account.CanSee(ctx, download.ChannelID) {
+			picked = account
+		}
+	}
+	return picked
+}
+
+// WorkerAccount scans items of the authorized account, then listens to its updates
+func (s _Supervisor) WorkerAccount(account *source.Account) {
+	scanLogger := s.logger.WithAction("scan").WithField("account", account.Name)
+	scanLogger.Debugln("scanning all histories")
+	scanned, err := ScanAndCreateNewDownloadTasks(account, nil)
+	if err != nil {
+		scanLogger.Warnln("scan history of all channels failed:", err)
+	} else {
+		scanLogger.Debugf("%d tasks created", scanned)
+		if scanned > 0 {
+			TryActivateTaskControl()
+		}
+	}
+
+	s.WorkerListen(account)
+}
+
+func (s _Supervisor) WorkerListen(account *source.Account) {
+	listener := account.Telegram.GetListener()
 	defer listener.Close()
 
 	for {
-		update := <-listener.Updates
+		var update client.Type
+		select {
+		case <-s.ctx.Done():
+			return
+		case u, ok := <-listener.Updates:
+			if !ok {
+				return
+			}
+			update = u
+		}
 
 		switch update.GetType() {
 
@@ -148,11 +256,15 @@ func (s _Supervisor) WorkerListen() {
 			var isFileCompleted bool
 			file := update.(*client.UpdateFile).File
 			queue.Range(func(id uint, task *DownloadTask) bool {
-				if state := task.state.Load(); state != nil && state.File.Id == file.Id {
+				if state := task.state.Load(); task.account == account && state != nil && state.File.Id == file.Id {
+					if downloaded := file.Local.DownloadedSize - state.File.Local.DownloadedSize; downloaded > 0 {
+						metrics.DownloadedBytes.Add(float64(downloaded))
+					}
 					task.state.Store(&TaskFileState{
 						File:      file,
 						UpdatedAt: time.Now(),
 					})
+					PublishTaskEvent(TaskEvent{Type: TaskEventProgress, ID: id, File: file})
 					if file.Local.IsDownloadingCompleted {
 						isFileCompleted = true
 						ok, err := task.CompleteDownload(context.TODO())
@@ -165,14 +277,18 @@ func (s _Supervisor) WorkerListen() {
 				}
 				return true
 			})
+			if file.Local.IsDownloadingCompleted && isCompanionFile(account, file.Id) {
+				// placed by task control
+				isFileCompleted = true
+			}
 			if isFileCompleted {
 				TryActivateTaskControl()
 			}
 
 		case client.TypeUpdateNewMessage:
 			message := update.(*client.UpdateNewMessage).Message
-			logger := s.logger.WithAction("scan").WithField("channel", message.ChatId)
-			created, err := ScanAndCreateNewDownloadTasks(&message.Id, message.ChatId)
+			logger := s.logger.WithAction("scan").WithField("account", account.Name).WithField("channel", message.ChatId)
+			created, err := ScanAndCreateNewDownloadTasks(account, &message.Id, message.ChatId)
 			if err != nil {
 				logger.Errorln("failed to scan tasks:", err)
 				continue
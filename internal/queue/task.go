@@ -4,8 +4,6 @@ import (
 	"context"
 	"errors"
 	"fmt"
-	"io"
-	"os"
 	"path"
 	"sync"
 	"sync/atomic"
@@ -14,6 +12,7 @@ import (
 	"github.com/acgn-org/onest/internal/config"
 	"github.com/acgn-org/onest/internal/database"
 	"github.com/acgn-org/onest/internal/logfield"
+	"github.com/acgn-org/onest/internal/metrics"
 	"github.com/acgn-org/onest/internal/source"
 	"github.com/acgn-org/onest/repository"
 	"github.com/acgn-org/onest/tools"
@@ -31,12 +30,12 @@ func UpdatePriority(ctx context.Context, id uint, priority int32) error {
 	return download.UpdateOrDownload(ctx, true)
 }
 
-func ForceAddDownloadQueue(ctx context.Context, channelId int64, model repository.Download) error {
+func ForceAddDownloadQueue(ctx context.Context, account *source.Account, channelId int64, model repository.Download) error {
 	task, ok := queue.Load(model.ID)
 	if ok {
 		return task.UpdateOrDownload(ctx, false)
 	}
-	return startDownload(ctx, channelId, model)
+	return startDownload(ctx, account, channelId, model)
 }
 
 func RemoveTasks(ids ...uint) {
@@ -49,6 +48,22 @@ func RemoveTasks(ids ...uint) {
 		if err := task.Terminate(); err != nil {
 			logfield.New(logfield.ComQueue).WithAction("remove").Errorf("terminate task %d with error: %v", id, err)
 		}
+		PublishTaskEvent(TaskEvent{Type: TaskEventRemoved, ID: id})
+	}
+}
+
+// PauseTasks removes tasks from queue, downloaded parts are kept in tdlib cache until queue is cleaned
+func PauseTasks(ids ...uint) {
+	for _, id := range ids {
+		task, ok := queue.LoadAndDelete(id)
+		if !ok {
+			continue
+		}
+
+		if err := task.Pause(); err != nil {
+			logfield.New(logfield.ComQueue).WithAction("pause").Errorf("pause task %d with error: %v", id, err)
+		}
+		PublishTaskEvent(TaskEvent{Type: TaskEventRemoved, ID: id})
 	}
 }
 
@@ -107,7 +122,9 @@ func (tl TaskLogger) Errorln(args ...interface{}) {
 		At:  time.Now(),
 	}
 	tl.error.Store(&errorState)
+	metrics.TaskErrors.Inc()
 	newErrorCount := tl.errorCount.Add(uint32(1))
+	PublishTaskEvent(TaskEvent{Type: TaskEventError, ID: tl.id, Error: errorState.Err})
 
 	if newErrorCount >= config.Telegram.Get().MaxDownloadError {
 		tl.FatalNow()
@@ -124,15 +141,19 @@ func (tl TaskLogger) Errorln(args ...interface{}) {
 
 func (tl TaskLogger) FatalNow() {
 	tl.logger.Debugln("fatal now")
-	tl.isFatal.Store(true)
+	if !tl.isFatal.Swap(true) {
+		metrics.TaskFatals.Inc()
+	}
+	PublishTaskEvent(TaskEvent{Type: TaskEventFatal, ID: tl.id, Error: tl.error.Load().Err})
 }
 
-func NewTask(ctx context.Context, channelId int64, model repository.Download) (*DownloadTask, error) {
+func NewTask(ctx context.Context, account *source.Account, channelId int64, model repository.Download) (*DownloadTask, error) {
 	task := &DownloadTask{
 		ID:        model.ID,
 		ChannelID: channelId,
 		MsgID:     model.MsgID,
-		log:       NewTaskLogger(model.ID, logfield.New(logfield.ComTask).WithField("id", model.ID)),
+		account:   account,
+		log:       NewTaskLogger(model.ID, logfield.New(logfield.ComTask).WithField("id", model.ID).WithField("account", account.Name)),
 	}
 	task.priority.Store(model.Priority)
 	return task, task.UpdateOrDownload(ctx, false)
@@ -144,6 +165,9 @@ type DownloadTask struct {
 	ChannelID int64
 	MsgID     int64
 
+	// file ids are only valid in the account
+	account *source.Account
+
 	log TaskLogger
 
 	completed    atomic.Bool
@@ -189,12 +213,6 @@ func (task *DownloadTask) CompleteDownload(ctx context.Context) (ok bool, err er
 		return
 	}
 
-	err = downloadRepo.UpdateDownloadComplete(task.ID)
-	if err != nil {
-		task.log.Errorln("mark download complete failed:", err)
-		return
-	}
-
 	itemRepo := repository.ItemRepository{Repository: downloadRepo.Repository}
 	item, err := itemRepo.FirstItemByID(download.ItemID)
 	if err != nil {
@@ -205,69 +223,35 @@ func (task *DownloadTask) CompleteDownload(ctx context.Context) (ok bool, err er
 		return
 	}
 
-	targetPath := item.TargetPath
-	targetName, err := tools.ConvertPatternRegexpString(download.Text, item.Regexp, item.Pattern)
-	if err != nil {
-		task.log.Errorln("convert target path failed:", err)
-		return
-	}
-
 	state := task.state.Load()
 	if state == nil {
 		panic("complete download called without file state")
 	}
 
-	info, err := os.Stat(targetPath)
+	fullPath, err := RenderTargetPath(item, download.Text, path.Ext(state.File.Local.Path))
 	if err != nil {
-		if os.IsNotExist(err) {
-			err = os.MkdirAll(targetPath, config.FilePerm)
-			if err != nil {
-				task.log.Errorln("create target directory failed:", err)
-				return
-			}
-		} else {
-			task.log.Errorln("stat target directory failed:", err)
-			return
-		}
-	} else if !info.IsDir() {
-		msg := fmt.Sprintf("target path '%s' is not a directory", targetPath)
-		task.log.Errorln(msg)
-		return ok, errors.New(msg)
+		task.log.Errorln("convert target path failed:", err)
+		return
 	}
 
-	fullPath := path.Join(targetPath, targetName) + path.Ext(state.File.Local.Path)
-	err = os.Rename(state.File.Local.Path, fullPath)
+	err = downloadRepo.UpdateDownloadComplete(task.ID, fullPath)
 	if err != nil {
-		task.log.logger.Debugln("rename file failed:", err)
-
-		var fileSource *os.File
-		fileSource, err = os.OpenFile(state.File.Local.Path, os.O_RDONLY, 0600)
-		if err != nil {
-			task.log.Errorln("open source file failed:", err)
-			return
-		}
-		defer fileSource.Close()
+		task.log.Errorln("mark download complete failed:", err)
+		return
+	}
 
-		var fileTarget *os.File
-		fileTarget, err = os.OpenFile(fullPath, os.O_RDWR|os.O_CREATE|os.O_TRUNC, config.FilePerm)
-		if err != nil {
-			task.log.Errorln("create file failed:", err)
-			return
-		}
-		defer fileTarget.Close()
+	err = tools.EnsureDirectory(path.Dir(fullPath), config.FilePerm)
+	if err != nil {
+		task.log.Errorln("prepare target directory failed:", err)
+		return
+	}
 
-		buffer := tools.BufferCopy.Get().([]byte)
-		defer tools.BufferCopy.Put(buffer)
-		_, err = io.CopyBuffer(fileTarget, fileSource, buffer)
-		if err != nil {
-			task.log.Errorln("copy file failed:", err)
+	err = tools.MoveFile(state.File.Local.Path, fullPath, config.FilePerm)
+	if err != nil {
+		task.log.Errorln("move file failed:", err)
+		if !errors.Is(err, tools.ErrRemoveSource) {
 			return
 		}
-
-		err = os.Remove(state.File.Local.Path)
-		if err != nil {
-			task.log.Errorln("remove source file failed:", err)
-		}
 	}
 
 	if err = downloadRepo.Commit().Error; err != nil {
@@ -276,16 +260,45 @@ func (task *DownloadTask) CompleteDownload(ctx context.Context) (ok bool, err er
 	}
 
 	task.completed.Store(true)
+	metrics.DownloadsCompleted.Inc()
+	PublishTaskEvent(TaskEvent{Type: TaskEventCompleted, ID: task.ID, File: state.File, Path: fullPath})
+
+	if item.Nfo {
+		task.writeNfo(ctx, item, download, fullPath)
+	}
 	return
 }
 
+// writeNfo failures are not counted as task errors, as the video is already in place
+func (task *DownloadTask) writeNfo(ctx context.Context, item *repository.Item, download *repository.Download, videoPath string) {
+	ctx, cancel := context.WithTimeout(ctx, time.Second*30)
+	defer cancel()
+
+	nfo, err := NewNfoEpisode(ctx, task.log.logger, task.account, item, download, videoPath)
+	if err != nil {
+		task.log.logger.Warnln("collect nfo metadata failed:", err)
+		return
+	}
+	if err := nfo.Write(videoPath); err != nil {
+		task.log.logger.Warnln("write nfo file failed:", err)
+	}
+}
+
+// File returns the last known file state, maybe nil
+func (task *DownloadTask) File() *client.File {
+	if state := task.state.Load(); state != nil {
+		return state.File
+	}
+	return nil
+}
+
 func (task *DownloadTask) GetVideoFile(ctx context.Context) (bool, error) {
-	msg, err := source.Telegram.GetMessage(ctx, task.ChannelID, task.MsgID)
+	msg, err := task.account.Telegram.GetMessage(ctx, task.ChannelID, task.MsgID)
 	if err != nil {
 		task.log.Errorln("get message failed:", err)
 		return false, err
 	}
-	messageVideo, ok := source.Telegram.GetMessageVideo(msg)
+	messageVideo, ok := task.account.Telegram.GetMessageVideo(msg)
 	if !ok {
 		return false, nil
 	}
@@ -315,7 +328,7 @@ func (task *DownloadTask) UpdateOrDownload(ctx context.Context, forceStart bool)
 		}
 		state = task.state.Load()
 	} else if !forceStart {
-		file, err := source.Telegram.GetFile(state.File.Id)
+		file, err := task.account.Telegram.GetFile(state.File.Id)
 		if err != nil {
 			task.log.Errorln("get download file state failed:", err)
 			return err
@@ -329,7 +342,7 @@ func (task *DownloadTask) UpdateOrDownload(ctx context.Context, forceStart bool)
 		}
 	}
 
-	file, err := source.Telegram.DownloadFile(state.File.Id, task.priority.Load(), false)
+	file, err := task.account.Telegram.DownloadFile(state.File.Id, task.priority.Load(), false)
 	if err != nil {
 		task.log.Errorln("request download failed:", err)
 	} else {
@@ -341,6 +354,13 @@ func (task *DownloadTask) UpdateOrDownload(ctx context.Context, forceStart bool)
 	return err
 }
 
+func (task *DownloadTask) Pause() error {
+	if state := task.state.Load(); state != nil && !state.File.Local.IsDownloadingCompleted {
+		return task.account.Telegram.CancelDownloadFile(state.File.Id)
+	}
+	return nil
+}
+
 func (task *DownloadTask) Terminate() error {
 	if task.log.isFatal.Load() {
 		return nil
@@ -348,11 +368,11 @@ func (task *DownloadTask) Terminate() error {
 
 	if state := task.state.Load(); state != nil {
 		if !state.File.Local.IsDownloadingCompleted {
-			if err := source.Telegram.CancelDownloadFile(state.File.Id); err != nil {
+			if err := task.account.Telegram.CancelDownloadFile(state.File.Id); err != nil {
 				return err
 			}
 		}
-		return source.Telegram.RemoveFileFromDownloads(state.File.Id)
+		return task.account.Telegram.RemoveFileFromDownloads(state.File.Id)
 	}
 	return nil
 }
@@ -0,0 +1,61 @@
+package api
+
+import (
+	"errors"
+
+	"github.com/acgn-org/onest/internal/auth"
+	"github.com/acgn-org/onest/internal/database"
+	"github.com/acgn-org/onest/internal/server/response"
+	"github.com/acgn-org/onest/repository"
+	"github.com/gin-gonic/gin"
+)
+
+// itemSnapshot is an item with downloads created or deleted together
+type itemSnapshot struct {
+	*repository.Item
+	DownloadIDs []uint `json:"download_ids,omitempty"`
+}
+
+// audit records a change in the transaction of repo, before and after are snapshots of the targets
+func audit(ctx *gin.Context, repo repository.Repository, action, targetType string, targetIDs []uint, before, after any) error {
+	var model = repository.AuditLog{
+		ActorType:  repository.AuditActorAnonymous,
+		Actor:      ctx.ClientIP(),
+		RemoteAddr: ctx.ClientIP(),
+		Action:     action,
+		TargetType: targetType,
+		TargetIDs:  targetIDs,
+		Before:     before,
+		After:      after,
+	}
+	if principal := auth.GetPrincipal(ctx); principal != nil {
+		model.ActorType = principal.Type
+		model.Actor = principal.Name
+		// names of tokens are not unique
+		model.ActorID = principal.ID
+	}
+
+	auditRepo := repository.AuditRepository{Repository: repo}
+	return auditRepo.Create(&model)
+}
+
+func GetAuditLogs(ctx *gin.Context) {
+	var filter repository.AuditFilter
+	if err := ctx.ShouldBind(&filter); err != nil {
+		response.Error(ctx, response.ErrForm, err)
+		return
+	}
+
+	auditRepo := database.NewRepository[repository.AuditRepository]()
+	page, err := auditRepo.GetPage(&filter)
+	if err != nil {
+		if errors.Is(err, repository.ErrInvalidCursor) {
+			response.Error(ctx, response.ErrForm, err)
+			return
+		}
+		response.Error(ctx, response.ErrDBOperation, err)
+		return
+	}
+
+	response.Success(ctx, page)
+}
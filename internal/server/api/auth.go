@@ -0,0 +1,247 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/acgn-org/onest/internal/auth"
+	"github.com/acgn-org/onest/internal/config"
+	"github.com/acgn-org/onest/internal/database"
+	"github.com/acgn-org/onest/internal/server/response"
+	"github.com/acgn-org/onest/repository"
+	"github.com/acgn-org/onest/tools"
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+type AuthStatus struct {
+	Enabled       bool            `json:"enabled"`
+	Authenticated bool            `json:"authenticated"`
+	Principal     *auth.Principal `json:"principal,omitempty"`
+}
+
+func GetAuthStatus(ctx *gin.Context) {
+	var status = AuthStatus{
+		Enabled: config.Auth.Get().Enabled,
+	}
+	if !status.Enabled {
+		status.Authenticated = true
+		response.Success(ctx, status)
+		return
+	}
+
+	principal, err := auth.Authenticate(ctx.Request)
+	if err != nil && !errors.Is(err, auth.ErrUnauthenticated) {
+		response.Error(ctx, response.ErrDBOperation, err)
+		return
+	}
+	status.Authenticated = principal != nil
+	status.Principal = principal
+	response.Success(ctx, status)
+}
+
+func setSessionCookie(ctx *gin.Context, value string, maxAge int) {
+	secure := ctx.Request.TLS != nil || ctx.GetHeader("X-Forwarded-Proto") == "https"
+	ctx.SetSameSite(http.SameSiteLaxMode)
+	ctx.SetCookie(auth.SessionCookie, value, maxAge, "/", "", secure, true)
+}
+
+type LoginForm struct {
+	Username string `json:"username" form:"username" binding:"required"`
+	Password string `json:"password" form:"password" binding:"required"`
+}
+
+func Login(ctx *gin.Context) {
+	var form LoginForm
+	if err := ctx.ShouldBind(&form); err != nil {
+		response.Error(ctx, response.ErrForm, err)
+		return
+	}
+
+	if !config.Auth.Get().Enabled {
+		response.ErrorWithTip(ctx, response.ErrForm, "authentication is disabled")
+		return
+	} else if !auth.CheckPassword(form.Username, form.Password) {
+		response.ErrorWithTip(ctx, response.ErrUnauthorized, "wrong username or password")
+		return
+	}
+
+	secret, session, err := auth.NewSession()
+	if err != nil {
+		response.Error(ctx, response.ErrUnexpected, err)
+		return
+	}
+
+	authRepo := database.NewRepository[repository.AuthRepository]()
+	if err := authRepo.DeleteExpiredSessions(); err != nil {
+		response.Error(ctx, response.ErrDBOperation, err)
+		return
+	}
+	if err := authRepo.CreateSession(session); err != nil {
+		response.Error(ctx, response.ErrDBOperation, err)
+		return
+	}
+
+	setSessionCookie(ctx, secret, int(time.Until(time.Unix(session.ExpiresAt, 0)).Seconds()))
+	response.Default(ctx)
+}
+
+func Logout(ctx *gin.Context) {
+	if cookie, err := ctx.Cookie(auth.SessionCookie); err == nil && cookie != "" {
+		authRepo := database.NewRepository[repository.AuthRepository]()
+		if err := authRepo.DeleteSession(auth.HashSecret(cookie)); err != nil {
+			response.Error(ctx, response.ErrDBOperation, err)
+			return
+		}
+	}
+	setSessionCookie(ctx, "", -1)
+	response.Default(ctx)
+}
+
+type ChangePasswordForm struct {
+	Password    string `json:"password" form:"password" binding:"required"`
+	NewPassword string `json:"new_password" form:"new_password" binding:"required,min=8"`
+}
+
+func ChangePassword(ctx *gin.Context) {
+	var form ChangePasswordForm
+	if err := ctx.ShouldBind(&form); err != nil {
+		response.Error(ctx, response.ErrForm, err)
+		return
+	}
+
+	conf := config.Auth.Get()
+	if !conf.Enabled {
+		response.ErrorWithTip(ctx, response.ErrForm, "authentication is disabled")
+		return
+	} else if !auth.CheckPassword(conf.Username, form.Password) {
+		response.ErrorWithTip(ctx, response.ErrUnauthorized, "wrong password")
+		return
+	}
+
+	hash, err := auth.HashPassword(form.NewPassword)
+	if err != nil {
+		response.Error(ctx, response.ErrUnexpected, err)
+		return
+	}
+	authRepo := database.BeginRepository[repository.AuthRepository]()
+	defer authRepo.Rollback()
+
+	if err := authRepo.DeleteAllSessions(); err != nil {
+		response.Error(ctx, response.ErrDBOperation, err)
+		return
+	}
+	// password hash is not recorded
+	if err := audit(ctx, authRepo.Repository, "config.auth.password", repository.AuditTargetConfig, nil, nil, nil); err != nil {
+		response.Error(ctx, response.ErrDBOperation, err)
+		return
+	}
+
+	conf.PasswordHash = hash
+	if err := config.Auth.Save(conf); err != nil {
+		response.Error(ctx, response.ErrUnexpected, err)
+		return
+	}
+	if err := authRepo.Commit().Error; err != nil {
+		response.Error(ctx, response.ErrDBOperation, err)
+		return
+	}
+	setSessionCookie(ctx, "", -1)
+
+	response.Default(ctx)
+}
+
+func GetApiTokens(ctx *gin.Context) {
+	authRepo := database.NewRepository[repository.AuthRepository]()
+	tokens, err := authRepo.GetTokens()
+	if err != nil {
+		response.Error(ctx, response.ErrDBOperation, err)
+		return
+	}
+	response.Success(ctx, tokens)
+}
+
+type NewApiTokenForm struct {
+	Name   string   `json:"name" form:"name" binding:"required"`
+	Scopes []string `json:"scopes" form:"scopes" binding:"required,min=1,dive,oneof=admin read downloads:write items:write telegram:read logs:read metrics:read"`
+}
+
+type NewApiTokenResult struct {
+	repository.ApiToken
+	// only returned on creation
+	Token string `json:"token"`
+}
+
+func NewApiToken(ctx *gin.Context) {
+	var form NewApiTokenForm
+	if err := ctx.ShouldBind(&form); err != nil {
+		response.Error(ctx, response.ErrForm, err)
+		return
+	}
+
+	secret, token, err := auth.NewToken(form.Name, form.Scopes)
+	if err != nil {
+		response.Error(ctx, response.ErrUnexpected, err)
+		return
+	}
+
+	authRepo := database.BeginRepository[repository.AuthRepository]()
+	defer authRepo.Rollback()
+
+	if err := authRepo.CreateToken(token); err != nil {
+		response.Error(ctx, response.ErrDBOperation, err)
+		return
+	}
+	if err := audit(ctx, authRepo.Repository, "token.create", repository.AuditTargetToken, []uint{token.ID}, nil, token); err != nil {
+		response.Error(ctx, response.ErrDBOperation, err)
+		return
+	}
+
+	if err := authRepo.Commit().Error; err != nil {
+		response.Error(ctx, response.ErrDBOperation, err)
+		return
+	}
+
+	response.Success(ctx, NewApiTokenResult{
+		ApiToken: *token,
+		Token:    secret,
+	})
+}
+
+func DeleteApiToken(ctx *gin.Context) {
+	id, err := tools.UintIDFromParam(ctx, "id")
+	if err != nil {
+		response.Error(ctx, response.ErrForm, err)
+		return
+	}
+
+	authRepo := database.BeginRepository[repository.AuthRepository]()
+	defer authRepo.Rollback()
+
+	before, err := authRepo.FirstTokenByID(id)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			response.Error(ctx, response.ErrNotFound)
+			return
+		}
+		response.Error(ctx, response.ErrDBOperation, err)
+		return
+	}
+
+	if _, err := authRepo.DeleteToken(id); err != nil {
+		response.Error(ctx, response.ErrDBOperation, err)
+		return
+	}
+	if err := audit(ctx, authRepo.Repository, "token.delete", repository.AuditTargetToken, []uint{id}, before, nil); err != nil {
+		response.Error(ctx, response.ErrDBOperation, err)
+		return
+	}
+
+	if err := authRepo.Commit().Error; err != nil {
+		response.Error(ctx, response.ErrDBOperation, err)
+		return
+	}
+
+	response.Default(ctx)
+}
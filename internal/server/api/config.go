@@ -0,0 +1,99 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+
+	"github.com/acgn-org/onest/internal/config"
+	"github.com/acgn-org/onest/internal/database"
+	"github.com/acgn-org/onest/internal/server/response"
+	"github.com/acgn-org/onest/repository"
+	"github.com/gin-gonic/gin"
+)
+
+type scopedConfig interface {
+	Scope() string
+	Fields() []config.Field
+	Update(values map[string]json.RawMessage) (bool, error)
+}
+
+// configScopes are scopes editable at runtime
+var configScopes = map[string]scopedConfig{
+	"server":     config.Server,
+	"telegram":   config.Telegram,
+	"realsearch": config.RealSearch,
+}
+
+type ConfigScope struct {
+	Scope  string         `json:"scope"`
+	Fields []config.Field `json:"fields"`
+	// some of the changed fields take effect after restart
+	RestartRequired bool `json:"restart_required"`
+}
+
+func configScopeFromParam(ctx *gin.Context) (scopedConfig, bool) {
+	conf, ok := configScopes[ctx.Param("scope")]
+	if !ok {
+		response.ErrorWithTip(ctx, response.ErrNotFound, "config scope not found")
+	}
+	return conf, ok
+}
+
+// configValues is used as audit snapshot, secrets are masked
+func configValues(fields []config.Field) map[string]any {
+	var values = make(map[string]any, len(fields))
+	for _, field := range fields {
+		values[field.Key] = field.Value
+	}
+	return values
+}
+
+func GetConfig(ctx *gin.Context) {
+	conf, ok := configScopeFromParam(ctx)
+	if !ok {
+		return
+	}
+
+	response.Success(ctx, ConfigScope{
+		Scope:  conf.Scope(),
+		Fields: conf.Fields(),
+	})
+}
+
+func PatchConfig(ctx *gin.Context) {
+	conf, ok := configScopeFromParam(ctx)
+	if !ok {
+		return
+	}
+
+	var form map[string]json.RawMessage
+	if err := ctx.ShouldBindJSON(&form); err != nil {
+		response.Error(ctx, response.ErrForm, err)
+		return
+	}
+
+	before := conf.Fields()
+	restart, err := conf.Update(form)
+	if err != nil {
+		if errors.Is(err, config.ErrInvalid) {
+			response.ErrorWithTip(ctx, response.ErrForm, err.Error())
+			return
+		}
+		response.Error(ctx, response.ErrUnexpected, err)
+		return
+	}
+	after := conf.Fields()
+
+	auditRepo := database.NewRepository[repository.AuditRepository]()
+	if err := audit(ctx, auditRepo.Repository, "config."+conf.Scope()+".update", repository.AuditTargetConfig, nil,
+		configValues(before), configValues(after)); err != nil {
+		response.Error(ctx, response.ErrDBOperation, err)
+		return
+	}
+
+	response.Success(ctx, ConfigScope{
+		Scope:           conf.Scope(),
+		Fields:          after,
+		RestartRequired: restart,
+	})
+}
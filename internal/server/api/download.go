@@ -7,23 +7,26 @@ import (
 
 	"github.com/acgn-org/onest/internal/config"
 	"github.com/acgn-org/onest/internal/database"
+	"github.com/acgn-org/onest/internal/logfield"
 	"github.com/acgn-org/onest/internal/queue"
 	"github.com/acgn-org/onest/internal/server/response"
 	"github.com/acgn-org/onest/internal/source"
 	"github.com/acgn-org/onest/repository"
 	"github.com/acgn-org/onest/tools"
 	"github.com/gin-gonic/gin"
-	"github.com/jinzhu/copier"
+	"github.com/google/uuid"
 	"github.com/zelenin/go-tdlib/client"
 	"gorm.io/gorm"
 )
 
+type AddDownloadForm struct {
+	ItemID    uint  `json:"item_id" form:"item_id" binding:"required"`
+	MessageID int64 `json:"message_id" form:"message_id" binding:"required"`
+	Priority  int32 `json:"priority" form:"priority" binding:"min=1,max=32"`
+}
+
 func AddDownloadForItem(ctx *gin.Context) {
-	var form struct {
-		ItemID    uint  `json:"item_id" form:"item_id" binding:"required"`
-		MessageID int64 `json:"message_id" form:"message_id" binding:"required"`
-		Priority  int32 `json:"priority" form:"priority" binding:"min=1,max=32"`
-	}
+	var form AddDownloadForm
 	if err := ctx.ShouldBind(&form); err != nil {
 		response.Error(ctx, response.ErrForm, err)
 		return
@@ -46,7 +49,13 @@ func AddDownloadForItem(ctx *gin.Context) {
 		return
 	}
 
-	msg, err := source.Telegram.GetMessage(_ctx, item.ChannelID, form.MessageID)
+	account, err := source.AccountForChannel(_ctx, item.ChannelID, item.Account)
+	if err != nil {
+		accountError(ctx, err)
+		return
+	}
+
+	msg, err := account.Telegram.GetMessage(_ctx, item.ChannelID, form.MessageID)
 	if err != nil {
 		response.Error(ctx, response.ErrTelegram, err)
 		return
@@ -66,39 +75,53 @@ func AddDownloadForItem(ctx *gin.Context) {
 		return
 	}
 
+	after, err := downloadRepo.GetByID(result[0].ID)
+	if err != nil {
+		response.Error(ctx, response.ErrDBOperation, err)
+		return
+	}
+	if err := audit(ctx, itemRepo.Repository, "download.create", repository.AuditTargetDownload, []uint{result[0].ID}, nil, after); err != nil {
+		response.Error(ctx, response.ErrDBOperation, err)
+		return
+	}
+
 	if err := itemRepo.Commit().Error; err != nil {
 		response.Error(ctx, response.ErrDBOperation, err)
 		return
 	}
 
+	queue.PublishTasksQueued(result[0].ID)
 	queue.TryActivateTaskControl()
 
 	response.Default(ctx)
 }
 
 func GetDownloadTasks(ctx *gin.Context) {
-	tasksActive, err := queue.GetDownloading()
-	if err != nil {
-		response.Error(ctx, response.ErrDBOperation, err)
+	var filter repository.DownloadFilter
+	if err := ctx.ShouldBind(&filter); err != nil {
+		response.Error(ctx, response.ErrForm, err)
 		return
 	}
-	downloadWaiting, err := database.NewRepository[repository.DownloadRepository]().GetForDownload(nil)
-	if err != nil {
-		response.Error(ctx, response.ErrDBOperation, err)
-		return
+	if filter.State == "" {
+		filter.State = repository.DownloadStateQueued
+	}
+	if filter.Sort == "" {
+		filter.Sort = "queue"
 	}
 
-	tasks := make([]repository.DownloadTask, 0, len(tasksActive)+len(downloadWaiting))
-	tasks = append(tasks, tasksActive...)
-	for _, download := range downloadWaiting {
-		var task repository.DownloadTask
-		if err := copier.Copy(&task, download.Download); err != nil {
-			response.Error(ctx, response.ErrUnexpected, err)
+	page, err := database.NewRepository[repository.DownloadRepository]().GetPage(&filter)
+	if err != nil {
+		if errors.Is(err, repository.ErrInvalidCursor) {
+			response.Error(ctx, response.ErrForm, err)
 			return
 		}
-		tasks = append(tasks, task)
+		response.Error(ctx, response.ErrDBOperation, err)
+		return
 	}
-	response.Success(ctx, tasks)
+
+	queue.MigrateDownloadTaskInfo(page.Items)
+
+	response.Success(ctx, page)
 }
 
 func ForceStartTask(ctx *gin.Context) {
@@ -111,8 +134,9 @@ func ForceStartTask(ctx *gin.Context) {
 	_ctx, cancel := context.WithTimeout(ctx, time.Second*time.Duration(config.Server.Get().Timeout))
 	defer cancel()
 
-	downloadRepo := database.NewRepository[repository.DownloadRepository]()
-	downloadRepo.DB = downloadRepo.DB.WithContext(_ctx)
+	downloadRepo := database.BeginRepositoryWithContext[repository.DownloadRepository](_ctx)
+	defer downloadRepo.Rollback()
+
 	downloadTask, err := downloadRepo.FirstByIDWithChannelID(id)
 	if err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
@@ -123,7 +147,32 @@ func ForceStartTask(ctx *gin.Context) {
 		return
 	}
 
-	if err := queue.ForceAddDownloadQueue(ctx, downloadTask.ChannelID, downloadTask.Download); err != nil {
+	account, err := source.AccountForChannel(_ctx, downloadTask.ChannelID, downloadTask.ItemAccount)
+	if err != nil {
+		accountError(ctx, err)
+		return
+	}
+
+	// the state change is committed along with the audit, the task is started afterward
+	if !downloadTask.Downloading || downloadTask.Account != account.Name {
+		if err := downloadRepo.SetDownloading(id, account.Name); err != nil {
+			response.Error(ctx, response.ErrDBOperation, err)
+			return
+		}
+		downloadTask.Downloading, downloadTask.Account = true, account.Name
+	}
+
+	if err := audit(ctx, downloadRepo.Repository, "download.start", repository.AuditTargetDownload, []uint{id}, nil, nil); err != nil {
+		response.Error(ctx, response.ErrDBOperation, err)
+		return
+	}
+
+	if err := downloadRepo.Commit().Error; err != nil {
+		response.Error(ctx, response.ErrDBOperation, err)
+		return
+	}
+
+	if err := queue.ForceAddDownloadQueue(ctx, account, downloadTask.ChannelID, downloadTask.Download); err != nil {
 		response.Error(ctx, response.ErrUnexpected, err)
 		return
 	}
@@ -141,6 +190,12 @@ func ForceResetTask(ctx *gin.Context) {
 	downloadRepo := database.BeginRepository[repository.DownloadRepository]()
 	defer downloadRepo.Rollback()
 
+	before, err := downloadRepo.GetByID(id)
+	if err != nil {
+		response.Error(ctx, response.ErrDBOperation, err)
+		return
+	}
+
 	ok, err := downloadRepo.UpdateResetDownloadState(id)
 	if err != nil {
 		response.Error(ctx, response.ErrDBOperation, err)
@@ -150,6 +205,22 @@ func ForceResetTask(ctx *gin.Context) {
 		return
 	}
 
+	companionRepo := repository.CompanionRepository{Repository: downloadRepo.Repository}
+	if err := companionRepo.ResetByDownloadID(id); err != nil {
+		response.Error(ctx, response.ErrDBOperation, err)
+		return
+	}
+
+	after, err := downloadRepo.GetByID(id)
+	if err != nil {
+		response.Error(ctx, response.ErrDBOperation, err)
+		return
+	}
+	if err := audit(ctx, downloadRepo.Repository, "download.reset", repository.AuditTargetDownload, []uint{id}, before, after); err != nil {
+		response.Error(ctx, response.ErrDBOperation, err)
+		return
+	}
+
 	queue.RemoveTasks(id)
 
 	if err := downloadRepo.Commit().Error; err != nil {
@@ -157,15 +228,18 @@ func ForceResetTask(ctx *gin.Context) {
 		return
 	}
 
+	queue.PublishTasksQueued(id)
 	queue.TryActivateTaskControl()
 
 	response.Default(ctx)
 }
 
+type PriorityForm struct {
+	Priority int32 `json:"priority" form:"priority" binding:"min=1,max=32"`
+}
+
 func UpdateDownloadPriority(ctx *gin.Context) {
-	var form struct {
-		Priority int32 `json:"priority" form:"priority" binding:"min=1,max=32"`
-	}
+	var form PriorityForm
 	if err := ctx.ShouldBind(&form); err != nil {
 		response.Error(ctx, response.ErrForm, err)
 		return
@@ -180,6 +254,12 @@ func UpdateDownloadPriority(ctx *gin.Context) {
 	downloadRepo := database.BeginRepository[repository.DownloadRepository]()
 	defer downloadRepo.Rollback()
 
+	before, err := downloadRepo.GetByID(id)
+	if err != nil {
+		response.Error(ctx, response.ErrDBOperation, err)
+		return
+	}
+
 	ok, err := downloadRepo.UpdatePriority(id, form.Priority)
 	if err != nil {
 		response.Error(ctx, response.ErrDBOperation, err)
@@ -189,6 +269,16 @@ func UpdateDownloadPriority(ctx *gin.Context) {
 		return
 	}
 
+	after, err := downloadRepo.GetByID(id)
+	if err != nil {
+		response.Error(ctx, response.ErrDBOperation, err)
+		return
+	}
+	if err := audit(ctx, downloadRepo.Repository, "download.priority", repository.AuditTargetDownload, []uint{id}, before, after); err != nil {
+		response.Error(ctx, response.ErrDBOperation, err)
+		return
+	}
+
 	if err := downloadRepo.Commit().Error; err != nil {
 		response.Error(ctx, response.ErrDBOperation, err)
 		return
@@ -214,6 +304,12 @@ func DeleteDownload(ctx *gin.Context) {
 	downloadRepo := database.BeginRepository[repository.DownloadRepository]()
 	defer downloadRepo.Rollback()
 
+	before, err := downloadRepo.GetByID(id)
+	if err != nil {
+		response.Error(ctx, response.ErrDBOperation, err)
+		return
+	}
+
 	ok, err := downloadRepo.DeleteByID(id)
 	if err != nil {
 		response.Error(ctx, response.ErrDBOperation, err)
@@ -223,6 +319,17 @@ func DeleteDownload(ctx *gin.Context) {
 		return
 	}
 
+	companionRepo := repository.CompanionRepository{Repository: downloadRepo.Repository}
+	if err := companionRepo.DeleteByDownloadID(id); err != nil {
+		response.Error(ctx, response.ErrDBOperation, err)
+		return
+	}
+
+	if err := audit(ctx, downloadRepo.Repository, "download.delete", repository.AuditTargetDownload, []uint{id}, before, nil); err != nil {
+		response.Error(ctx, response.ErrDBOperation, err)
+		return
+	}
+
 	if err := downloadRepo.Commit().Error; err != nil {
 		response.Error(ctx, response.ErrDBOperation, err)
 		return
@@ -232,3 +339,239 @@ func DeleteDownload(ctx *gin.Context) {
 
 	response.Default(ctx)
 }
+
+func GetDownloadCompanions(ctx *gin.Context) {
+	id, err := tools.UintIDFromParam(ctx, "id")
+	if err != nil {
+		response.Error(ctx, response.ErrForm, err)
+		return
+	}
+
+	companionRepo := database.NewRepository[repository.CompanionRepository]()
+	companions, err := companionRepo.GetByDownloadID(id)
+	if err != nil {
+		response.Error(ctx, response.ErrDBOperation, err)
+		return
+	}
+
+	if companions == nil {
+		companions = make([]repository.Companion, 0)
+	}
+	response.Success(ctx, companions)
+}
+
+type BulkDownloadResult struct {
+	ID    uint   `json:"id"`
+	Ok    bool   `json:"ok"`
+	Error string `json:"error,omitempty"`
+}
+
+type BulkDownloadsForm struct {
+	// pause and start skip completed downloads, start clears errors of failed ones
+	Action   string                     `json:"action" form:"action" binding:"required,oneof=reset delete priority pause start"`
+	IDs      []uint                     `json:"ids" form:"ids"`
+	Filter   *repository.DownloadFilter `json:"filter" form:"filter"`
+	Priority int32                      `json:"priority" form:"priority" binding:"omitempty,min=1,max=32"`
+}
+
+func BulkDownloads(ctx *gin.Context) {
+	var form BulkDownloadsForm
+	if err := ctx.ShouldBind(&form); err != nil {
+		response.Error(ctx, response.ErrForm, err)
+		return
+	}
+	if len(form.IDs) == 0 && form.Filter == nil {
+		response.ErrorWithTip(ctx, response.ErrForm, "ids or filter is required")
+		return
+	} else if form.Filter != nil && !form.Filter.HasCriteria() {
+		// an empty filter matches every download
+		response.ErrorWithTip(ctx, response.ErrForm, "filter has no criteria")
+		return
+	} else if form.Action == "priority" && form.Priority == 0 {
+		response.ErrorWithTip(ctx, response.ErrForm, "priority is required")
+		return
+	}
+
+	downloadRepo := database.BeginRepository[repository.DownloadRepository]()
+	defer downloadRepo.Rollback()
+
+	downloads, err := downloadRepo.GetWithChannelIDForUpdates(form.IDs, form.Filter)
+	if err != nil {
+		response.Error(ctx, response.ErrDBOperation, err)
+		return
+	}
+
+	var ids = make([]uint, len(downloads))
+	var results = make([]BulkDownloadResult, 0, len(downloads)+len(form.IDs))
+	var resultIndex = make(map[uint]int, len(downloads))
+	for i, download := range downloads {
+		ids[i] = download.ID
+		resultIndex[download.ID] = len(results)
+		results = append(results, BulkDownloadResult{ID: download.ID, Ok: true})
+	}
+	for _, id := range form.IDs {
+		if _, ok := resultIndex[id]; !ok {
+			resultIndex[id] = len(results)
+			results = append(results, BulkDownloadResult{ID: id, Error: response.ErrNotFound.Msg})
+		}
+	}
+	// completed downloads are neither paused nor started, failed ones are started again
+	var failedIDs []uint
+	if form.Action == "pause" || form.Action == "start" {
+		ids = ids[:0]
+		for _, download := range downloads {
+			if download.Downloaded && (form.Action == "pause" || !download.FatalError) {
+				result := &results[resultIndex[download.ID]]
+				result.Ok, result.Error = false, "download is completed"
+				continue
+			}
+			ids = append(ids, download.ID)
+			if download.FatalError || download.ErrorAt != 0 {
+				failedIDs = append(failedIDs, download.ID)
+			}
+		}
+	}
+	if len(ids) == 0 {
+		response.Success(ctx, results)
+		return
+	}
+
+	before, err := downloadRepo.GetByID(ids...)
+	if err != nil {
+		response.Error(ctx, response.ErrDBOperation, err)
+		return
+	}
+
+	companionRepo := repository.CompanionRepository{Repository: downloadRepo.Repository}
+	switch form.Action {
+	case "reset":
+		err = downloadRepo.UpdateResetDownloadStateByIDs(ids...)
+		if err == nil {
+			err = companionRepo.ResetByDownloadID(ids...)
+		}
+	case "delete":
+		err = downloadRepo.DeleteByIDs(ids...)
+		if err == nil {
+			err = companionRepo.DeleteByDownloadID(ids...)
+		}
+	case "priority":
+		err = downloadRepo.UpdatePriorityByIDs(form.Priority, ids...)
+	case "pause":
+		err = downloadRepo.UpdatePausedByIDs(ids...)
+	case "start":
+		err = downloadRepo.UpdateStartByIDs(ids...)
+	}
+	if err != nil {
+		response.Error(ctx, response.ErrDBOperation, err)
+		return
+	}
+
+	var after []repository.DownloadTask
+	if form.Action != "delete" {
+		after, err = downloadRepo.GetByID(ids...)
+		if err != nil {
+			response.Error(ctx, response.ErrDBOperation, err)
+			return
+		}
+	}
+	if err := audit(ctx, downloadRepo.Repository, "download.bulk."+form.Action, repository.AuditTargetDownload, ids, before, after); err != nil {
+		response.Error(ctx, response.ErrDBOperation, err)
+		return
+	}
+
+	switch form.Action {
+	case "reset":
+		queue.RemoveTasks(ids...)
+	case "start":
+		// error count of tasks is restarted
+		queue.RemoveTasks(failedIDs...)
+	}
+
+	if err := downloadRepo.Commit().Error; err != nil {
+		response.Error(ctx, response.ErrDBOperation, err)
+		return
+	}
+
+	_ctx, cancel := context.WithTimeout(ctx, time.Second*time.Duration(config.Server.Get().Timeout))
+	defer cancel()
+
+	switch form.Action {
+	case "reset":
+		queue.PublishTasksQueued(ids...)
+	case "delete":
+		queue.RemoveTasks(ids...)
+	case "pause":
+		queue.PauseTasks(ids...)
+	case "priority":
+		for _, id := range ids {
+			if err := queue.UpdatePriority(_ctx, id, form.Priority); err != nil {
+				result := &results[resultIndex[id]]
+				result.Ok, result.Error = false, err.Error()
+			}
+		}
+	case "start":
+		// queued by task control within parallel downloads of accounts
+		queue.PublishTasksQueued(ids...)
+	}
+
+	queue.TryActivateTaskControl()
+
+	response.Success(ctx, results)
+}
+
+type WatchDownloadsForm struct {
+	IDs []uint `json:"ids" form:"ids"`
+}
+
+func WatchDownloads(ctx *gin.Context) {
+	var form WatchDownloadsForm
+	if err := ctx.ShouldBind(&form); err != nil {
+		response.Error(ctx, response.ErrForm, err)
+		return
+	}
+	var watching = make(map[uint]struct{}, len(form.IDs))
+	for _, id := range form.IDs {
+		watching[id] = struct{}{}
+	}
+
+	logger := logfield.New(logfield.ComServer).WithAction("websocket").WithField("id", uuid.New().String())
+
+	conn, err := tools.WebsocketUpgrader.Upgrade(ctx.Writer, ctx.Request, nil)
+	if err != nil {
+		logger.Warnln("upgrade connection failed:", err)
+		response.Error(ctx, response.ErrForm, err)
+		return
+	}
+	defer conn.Close()
+
+	closed := make(chan struct{})
+	go func() {
+		defer close(closed)
+		for { // drop all messages
+			_, _, err := conn.ReadMessage()
+			if err != nil {
+				return
+			}
+		}
+	}()
+
+	events, unsubscribe := queue.SubscribeTaskEvents()
+	defer unsubscribe()
+
+	for {
+		select {
+		case <-closed:
+			return
+		case event := <-events:
+			if len(watching) != 0 {
+				if _, ok := watching[event.ID]; !ok {
+					continue
+				}
+			}
+			if err := conn.WriteJSON(event); err != nil {
+				logger.Debugln("failed to write event, exiting:", err)
+				return
+			}
+		}
+	}
+}
@@ -19,31 +19,65 @@ import (
 )
 
 func GetItemDownloads(ctx *gin.Context) {
+	var filter repository.DownloadFilter
+	if err := ctx.ShouldBind(&filter); err != nil {
+		response.Error(ctx, response.ErrForm, err)
+		return
+	}
+
 	id, err := tools.UintIDFromParam(ctx, "id")
 	if err != nil {
 		response.Error(ctx, response.ErrForm, err)
 		return
 	}
+	filter.ItemID = id
 
 	downloadRepo := database.NewRepository[repository.DownloadRepository]()
-	tasks, err := downloadRepo.GetByItemID(id)
+	page, err := downloadRepo.GetPage(&filter)
 	if err != nil {
+		if errors.Is(err, repository.ErrInvalidCursor) {
+			response.Error(ctx, response.ErrForm, err)
+			return
+		}
 		response.Error(ctx, response.ErrDBOperation, err)
 		return
 	}
 
-	queue.MigrateDownloadTaskInfo(tasks)
+	queue.MigrateDownloadTaskInfo(page.Items)
+
+	response.Success(ctx, page)
+}
 
-	if tasks == nil {
-		tasks = make([]repository.DownloadTask, 0)
+func GetItems(ctx *gin.Context) {
+	var filter repository.ItemFilter
+	if err := ctx.ShouldBind(&filter); err != nil {
+		response.Error(ctx, response.ErrForm, err)
+		return
 	}
-	response.Success(ctx, tasks)
+	if filter.ActiveAfter == 0 {
+		filter.ActiveAfter = int32(time.Now().Add(-time.Duration(config.Telegram.Get().ScanThresholdDays) * time.Hour * 24).Unix())
+	}
+
+	itemRepo := database.NewRepository[repository.ItemRepository]()
+	page, err := itemRepo.GetPage(&filter)
+	if err != nil {
+		if errors.Is(err, repository.ErrInvalidCursor) {
+			response.Error(ctx, response.ErrForm, err)
+			return
+		}
+		response.Error(ctx, response.ErrDBOperation, err)
+		return
+	}
+
+	response.Success(ctx, page)
+}
+
+type ActiveItemsForm struct {
+	ActiveAfter int32 `form:"active_after" json:"active_after" binding:"min=0"`
 }
 
 func GetActiveItems(ctx *gin.Context) {
-	var form struct {
-		ActiveAfter int32 `form:"active_after" json:"active_after" binding:"min=0"`
-	}
+	var form ActiveItemsForm
 	if err := ctx.ShouldBind(&form); err != nil {
 		response.Error(ctx, response.ErrForm, err)
 		return
@@ -97,27 +131,46 @@ func GetItemByID(ctx *gin.Context) {
 	response.Success(ctx, item)
 }
 
+type NewItemForm struct {
+	repository.NewItemForm
+	Downloads []repository.DownloadForm `json:"downloads" form:"downloads"`
+}
+
 func NewItem(ctx *gin.Context) {
-	var form struct {
-		repository.NewItemForm
-		Downloads []repository.DownloadForm `json:"downloads" form:"downloads"`
-	}
+	var form NewItemForm
 	if err := ctx.ShouldBind(&form); err != nil {
 		response.Error(ctx, response.ErrForm, err)
 		return
 	}
 
-	itemRegexp, err := regexp.Compile(form.Regexp)
-	if err != nil {
+	if _, err := regexp.Compile(form.Regexp); err != nil {
 		response.Error(ctx, response.ErrForm, err)
 		return
 	}
+	if err := queue.CompileMatchRules(form.MatchRules); err != nil {
+		response.ErrorWithTip(ctx, response.ErrForm, err.Error())
+		return
+	}
+	if _, err := queue.CompileCompanionRules(form.CompanionRules); err != nil {
+		response.ErrorWithTip(ctx, response.ErrForm, err.Error())
+		return
+	}
 
 	_ctx, cancel := context.WithTimeout(ctx, time.Second*time.Duration(config.Server.Get().Timeout))
 	defer cancel()
 
+	account, err := source.AccountForChannel(_ctx, form.ChannelID, form.Account)
+	if err != nil {
+		accountError(ctx, err)
+		return
+	} else if form.Account != "" && account.Name != form.Account {
+		response.ErrorWithTip(ctx, response.ErrForm, fmt.Sprintf("channel is not accessible by account %s", form.Account))
+		return
+	}
+	form.Account = account.Name
+
 	if form.Process == 0 {
-		messages, err := source.Telegram.GetHistory(_ctx, form.ChannelID, 0, 1)
+		messages, err := account.Telegram.GetHistory(_ctx, form.ChannelID, 0, 1)
 		if err != nil {
 			response.Error(ctx, response.ErrTelegram, err)
 			return
@@ -138,20 +191,26 @@ func NewItem(ctx *gin.Context) {
 		return
 	}
 
+	matcher, err := queue.NewMatcher(item)
+	if err != nil {
+		response.Error(ctx, response.ErrForm, err)
+		return
+	}
+
 	var downloadModels = make([]repository.Download, 0, len(form.Downloads))
 	for _, download := range form.Downloads {
-		msg, err := source.Telegram.GetMessage(_ctx, item.ChannelID, download.MsgID)
+		msg, err := account.Telegram.GetMessage(_ctx, item.ChannelID, download.MsgID)
 		if err != nil {
 			response.Error(ctx, response.ErrTelegram, err)
 			return
 		}
-		messageVideo, ok := source.Telegram.GetMessageVideo(msg)
+		messageVideo, ok := account.Telegram.GetMessageVideo(msg)
 		if !ok {
 			response.ErrorWithTip(ctx, response.ErrForm, fmt.Sprintf("message %d is not video message", download.MsgID))
 			return
 		}
-		if tools.ConvertPatternRegexp(messageVideo.Caption.Text, itemRegexp, form.MatchPattern) != form.MatchContent {
-			response.ErrorWithTip(ctx, response.ErrForm, fmt.Sprintf("message %d not matched with match pattern", download.MsgID))
+		if !matcher.Match(messageVideo) {
+			response.ErrorWithTip(ctx, response.ErrForm, fmt.Sprintf("message %d not matched with match rules", download.MsgID))
 			return
 		}
 		downloadModels = append(downloadModels, repository.Download{
@@ -171,11 +230,20 @@ func NewItem(ctx *gin.Context) {
 		}
 	}
 
+	if err := audit(ctx, itemRepo.Repository, "item.create", repository.AuditTargetItem, []uint{item.ID},
+		nil, itemSnapshot{Item: item, DownloadIDs: idsOfDownloads(downloadModels)}); err != nil {
+		response.Error(ctx, response.ErrDBOperation, err)
+		return
+	}
+
 	if err := itemRepo.Commit().Error; err != nil {
 		response.Error(ctx, response.ErrDBOperation, err)
 		return
 	}
 
+	for _, download := range downloadModels {
+		queue.PublishTasksQueued(download.ID)
+	}
 	queue.TryActivateTaskControl()
 
 	response.Default(ctx)
@@ -191,7 +259,7 @@ func DeleteItem(ctx *gin.Context) {
 	itemRepo := database.BeginRepository[repository.ItemRepository]()
 	defer itemRepo.Rollback()
 
-	_, err = itemRepo.FirstItemByIDForUpdates(id)
+	item, err := itemRepo.FirstItemByIDForUpdates(id)
 	if err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
 			response.Error(ctx, response.ErrNotFound)
@@ -219,6 +287,20 @@ func DeleteItem(ctx *gin.Context) {
 		return
 	}
 
+	if len(downloadIDs) != 0 {
+		companionRepo := repository.CompanionRepository{Repository: itemRepo.Repository}
+		if err := companionRepo.DeleteByDownloadID(downloadIDs...); err != nil {
+			response.Error(ctx, response.ErrDBOperation, err)
+			return
+		}
+	}
+
+	if err := audit(ctx, itemRepo.Repository, "item.delete", repository.AuditTargetItem, []uint{id},
+		itemSnapshot{Item: item, DownloadIDs: downloadIDs}, nil); err != nil {
+		response.Error(ctx, response.ErrDBOperation, err)
+		return
+	}
+
 	queue.RemoveTasks(downloadIDs...)
 
 	if err := itemRepo.Commit().Error; err != nil {
@@ -244,9 +326,34 @@ func PatchItem(ctx *gin.Context) {
 		return
 	}
 
+	if form.Regexp != "" {
+		if _, err := regexp.Compile(form.Regexp); err != nil {
+			response.Error(ctx, response.ErrForm, err)
+			return
+		}
+	}
+	if err := queue.CompileMatchRules(form.MatchRules); err != nil {
+		response.ErrorWithTip(ctx, response.ErrForm, err.Error())
+		return
+	}
+	if _, err := queue.CompileCompanionRules(form.CompanionRules); err != nil {
+		response.ErrorWithTip(ctx, response.ErrForm, err.Error())
+		return
+	}
+
 	itemRepo := database.BeginRepository[repository.ItemRepository]()
 	defer itemRepo.Rollback()
 
+	before, err := itemRepo.FirstItemByIDForUpdates(id)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			response.Error(ctx, response.ErrNotFound)
+			return
+		}
+		response.Error(ctx, response.ErrDBOperation, err)
+		return
+	}
+
 	ok, err := itemRepo.UpdatesItemWithForm(id, &form)
 	if err != nil {
 		response.Error(ctx, response.ErrDBOperation, err)
@@ -256,6 +363,16 @@ func PatchItem(ctx *gin.Context) {
 		return
 	}
 
+	after, err := itemRepo.FirstItemByID(id)
+	if err != nil {
+		response.Error(ctx, response.ErrDBOperation, err)
+		return
+	}
+	if err := audit(ctx, itemRepo.Repository, "item.update", repository.AuditTargetItem, []uint{id}, before, after); err != nil {
+		response.Error(ctx, response.ErrDBOperation, err)
+		return
+	}
+
 	if err := itemRepo.Commit().Error; err != nil {
 		response.Error(ctx, response.ErrDBOperation, err)
 		return
@@ -263,3 +380,264 @@ func PatchItem(ctx *gin.Context) {
 
 	response.Default(ctx)
 }
+
+func GetItemRenamePlan(ctx *gin.Context) {
+	id, err := tools.UintIDFromParam(ctx, "id")
+	if err != nil {
+		response.Error(ctx, response.ErrForm, err)
+		return
+	}
+
+	itemRepo := database.NewRepository[repository.ItemRepository]()
+	item, err := itemRepo.FirstItemByID(id)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			response.Error(ctx, response.ErrNotFound)
+			return
+		}
+		response.Error(ctx, response.ErrDBOperation, err)
+		return
+	}
+
+	downloadRepo := repository.DownloadRepository{Repository: itemRepo.Repository}
+	downloads, err := downloadRepo.GetDownloadedByItemID(id)
+	if err != nil {
+		response.Error(ctx, response.ErrDBOperation, err)
+		return
+	}
+
+	companionRepo := repository.CompanionRepository{Repository: itemRepo.Repository}
+	companions, err := companionRepo.GetByDownloadID(idsOfDownloads(downloads)...)
+	if err != nil {
+		response.Error(ctx, response.ErrDBOperation, err)
+		return
+	}
+
+	plan, err := queue.PlanRename(item, downloads, companions)
+	if err != nil {
+		response.Error(ctx, response.ErrUnexpected, err)
+		return
+	}
+
+	response.Success(ctx, plan)
+}
+
+type RenameItemForm struct {
+	// hash of the plan previewed, the rename is rejected if the plan changed since
+	Hash string `json:"hash" binding:"required"`
+}
+
+func RenameItemFiles(ctx *gin.Context) {
+	id, err := tools.UintIDFromParam(ctx, "id")
+	if err != nil {
+		response.Error(ctx, response.ErrForm, err)
+		return
+	}
+
+	var form RenameItemForm
+	if err := ctx.ShouldBindJSON(&form); err != nil {
+		response.Error(ctx, response.ErrForm, err)
+		return
+	}
+
+	itemRepo := database.BeginRepository[repository.ItemRepository]()
+	defer itemRepo.Rollback()
+
+	item, err := itemRepo.FirstItemByIDForUpdates(id)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			response.Error(ctx, response.ErrNotFound)
+			return
+		}
+		response.Error(ctx, response.ErrDBOperation, err)
+		return
+	}
+
+	downloadRepo := repository.DownloadRepository{Repository: itemRepo.Repository}
+	downloads, err := downloadRepo.GetDownloadedByItemIDForUpdates(id)
+	if err != nil {
+		response.Error(ctx, response.ErrDBOperation, err)
+		return
+	}
+
+	companionRepo := repository.CompanionRepository{Repository: itemRepo.Repository}
+	companions, err := companionRepo.GetDownloadedByDownloadIDForUpdates(idsOfDownloads(downloads)...)
+	if err != nil {
+		response.Error(ctx, response.ErrDBOperation, err)
+		return
+	}
+
+	plan, err := queue.PlanRename(item, downloads, companions)
+	if err != nil {
+		response.Error(ctx, response.ErrUnexpected, err)
+		return
+	} else if plan.Hash != form.Hash {
+		response.ErrorWithTip(ctx, response.ErrResourceConflict, "rename plan changed, preview it again")
+		return
+	} else if plan.HasConflict() {
+		response.ErrorWithTip(ctx, response.ErrResourceConflict, "rename plan contains conflicts")
+		return
+	}
+
+	if err := updateRenamePaths(itemRepo.Repository, plan, false); err != nil {
+		response.Error(ctx, response.ErrDBOperation, err)
+		return
+	}
+	if err := audit(ctx, itemRepo.Repository, "item.rename", repository.AuditTargetItem, []uint{id}, nil, plan); err != nil {
+		response.Error(ctx, response.ErrDBOperation, err)
+		return
+	}
+	if err := itemRepo.Commit().Error; err != nil {
+		response.Error(ctx, response.ErrDBOperation, err)
+		return
+	}
+
+	// files are moved without locks held, moving across devices copies them
+	if _, err := queue.ExecuteRename(plan); err != nil {
+		revertRepo := database.BeginRepository[repository.ItemRepository]()
+		defer revertRepo.Rollback()
+		if revertErr := updateRenamePaths(revertRepo.Repository, plan, true); revertErr != nil {
+			response.Error(ctx, response.ErrDBOperation, fmt.Errorf("%w, revert paths failed: %w", err, revertErr))
+			return
+		}
+		if revertErr := audit(ctx, revertRepo.Repository, "item.rename.revert", repository.AuditTargetItem, []uint{id}, plan, nil); revertErr != nil {
+			response.Error(ctx, response.ErrDBOperation, fmt.Errorf("%w, revert paths failed: %w", err, revertErr))
+			return
+		}
+		if revertErr := revertRepo.Commit().Error; revertErr != nil {
+			response.Error(ctx, response.ErrDBOperation, fmt.Errorf("%w, revert paths failed: %w", err, revertErr))
+			return
+		}
+		response.Error(ctx, response.ErrUnexpected, err)
+		return
+	}
+
+	response.Success(ctx, plan)
+}
+
+// updateRenamePaths records targets of steps, or sources if reverting
+func updateRenamePaths(repo repository.Repository, plan *queue.RenamePlan, revert bool) error {
+	downloadRepo := repository.DownloadRepository{Repository: repo}
+	companionRepo := repository.CompanionRepository{Repository: repo}
+	for _, step := range plan.Steps {
+		pathname := step.To
+		if revert {
+			pathname = step.From
+		}
+		var err error
+		if step.CompanionID != 0 {
+			err = companionRepo.UpdatePath(step.CompanionID, pathname)
+		} else {
+			err = downloadRepo.UpdatePath(step.DownloadID, pathname)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func idsOfDownloads(downloads []repository.Download) []uint {
+	ids := make([]uint, len(downloads))
+	for i, download := range downloads {
+		ids[i] = download.ID
+	}
+	return ids
+}
+
+type ExportItemsForm struct {
+	IDs       []uint `json:"ids" form:"ids"`
+	Format    string `json:"format" form:"format" binding:"omitempty,oneof=json yaml"`
+	Downloads bool   `json:"downloads" form:"downloads"`
+}
+
+func ExportItems(ctx *gin.Context) {
+	var form ExportItemsForm
+	if err := ctx.ShouldBind(&form); err != nil {
+		response.Error(ctx, response.ErrForm, err)
+		return
+	}
+	if form.Format == "" {
+		form.Format = "json"
+	}
+
+	itemRepo := database.NewRepository[repository.ItemRepository]()
+	export, err := queue.ExportItems(itemRepo, form.IDs, form.Downloads)
+	if err != nil {
+		if errors.Is(err, queue.ErrItemsNotFound) {
+			response.ErrorWithTip(ctx, response.ErrNotFound, err.Error())
+			return
+		}
+		response.Error(ctx, response.ErrDBOperation, err)
+		return
+	}
+
+	ctx.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="onest-items-%s.%s"`, time.Unix(export.ExportedAt, 0).Format("20060102"), form.Format))
+	if form.Format == "yaml" {
+		ctx.YAML(200, export)
+	} else {
+		ctx.JSON(200, export)
+	}
+}
+
+type ImportItemsForm struct {
+	DryRun   bool   `form:"dry_run"`
+	Conflict string `form:"conflict" binding:"omitempty,oneof=skip overwrite rename"`
+}
+
+func ImportItems(ctx *gin.Context) {
+	var form ImportItemsForm
+	if err := ctx.ShouldBindQuery(&form); err != nil {
+		response.Error(ctx, response.ErrForm, err)
+		return
+	}
+	if form.Conflict == "" {
+		form.Conflict = repository.ImportConflictSkip
+	}
+
+	var export repository.ItemExport
+	if err := ctx.ShouldBind(&export); err != nil {
+		response.Error(ctx, response.ErrForm, err)
+		return
+	}
+	report, ok, err := queue.NewItemImportReport(&export, form.DryRun)
+	if err != nil {
+		response.ErrorWithTip(ctx, response.ErrForm, err.Error())
+		return
+	} else if !ok {
+		response.Success(ctx, report)
+		return
+	}
+
+	if form.DryRun {
+		if err := queue.ImportItems(database.NewRepository[repository.ItemRepository](), &export, form.Conflict, report); err != nil {
+			response.Error(ctx, response.ErrDBOperation, err)
+			return
+		}
+		response.Success(ctx, report)
+		return
+	}
+
+	itemRepo := database.BeginRepository[repository.ItemRepository]()
+	defer itemRepo.Rollback()
+
+	if err := queue.ImportItems(itemRepo, &export, form.Conflict, report); err != nil {
+		response.Error(ctx, response.ErrDBOperation, err)
+		return
+	}
+
+	if err := audit(ctx, itemRepo.Repository, "item.import", repository.AuditTargetItem, report.ItemIDs(), nil, report.Items); err != nil {
+		response.Error(ctx, response.ErrDBOperation, err)
+		return
+	}
+
+	if err := itemRepo.Commit().Error; err != nil {
+		response.Error(ctx, response.ErrDBOperation, err)
+		return
+	}
+	report.Imported = true
+
+	queue.TryActivateTaskControl()
+
+	response.Success(ctx, report)
+}
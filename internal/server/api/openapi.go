@@ -0,0 +1,207 @@
+package api
+
+import (
+	"github.com/acgn-org/onest/internal/queue"
+	"github.com/acgn-org/onest/internal/server/openapi"
+	"github.com/acgn-org/onest/repository"
+	"github.com/acgn-org/onest/telegram"
+	"github.com/gin-gonic/gin"
+	"github.com/zelenin/go-tdlib/client"
+)
+
+// Operations documents handlers by name, update it along with routes and forms
+var Operations = map[string]openapi.Operation{
+	"RealSearchProxy": {
+		Summary: "proxy requests to real search api",
+		Raw:     "application/json",
+	},
+
+	"GetAuthStatus": {
+		Summary:  "whether authentication is enabled and the caller is authenticated",
+		Response: AuthStatus{},
+	},
+	"Login": {
+		Summary: "sign in the admin account, session is kept in cookie",
+		Body:    LoginForm{},
+	},
+	"Logout": {
+		Summary: "sign out current session",
+	},
+	"ChangePassword": {
+		Summary: "change password of the admin account, all sessions are signed out",
+		Body:    ChangePasswordForm{},
+	},
+	"GetApiTokens": {
+		Summary:  "list api tokens",
+		Response: []repository.ApiToken{},
+	},
+	"NewApiToken": {
+		Summary:  "create api token, used as bearer token in authorization header",
+		Body:     NewApiTokenForm{},
+		Response: NewApiTokenResult{},
+	},
+	"DeleteApiToken": {
+		Summary: "revoke api token",
+	},
+
+	"GetConfig": {
+		Summary:  "config of scope server, telegram or realsearch, secrets are masked",
+		Response: ConfigScope{},
+	},
+	"PatchConfig": {
+		Summary:  "change config keys of scope, changes are saved to config file and applied live where possible",
+		Body:     map[string]any{},
+		Response: ConfigScope{},
+	},
+
+	"GetAuditLogs": {
+		Summary:  "page of audit logs of changes, newest first",
+		Query:    repository.AuditFilter{},
+		Response: repository.Page[repository.AuditLog]{},
+	},
+
+	"GetItems": {
+		Summary:  "list items with download counts",
+		Query:    repository.ItemFilter{},
+		Response: repository.Page[repository.ItemWithStats]{},
+	},
+	"GetActiveItems": {
+		Summary:  "list items with pending downloads or updated after active_after",
+		Query:    ActiveItemsForm{},
+		Response: []repository.Item{},
+	},
+	"GetErrorItems": {
+		Summary:  "list items with failed downloads",
+		Response: []repository.Item{},
+	},
+	"NewItem": {
+		Summary: "create item with optional downloads",
+		Body:    NewItemForm{},
+	},
+	"ExportItems": {
+		Summary:  "export item definitions as json or yaml",
+		Query:    ExportItemsForm{},
+		Response: repository.ItemExport{},
+		Raw:      "application/json",
+	},
+	"ImportItems": {
+		Summary:  "import item definitions, yaml body is accepted with yaml content type",
+		Query:    ImportItemsForm{},
+		Body:     repository.ItemExport{},
+		Response: queue.ItemImportReport{},
+	},
+	"GetItemByID": {
+		Summary:  "get item",
+		Response: repository.Item{},
+	},
+	"GetItemDownloads": {
+		Summary:  "list downloads of item",
+		Query:    repository.DownloadFilter{},
+		Response: repository.Page[repository.DownloadTask]{},
+	},
+	"GetItemRenamePlan": {
+		Summary:  "preview moving downloaded files to paths rendered by current item rules",
+		Response: queue.RenamePlan{},
+	},
+	"RenameItemFiles": {
+		Summary:  "move downloaded files as previewed, the hash of the plan previewed is required",
+		Body:     RenameItemForm{},
+		Response: queue.RenamePlan{},
+	},
+	"PatchItem": {
+		Summary: "update item",
+		Body:    repository.UpdateItemForm{},
+	},
+	"DeleteItem": {
+		Summary: "delete item and its downloads",
+	},
+
+	"AddDownloadForItem": {
+		Summary: "add message to downloads of item",
+		Body:    AddDownloadForm{},
+	},
+	"GetDownloadTasks": {
+		Summary:  "list downloads, queued downloads ordered by queue by default",
+		Query:    repository.DownloadFilter{},
+		Response: repository.Page[repository.DownloadTask]{},
+	},
+	"BulkDownloads": {
+		Summary:  "reset, delete, reprioritize, pause or start downloads in ids or matched by filter, start retries failed ones as well",
+		Body:     BulkDownloadsForm{},
+		Response: []BulkDownloadResult{},
+	},
+	"WatchDownloads": {
+		Summary:  "websocket streaming task events as json messages, throttled per task",
+		Query:    WatchDownloadsForm{},
+		Response: queue.TaskEvent{},
+		Raw:      "application/json",
+	},
+	"GetDownloadCompanions": {
+		Summary:  "list companion documents of download",
+		Response: []repository.Companion{},
+	},
+	"UpdateDownloadPriority": {
+		Summary: "update priority of download",
+		Body:    PriorityForm{},
+	},
+	"DeleteDownload": {
+		Summary: "delete download",
+	},
+	"ForceStartTask": {
+		Summary: "start download regardless of queue",
+	},
+	"ForceResetTask": {
+		Summary: "reset state of download and queue it again",
+	},
+
+	"WatchLogs": {
+		Summary: "websocket streaming log lines",
+		Raw:     "application/octet-stream",
+	},
+
+	"GetTelegramAccounts": {
+		Summary:  "telegram accounts with authorization state and number of running downloads",
+		Response: []TelegramAccount{},
+	},
+	"GetTelegramAuth": {
+		Summary:  "authorization state of telegram account, the application runs degraded until the default account is ready",
+		Query:    TelegramAccountForm{},
+		Response: TelegramAuthState{},
+	},
+	"SubmitTelegramAuth": {
+		Summary:  "submit phone number, code or password of telegram login, or switch to qr code login",
+		Body:     TelegramAuthForm{},
+		Response: TelegramAuthState{},
+	},
+	"GetTelegramProxies": {
+		Summary:  "configured proxies of telegram account with results of the last ping",
+		Query:    TelegramAccountForm{},
+		Response: []telegram.ProxyState{},
+	},
+	"EnableTelegramProxy": {
+		Summary:  "switch to a proxy, it is kept until it fails a ping",
+		Body:     TelegramProxyForm{},
+		Response: []telegram.ProxyState{},
+	},
+	"GetChat": {
+		Summary:  "get telegram chat",
+		Query:    TelegramChatForm{},
+		Response: client.Chat{},
+	},
+	"GetMessage": {
+		Summary:  "get telegram message",
+		Query:    TelegramChatForm{},
+		Response: client.Message{},
+	},
+	"GetChatPhoto": {
+		Summary: "get photo of telegram chat",
+		Query:   TelegramChatForm{},
+		Raw:     "image/jpeg",
+	},
+}
+
+func GetOpenAPI(doc *openapi.Document) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		ctx.JSON(200, doc)
+	}
+}
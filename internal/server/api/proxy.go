@@ -2,20 +2,26 @@ package api
 
 import (
 	"net/http"
+	"sync"
 
 	"github.com/acgn-org/onest/internal/logfield"
 	"github.com/acgn-org/onest/internal/source"
+	"github.com/acgn-org/onest/realsearch"
 	"github.com/gin-gonic/gin"
 )
 
 func RealSearchProxy() gin.HandlerFunc {
 	logger := logfield.New(logfield.ComServer).WithAction("real search proxy")
-	proxy := source.RealSearch.NewProxy()
-	proxy.ErrorHandler = func(_ http.ResponseWriter, request *http.Request, err error) {
-		logger.WithField("url", request.URL.String()).Warnln("proxy error:", err)
-	}
+	// client of real search is created on start up, after routes are registered
+	newProxy := sync.OnceValue(func() *realsearch.Proxy {
+		proxy := source.RealSearch.NewProxy()
+		proxy.ErrorHandler = func(_ http.ResponseWriter, request *http.Request, err error) {
+			logger.WithField("url", request.URL.String()).Warnln("proxy error:", err)
+		}
+		return proxy
+	})
 	return func(ctx *gin.Context) {
 		ctx.Request.URL.Path = ctx.Param("path")
-		proxy.ServeHTTP(ctx.Writer, ctx.Request)
+		newProxy().ServeHTTP(ctx.Writer, ctx.Request)
 	}
 }
@@ -2,15 +2,80 @@ package api
 
 import (
 	"context"
+	"encoding/base64"
+	"errors"
 	"time"
 
 	"github.com/acgn-org/onest/internal/config"
+	"github.com/acgn-org/onest/internal/database"
+	"github.com/acgn-org/onest/internal/queue"
 	"github.com/acgn-org/onest/internal/server/response"
 	"github.com/acgn-org/onest/internal/source"
+	"github.com/acgn-org/onest/repository"
+	"github.com/acgn-org/onest/telegram"
 	"github.com/acgn-org/onest/tools"
 	"github.com/gin-gonic/gin"
+	"github.com/skip2/go-qrcode"
 )
 
+// accountError responds errors of looking up telegram accounts
+func accountError(ctx *gin.Context, err error) {
+	switch {
+	case errors.Is(err, source.ErrAccountNotFound):
+		response.ErrorWithTip(ctx, response.ErrNotFound, err.Error())
+	case errors.Is(err, source.ErrNoAccountReady):
+		response.ErrorWithTip(ctx, response.ErrTelegramUnauthorized, err.Error())
+	case errors.Is(err, source.ErrChannelNotFound):
+		response.ErrorWithTip(ctx, response.ErrTelegram, err.Error())
+	default:
+		response.Error(ctx, response.ErrTelegram, err)
+	}
+}
+
+type TelegramAccountForm struct {
+	// the default account if empty
+	Account string `form:"account" json:"account"`
+}
+
+// accountFromQuery finds account named by query, the default account if not specified
+func accountFromQuery(ctx *gin.Context) (*source.Account, bool) {
+	var form TelegramAccountForm
+	if err := ctx.ShouldBindQuery(&form); err != nil {
+		response.Error(ctx, response.ErrForm, err)
+		return nil, false
+	}
+	account, err := source.GetAccount(form.Account)
+	if err != nil {
+		accountError(ctx, err)
+	}
+	return account, err == nil
+}
+
+type TelegramChatForm struct {
+	// the first account can see the channel if empty
+	Account string `form:"account" json:"account"`
+}
+
+// channelAccount uses the account of query if specified, otherwise the first account can see the channel
+func channelAccount(ctx *gin.Context, c context.Context, channelID int64) (*source.Account, bool) {
+	var form TelegramChatForm
+	if err := ctx.ShouldBindQuery(&form); err != nil {
+		response.Error(ctx, response.ErrForm, err)
+		return nil, false
+	}
+	var account *source.Account
+	var err error
+	if form.Account != "" {
+		account, err = source.GetReadyAccount(form.Account)
+	} else {
+		account, err = source.AccountForChannel(c, channelID, "")
+	}
+	if err != nil {
+		accountError(ctx, err)
+	}
+	return account, err == nil
+}
+
 func GetChat(ctx *gin.Context) {
 	id, err := tools.Int64IDFromParam(ctx, "id")
 	if err != nil {
@@ -21,7 +86,12 @@ func GetChat(ctx *gin.Context) {
 	_ctx, cancel := context.WithTimeout(ctx, time.Second*time.Duration(config.Server.Get().Timeout))
 	defer cancel()
 
-	info, err := source.Telegram.GetChat(_ctx, id)
+	account, ok := channelAccount(ctx, _ctx, id)
+	if !ok {
+		return
+	}
+
+	info, err := account.Telegram.GetChat(_ctx, id)
 	if err != nil {
 		response.Error(ctx, response.ErrTelegram, err)
 		return
@@ -40,14 +110,19 @@ func GetChatPhoto(ctx *gin.Context) {
 	_ctx, cancel := context.WithTimeout(ctx, time.Second*time.Duration(config.Server.Get().Timeout))
 	defer cancel()
 
-	info, err := source.Telegram.GetChat(_ctx, id)
+	account, ok := channelAccount(ctx, _ctx, id)
+	if !ok {
+		return
+	}
+
+	info, err := account.Telegram.GetChat(_ctx, id)
 	if err != nil {
 		response.Error(ctx, response.ErrTelegram, err)
 		return
 	}
 
 	if !info.Photo.Big.Local.IsDownloadingCompleted {
-		file, err := source.Telegram.DownloadFile(info.Photo.Big.Id, 32, true)
+		file, err := account.Telegram.DownloadFile(info.Photo.Big.Id, 32, true)
 		if err != nil {
 			response.Error(ctx, response.ErrTelegram, err)
 			return
@@ -74,7 +149,12 @@ func GetMessage(ctx *gin.Context) {
 	_ctx, cancel := context.WithTimeout(ctx, time.Second*time.Duration(config.Server.Get().Timeout))
 	defer cancel()
 
-	info, err := source.Telegram.GetMessage(_ctx, chatID, messageID)
+	account, ok := channelAccount(ctx, _ctx, chatID)
+	if !ok {
+		return
+	}
+
+	info, err := account.Telegram.GetMessage(_ctx, chatID, messageID)
 	if err != nil {
 		response.Error(ctx, response.ErrTelegram, err)
 		return
@@ -82,3 +162,149 @@ func GetMessage(ctx *gin.Context) {
 
 	response.Success(ctx, info)
 }
+
+type TelegramAuthState struct {
+	telegram.AuthState
+	// png of qr_link as data url
+	QrCode string `json:"qr_code,omitempty"`
+}
+
+func newTelegramAuthState(state telegram.AuthState) TelegramAuthState {
+	result := TelegramAuthState{AuthState: state}
+	if state.QrLink != "" {
+		if png, err := qrcode.Encode(state.QrLink, qrcode.Medium, 256); err == nil {
+			result.QrCode = "data:image/png;base64," + base64.StdEncoding.EncodeToString(png)
+		}
+	}
+	return result
+}
+
+func GetTelegramAuth(ctx *gin.Context) {
+	account, ok := accountFromQuery(ctx)
+	if !ok {
+		return
+	}
+	state, _ := account.Auth.State()
+	response.Success(ctx, newTelegramAuthState(state))
+}
+
+type TelegramAuthForm struct {
+	Account string `json:"account" form:"account"`
+	Type    string `json:"type" form:"type" binding:"required,oneof=phone_number code password qr"`
+	Value   string `json:"value" form:"value" binding:"required_unless=Type qr"`
+}
+
+func SubmitTelegramAuth(ctx *gin.Context) {
+	var form TelegramAuthForm
+	if err := ctx.ShouldBind(&form); err != nil {
+		response.Error(ctx, response.ErrForm, err)
+		return
+	}
+
+	_ctx, cancel := context.WithTimeout(ctx, time.Second*time.Duration(config.Server.Get().Timeout))
+	defer cancel()
+
+	account, err := source.GetAccount(form.Account)
+	if err != nil {
+		accountError(ctx, err)
+		return
+	}
+
+	state, err := account.Auth.Submit(_ctx, form.Type, form.Value)
+	if err != nil {
+		if errors.Is(err, telegram.ErrAuthInput) {
+			response.ErrorWithTip(ctx, response.ErrForm, err.Error())
+			return
+		}
+		response.ErrorWithTip(ctx, response.ErrTelegram, err.Error())
+		return
+	}
+
+	// values like phone number and password are not recorded
+	auditRepo := database.NewRepository[repository.AuditRepository]()
+	if err := audit(ctx, auditRepo.Repository, "telegram.auth."+form.Type, repository.AuditTargetTelegram, nil,
+		nil, map[string]string{"account": account.Name, "state": state.State}); err != nil {
+		response.Error(ctx, response.ErrDBOperation, err)
+		return
+	}
+
+	response.Success(ctx, newTelegramAuthState(state))
+}
+
+func GetTelegramProxies(ctx *gin.Context) {
+	account, ok := accountFromQuery(ctx)
+	if !ok {
+		return
+	}
+	response.Success(ctx, account.Proxy.List())
+}
+
+type TelegramProxyForm struct {
+	Account string `json:"account" form:"account"`
+	ID      int32  `json:"id" form:"id" binding:"required"`
+}
+
+func EnableTelegramProxy(ctx *gin.Context) {
+	var form TelegramProxyForm
+	if err := ctx.ShouldBind(&form); err != nil {
+		response.Error(ctx, response.ErrForm, err)
+		return
+	}
+
+	account, err := source.GetAccount(form.Account)
+	if err != nil {
+		accountError(ctx, err)
+		return
+	}
+
+	var before int32
+	for _, proxy := range account.Proxy.List() {
+		if proxy.Enabled {
+			before = proxy.ID
+		}
+	}
+
+	proxies, err := account.Proxy.Enable(form.ID)
+	if err != nil {
+		if errors.Is(err, telegram.ErrProxyNotFound) {
+			response.Error(ctx, response.ErrNotFound, err)
+			return
+		}
+		response.ErrorWithTip(ctx, response.ErrTelegram, err.Error())
+		return
+	}
+
+	auditRepo := database.NewRepository[repository.AuditRepository]()
+	if err := audit(ctx, auditRepo.Repository, "telegram.proxy.enable", repository.AuditTargetTelegram, nil,
+		map[string]any{"account": account.Name, "id": before}, map[string]any{"account": account.Name, "id": form.ID}); err != nil {
+		response.Error(ctx, response.ErrDBOperation, err)
+		return
+	}
+
+	response.Success(ctx, proxies)
+}
+
+type TelegramAccount struct {
+	Name    string `json:"name"`
+	State   string `json:"state"`
+	Ready   bool   `json:"ready"`
+	Running int    `json:"running"`
+	// 0 if removed from config, which takes effect after restart
+	MaxParallelDownload int `json:"max_parallel_download"`
+}
+
+func GetTelegramAccounts(ctx *gin.Context) {
+	running := queue.RunningByAccount()
+	accounts := make([]TelegramAccount, len(source.Accounts))
+	for i, account := range source.Accounts {
+		state, _ := account.Auth.State()
+		accounts[i] = TelegramAccount{
+			Name:                account.Name,
+			State:               state.State,
+			Ready:               account.Ready(),
+			Running:             running[account.Name],
+			MaxParallelDownload: account.MaxParallelDownload(),
+		}
+	}
+	response.Success(ctx, accounts)
+}
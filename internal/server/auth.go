@@ -0,0 +1,64 @@
+package server
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/acgn-org/onest/internal/auth"
+	"github.com/acgn-org/onest/internal/config"
+	"github.com/acgn-org/onest/internal/server/response"
+	"github.com/gin-gonic/gin"
+)
+
+// routes accessible without authentication
+var publicRoutes = map[string]bool{
+	"/api/auth/login":   true,
+	"/api/auth/status":  true,
+	"/api/openapi.json": true,
+}
+
+func Authentication() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		if !config.Auth.Get().Enabled || publicRoutes[ctx.FullPath()] {
+			ctx.Next()
+			return
+		}
+
+		principal, err := auth.Authenticate(ctx.Request)
+		if err != nil {
+			if errors.Is(err, auth.ErrUnauthenticated) {
+				response.Error(ctx, response.ErrUnauthorized)
+				return
+			}
+			response.Error(ctx, response.ErrDBOperation, err)
+			return
+		}
+		auth.SetPrincipal(ctx, principal)
+
+		ctx.Next()
+	}
+}
+
+// requireScope checks scope of the principal, read is required by GET requests and write by others
+func requireScope(read, write string) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		principal := auth.GetPrincipal(ctx)
+		if principal == nil {
+			// authentication disabled or public route
+			ctx.Next()
+			return
+		}
+
+		scope := write
+		if ctx.Request.Method == http.MethodGet || ctx.Request.Method == http.MethodHead {
+			scope = read
+		}
+		if !principal.HasScope(scope) {
+			response.ErrorWithTip(ctx, response.ErrUnauthorized, fmt.Sprintf("scope '%s' is required", scope))
+			return
+		}
+
+		ctx.Next()
+	}
+}
@@ -0,0 +1,25 @@
+package server
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/acgn-org/onest/internal/metrics"
+	"github.com/gin-gonic/gin"
+)
+
+// measureRequests observes latency of matched routes, files of web UI are not measured
+func measureRequests() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		start := time.Now()
+		ctx.Next()
+
+		route := ctx.FullPath()
+		if route == "" {
+			return
+		}
+		metrics.HTTPRequestDuration.
+			WithLabelValues(ctx.Request.Method, route, strconv.Itoa(ctx.Writer.Status())).
+			Observe(time.Since(start).Seconds())
+	}
+}
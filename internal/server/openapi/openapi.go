@@ -0,0 +1,203 @@
+// Package openapi builds an OpenAPI 3 document from gin routes and the types bound by handlers
+package openapi
+
+import (
+	"fmt"
+	"reflect"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/acgn-org/onest/internal/server/response"
+	"github.com/gin-gonic/gin"
+)
+
+// Operation describes a handler, types are given as zero values
+type Operation struct {
+	Summary string
+	// bound from query string
+	Query any
+	// bound from json body
+	Body any
+	// data field of response.Msg, nil for response.Default, or the whole body of raw responses
+	Response any
+	// content type of responses not wrapped in response.Msg, e.g. files and websockets
+	Raw string
+}
+
+type Document struct {
+	OpenAPI    string                          `json:"openapi"`
+	Info       Info                            `json:"info"`
+	Paths      map[string]map[string]*PathItem `json:"paths"`
+	Components Components                      `json:"components"`
+}
+
+type Info struct {
+	Title   string `json:"title"`
+	Version string `json:"version"`
+}
+
+type Components struct {
+	Schemas map[string]*Schema `json:"schemas"`
+}
+
+type PathItem struct {
+	OperationID string               `json:"operationId"`
+	Summary     string               `json:"summary,omitempty"`
+	Tags        []string             `json:"tags,omitempty"`
+	Parameters  []Parameter          `json:"parameters,omitempty"`
+	RequestBody *Body                `json:"requestBody,omitempty"`
+	Responses   map[string]*Response `json:"responses"`
+}
+
+type Parameter struct {
+	Name     string  `json:"name"`
+	In       string  `json:"in"`
+	Required bool    `json:"required,omitempty"`
+	Schema   *Schema `json:"schema"`
+}
+
+type Body struct {
+	Required bool                  `json:"required,omitempty"`
+	Content  map[string]*MediaType `json:"content"`
+}
+
+type Response struct {
+	Description string                `json:"description"`
+	Content     map[string]*MediaType `json:"content,omitempty"`
+}
+
+type MediaType struct {
+	Schema *Schema `json:"schema"`
+}
+
+var (
+	pathParamRe   = regexp.MustCompile(`[:*]([A-Za-z0-9_]+)`)
+	closureNameRe = regexp.MustCompile(`(\.func\d+)+$`)
+)
+
+// HandlerName returns the name of the handler function without package path, as used in operations
+func HandlerName(route gin.RouteInfo) string {
+	name := closureNameRe.ReplaceAllString(route.Handler, "")
+	name = name[strings.LastIndex(name, "/")+1:]
+	_, name, _ = strings.Cut(name, ".")
+	return name
+}
+
+func errorDescription() string {
+	var codes = make([]string, len(response.Errors))
+	for i, msg := range response.Errors {
+		codes[i] = fmt.Sprintf("%d: %s", msg.Code, msg.Msg)
+	}
+	return "failed with error code, msg is replaced with detail in some cases. " + strings.Join(codes, ", ")
+}
+
+// Build describes routes with operations keyed by handler name, routes without operation are described by path only
+func Build(title, version string, routes gin.RoutesInfo, operations map[string]Operation) *Document {
+	s := newSchemas()
+	doc := Document{
+		OpenAPI: "3.0.3",
+		Info: Info{
+			Title:   title,
+			Version: version,
+		},
+		Paths: make(map[string]map[string]*PathItem),
+	}
+
+	msgRef := s.of(reflect.TypeOf(response.Msg{}))
+	var codes []any
+	for _, msg := range response.Errors {
+		codes = append(codes, int64(msg.Code))
+	}
+	errorResponse := &Response{
+		Description: errorDescription(),
+		Content: map[string]*MediaType{
+			"application/json": {Schema: &Schema{AllOf: []*Schema{msgRef, {
+				Properties: map[string]*Schema{"code": {Type: "integer", Enum: codes}},
+			}}}},
+		},
+	}
+
+	var operationIDs = make(map[string]bool, len(routes))
+	sort.SliceStable(routes, func(i, j int) bool {
+		return routes[i].Path < routes[j].Path
+	})
+	for _, route := range routes {
+		name := HandlerName(route)
+		operation := operations[name]
+
+		path := pathParamRe.ReplaceAllString(route.Path, "{$1}")
+		if doc.Paths[path] == nil {
+			doc.Paths[path] = make(map[string]*PathItem)
+		}
+		item := PathItem{
+			OperationID: name,
+			Summary:     operation.Summary,
+			Responses:   make(map[string]*Response),
+		}
+		if parts := strings.Split(strings.Trim(route.Path, "/"), "/"); len(parts) > 1 {
+			item.Tags = []string{parts[1]}
+		}
+		// handlers registered with Any share the name
+		if operationIDs[item.OperationID] {
+			item.OperationID = name + strings.ToUpper(route.Method[:1]) + strings.ToLower(route.Method[1:])
+		}
+		operationIDs[item.OperationID] = true
+
+		for _, match := range pathParamRe.FindAllStringSubmatch(route.Path, -1) {
+			parameter := Parameter{Name: match[1], In: "path", Required: true, Schema: &Schema{Type: "string"}}
+			// ids are numeric, e.g. :id and :msgId
+			if strings.HasPrefix(match[0], ":") && (match[1] == "id" || strings.HasSuffix(match[1], "Id")) {
+				parameter.Schema = &Schema{Type: "integer", Format: "int64"}
+			}
+			item.Parameters = append(item.Parameters, parameter)
+		}
+		if operation.Query != nil {
+			for _, f := range fields(reflect.TypeOf(operation.Query), "form") {
+				schema := s.of(f.Type)
+				applyBinding(schema, f.Tag.Get("binding"))
+				item.Parameters = append(item.Parameters, Parameter{Name: f.name, In: "query", Required: f.required, Schema: schema})
+			}
+		}
+		if operation.Body != nil {
+			item.RequestBody = &Body{
+				Required: true,
+				Content: map[string]*MediaType{
+					"application/json": {Schema: s.of(reflect.TypeOf(operation.Body))},
+				},
+			}
+		}
+
+		switch {
+		case operation.Raw != "":
+			schema := &Schema{}
+			if operation.Response != nil {
+				schema = s.of(reflect.TypeOf(operation.Response))
+			}
+			item.Responses["200"] = &Response{
+				Description: "raw response",
+				Content:     map[string]*MediaType{operation.Raw: {Schema: schema}},
+			}
+		case operation.Response != nil:
+			item.Responses["200"] = &Response{
+				Description: "success",
+				Content: map[string]*MediaType{
+					"application/json": {Schema: &Schema{AllOf: []*Schema{msgRef, {
+						Properties: map[string]*Schema{"data": s.of(reflect.TypeOf(operation.Response))},
+					}}}},
+				},
+			}
+		default:
+			item.Responses["200"] = &Response{
+				Description: "success",
+				Content:     map[string]*MediaType{"application/json": {Schema: msgRef}},
+			}
+		}
+		item.Responses["400"] = errorResponse
+
+		doc.Paths[path][strings.ToLower(route.Method)] = &item
+	}
+
+	doc.Components.Schemas = s.components
+	return &doc
+}
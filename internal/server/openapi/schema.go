@@ -0,0 +1,258 @@
+package openapi
+
+import (
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type Schema struct {
+	Ref         string `json:"$ref,omitempty"`
+	Type        string `json:"type,omitempty"`
+	Format      string `json:"format,omitempty"`
+	Description string `json:"description,omitempty"`
+	Nullable    bool   `json:"nullable,omitempty"`
+
+	Enum      []any    `json:"enum,omitempty"`
+	Minimum   *float64 `json:"minimum,omitempty"`
+	Maximum   *float64 `json:"maximum,omitempty"`
+	MinLength *int     `json:"minLength,omitempty"`
+	MaxLength *int     `json:"maxLength,omitempty"`
+
+	Items                *Schema            `json:"items,omitempty"`
+	Properties           map[string]*Schema `json:"properties,omitempty"`
+	Required             []string           `json:"required,omitempty"`
+	AdditionalProperties *Schema            `json:"additionalProperties,omitempty"`
+	AllOf                []*Schema          `json:"allOf,omitempty"`
+}
+
+// field is a struct field flattened as encoding/json and gin binding see it
+type field struct {
+	reflect.StructField
+	name     string
+	required bool
+}
+
+var (
+	timeType        = reflect.TypeOf(time.Time{})
+	componentNameRe = regexp.MustCompile(`[^A-Za-z0-9._-]+`)
+)
+
+// opaque packages are described as plain objects, e.g. tdlib types
+var opaquePackages = []string{
+	"github.com/zelenin/go-tdlib",
+}
+
+// schemas collects named struct types as components
+type schemas struct {
+	components map[string]*Schema
+	names      map[reflect.Type]string
+}
+
+func newSchemas() *schemas {
+	return &schemas{
+		components: make(map[string]*Schema),
+		names:      make(map[reflect.Type]string),
+	}
+}
+
+func componentName(t reflect.Type) string {
+	var name = t.Name()
+	if i := strings.LastIndex(t.PkgPath(), "/"); i != -1 {
+		name = t.PkgPath()[i+1:] + "." + name
+	} else if t.PkgPath() != "" {
+		name = t.PkgPath() + "." + name
+	}
+	// generic type arguments are written with full package path
+	if i := strings.Index(name, "["); i != -1 {
+		args := strings.Split(strings.TrimSuffix(name[i+1:], "]"), ",")
+		for j, arg := range args {
+			args[j] = arg[strings.LastIndex(arg, ".")+1:]
+		}
+		name = name[:i] + "_" + strings.Join(args, "_")
+	}
+	return componentNameRe.ReplaceAllString(name, "_")
+}
+
+func isOpaque(t reflect.Type) bool {
+	for _, pkg := range opaquePackages {
+		if strings.HasPrefix(t.PkgPath(), pkg) {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *schemas) of(t reflect.Type) *Schema {
+	if t.Kind() == reflect.Pointer {
+		schema := s.of(t.Elem())
+		if schema.Ref != "" {
+			return &Schema{AllOf: []*Schema{schema}, Nullable: true}
+		}
+		schema.Nullable = true
+		return schema
+	}
+
+	switch t.Kind() {
+	case reflect.Bool:
+		return &Schema{Type: "boolean"}
+	case reflect.Int8, reflect.Int16, reflect.Int32:
+		return &Schema{Type: "integer", Format: "int32"}
+	case reflect.Int, reflect.Int64:
+		return &Schema{Type: "integer", Format: "int64"}
+	case reflect.Uint8, reflect.Uint16, reflect.Uint32:
+		return &Schema{Type: "integer", Format: "int32", Minimum: float(0)}
+	case reflect.Uint, reflect.Uint64:
+		return &Schema{Type: "integer", Format: "int64", Minimum: float(0)}
+	case reflect.Float32:
+		return &Schema{Type: "number", Format: "float"}
+	case reflect.Float64:
+		return &Schema{Type: "number", Format: "double"}
+	case reflect.String:
+		return &Schema{Type: "string"}
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return &Schema{Type: "string", Format: "byte"}
+		}
+		return &Schema{Type: "array", Items: s.of(t.Elem())}
+	case reflect.Map:
+		return &Schema{Type: "object", AdditionalProperties: s.of(t.Elem())}
+	case reflect.Struct:
+		if t == timeType {
+			return &Schema{Type: "string", Format: "date-time"}
+		} else if isOpaque(t) {
+			return &Schema{Type: "object"}
+		} else if t.Name() == "" {
+			return s.object(t)
+		}
+		return s.ref(t)
+	default:
+		return &Schema{}
+	}
+}
+
+func (s *schemas) ref(t reflect.Type) *Schema {
+	name, ok := s.names[t]
+	if !ok {
+		name = componentName(t)
+		s.names[t] = name
+		// registered before building to stop recursion
+		s.components[name] = &Schema{}
+		*s.components[name] = *s.object(t)
+	}
+	return &Schema{Ref: "#/components/schemas/" + name}
+}
+
+func (s *schemas) object(t reflect.Type) *Schema {
+	schema := Schema{
+		Type:       "object",
+		Properties: make(map[string]*Schema),
+	}
+	for _, f := range fields(t, "json") {
+		property := s.of(f.Type)
+		applyBinding(property, f.Tag.Get("binding"))
+		schema.Properties[f.name] = property
+		if f.required {
+			schema.Required = append(schema.Required, f.name)
+		}
+	}
+	return &schema
+}
+
+// fields returns exported fields named by tag, embedded structs without tag are flattened
+func fields(t reflect.Type, tag string) []field {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	var result []field
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		name, _, _ := strings.Cut(f.Tag.Get(tag), ",")
+		if name == "-" {
+			continue
+		}
+		if f.Anonymous && name == "" {
+			ft := f.Type
+			if ft.Kind() == reflect.Pointer {
+				ft = ft.Elem()
+			}
+			if ft.Kind() == reflect.Struct {
+				result = append(result, fields(ft, tag)...)
+				continue
+			}
+		}
+		if !f.IsExported() {
+			continue
+		}
+		if name == "" {
+			name = f.Name
+		}
+		result = append(result, field{
+			StructField: f,
+			name:        name,
+			required:    hasRule(f.Tag.Get("binding"), "required"),
+		})
+	}
+	return result
+}
+
+func hasRule(binding, rule string) bool {
+	for _, r := range strings.Split(binding, ",") {
+		if r == rule {
+			return true
+		}
+	}
+	return false
+}
+
+// applyBinding describes validator rules in the schema, rules after dive belong to elements
+func applyBinding(schema *Schema, binding string) {
+	if binding == "" {
+		return
+	}
+	rules, _, _ := strings.Cut(binding, ",dive")
+	if rules == "dive" {
+		return
+	}
+	for _, rule := range strings.Split(rules, ",") {
+		key, value, _ := strings.Cut(rule, "=")
+		switch key {
+		case "oneof":
+			for _, option := range strings.Fields(value) {
+				if schema.Type == "integer" {
+					if n, err := strconv.ParseInt(option, 10, 64); err == nil {
+						schema.Enum = append(schema.Enum, n)
+					}
+				} else {
+					schema.Enum = append(schema.Enum, option)
+				}
+			}
+		case "min", "max":
+			n, err := strconv.ParseFloat(value, 64)
+			if err != nil {
+				continue
+			}
+			switch schema.Type {
+			case "integer", "number":
+				if key == "min" {
+					schema.Minimum = &n
+				} else {
+					schema.Maximum = &n
+				}
+			case "string":
+				length := int(n)
+				if key == "min" {
+					schema.MinLength = &length
+				} else {
+					schema.MaxLength = &length
+				}
+			}
+		}
+	}
+}
+
+func float(f float64) *float64 {
+	return &f
+}
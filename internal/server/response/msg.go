@@ -9,6 +9,8 @@ const (
 	cErrNotFound
 	cErrTelegram
 	cErrResourceConflict
+	cErrUnauthorized
+	cErrTelegramUnauthorized
 )
 
 type Msg struct {
@@ -46,4 +48,25 @@ var (
 		Code: cErrResourceConflict,
 		Msg:  "resource conflict",
 	}
+	ErrUnauthorized = &Msg{
+		Code: cErrUnauthorized,
+		Msg:  "unauthorized",
+	}
+	ErrTelegramUnauthorized = &Msg{
+		Code: cErrTelegramUnauthorized,
+		Msg:  "telegram is not logged in",
+	}
 )
+
+// Errors lists every error code returned by api
+var Errors = []*Msg{
+	ErrForm,
+	ErrDBOperation,
+	ErrReachLimit,
+	ErrUnexpected,
+	ErrNotFound,
+	ErrTelegram,
+	ErrResourceConflict,
+	ErrUnauthorized,
+	ErrTelegramUnauthorized,
+}
@@ -1,37 +1,73 @@
 package server
 
 import (
+	"github.com/acgn-org/onest/internal/auth"
 	"github.com/acgn-org/onest/internal/server/api"
 	"github.com/gin-gonic/gin"
 )
 
 func Api(group *gin.RouterGroup) {
-	group.Any("realsearch/*path", api.RealSearchProxy())
+	group.Any("realsearch/*path", requireScope(auth.ScopeRead, auth.ScopeItemsWrite), api.RealSearchProxy())
 
-	item := group.Group("item")
+	authGroup := group.Group("auth")
+	authGroup.GET("status", api.GetAuthStatus)
+	authGroup.POST("login", api.Login)
+	authGroup.POST("logout", api.Logout)
+	authAdmin := authGroup.Group("", requireScope(auth.ScopeAdmin, auth.ScopeAdmin))
+	authAdmin.PUT("password", api.ChangePassword)
+	authToken := authAdmin.Group("token")
+	authToken.GET("/", api.GetApiTokens)
+	authToken.POST("/", api.NewApiToken)
+	authToken.DELETE(":id", api.DeleteApiToken)
+
+	group.GET("audit", requireScope(auth.ScopeAdmin, auth.ScopeAdmin), api.GetAuditLogs)
+
+	configScope := group.Group("config/:scope", requireScope(auth.ScopeAdmin, auth.ScopeAdmin))
+	configScope.GET("/", api.GetConfig)
+	configScope.PATCH("/", api.PatchConfig)
+
+	item := group.Group("item", requireScope(auth.ScopeRead, auth.ScopeItemsWrite))
+	item.GET("/", api.GetItems)
 	item.GET("active", api.GetActiveItems)
 	item.GET("error", api.GetErrorItems)
-	item.POST("/", api.NewItem)
+	item.POST("/", requireTelegram(), api.NewItem)
+	item.GET("export", api.ExportItems)
+	item.POST("import", api.ImportItems)
 	itemWithId := item.Group(":id")
 	itemWithId.GET("/", api.GetItemByID)
 	itemWithId.GET("downloads", api.GetItemDownloads)
+	itemWithId.GET("rename", api.GetItemRenamePlan)
+	itemWithId.POST("rename", api.RenameItemFiles)
 	itemWithId.PATCH("/", api.PatchItem)
 	itemWithId.DELETE("/", api.DeleteItem)
 
-	download := group.Group("download")
-	download.POST("/", api.AddDownloadForItem)
+	download := group.Group("download", requireScope(auth.ScopeRead, auth.ScopeDownloadsWrite))
+	download.POST("/", requireTelegram(), api.AddDownloadForItem)
 	download.GET("tasks", api.GetDownloadTasks)
+	download.POST("bulk", api.BulkDownloads)
+	download.GET("watch", api.WatchDownloads)
 	downloadWithId := download.Group(":id")
+	downloadWithId.GET("companions", api.GetDownloadCompanions)
 	downloadWithId.PATCH("priority", api.UpdateDownloadPriority)
 	downloadWithId.DELETE("/", api.DeleteDownload)
 	downloadForce := downloadWithId.Group("force")
-	downloadForce.POST("start", api.ForceStartTask)
+	downloadForce.POST("start", requireTelegram(), api.ForceStartTask)
 	downloadForce.POST("reset", api.ForceResetTask)
 
-	log := group.Group("log")
+	log := group.Group("log", requireScope(auth.ScopeLogsRead, auth.ScopeLogsRead))
 	log.GET("watch", api.WatchLogs)
 
-	telegram := group.Group("telegram")
+	group.GET("telegram/accounts", requireScope(auth.ScopeTelegramRead, auth.ScopeAdmin), api.GetTelegramAccounts)
+
+	telegramAuth := group.Group("telegram/auth", requireScope(auth.ScopeTelegramRead, auth.ScopeAdmin))
+	telegramAuth.GET("/", api.GetTelegramAuth)
+	telegramAuth.POST("/", api.SubmitTelegramAuth)
+
+	telegramProxy := group.Group("telegram/proxy", requireScope(auth.ScopeTelegramRead, auth.ScopeAdmin))
+	telegramProxy.GET("/", api.GetTelegramProxies)
+	telegramProxy.POST("enable", api.EnableTelegramProxy)
+
+	telegram := group.Group("telegram", requireScope(auth.ScopeTelegramRead, auth.ScopeTelegramRead), requireTelegram())
 	telegramChat := telegram.Group("chat/:id")
 	telegramChat.GET("/", api.GetChat)
 	telegramChat.GET("message/:msgId", api.GetMessage)
@@ -0,0 +1,30 @@
+package server
+
+import (
+	"testing"
+
+	"github.com/acgn-org/onest/internal/server/api"
+	"github.com/acgn-org/onest/internal/server/openapi"
+	"github.com/gin-gonic/gin"
+)
+
+// routes registered by Api are the ones described by openapi.Build in NewEngine
+func TestOperationsMatchRoutes(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	engine := gin.New()
+	Api(engine.Group("api"))
+
+	var routed = make(map[string]bool)
+	for _, route := range engine.Routes() {
+		name := openapi.HandlerName(route)
+		routed[name] = true
+		if _, ok := api.Operations[name]; !ok {
+			t.Errorf("route %s %s: handler %s is missing in api.Operations", route.Method, route.Path, name)
+		}
+	}
+	for name := range api.Operations {
+		if !routed[name] {
+			t.Errorf("operation %s has no route", name)
+		}
+	}
+}
@@ -6,41 +6,80 @@ import (
 	"fmt"
 	"net"
 	"net/http"
-	"os"
-	"os/signal"
-	"syscall"
-	"time"
 
+	"github.com/acgn-org/onest/internal/app"
+	"github.com/acgn-org/onest/internal/auth"
 	"github.com/acgn-org/onest/internal/config"
+	"github.com/acgn-org/onest/internal/database"
 	"github.com/acgn-org/onest/internal/logfield"
+	"github.com/acgn-org/onest/internal/metrics"
+	"github.com/acgn-org/onest/internal/queue"
+	"github.com/acgn-org/onest/internal/server/api"
+	"github.com/acgn-org/onest/internal/server/openapi"
+	"github.com/acgn-org/onest/internal/source"
 	"github.com/gin-gonic/gin"
 )
 
 func NewEngine() *gin.Engine {
 	gin.SetMode(gin.ReleaseMode)
 	Engine := gin.Default()
+	if err := Engine.SetTrustedProxies(config.Server.Get().TrustedProxies); err != nil {
+		logfield.New(logfield.ComServer).WithAction("init").Fatalln("set trusted proxies failed:", err)
+	}
+
+	if err := auth.EnsurePassword(); err != nil {
+		logfield.New(logfield.ComAuth).WithAction("init").Fatalln("generate password failed:", err)
+	}
+
+	Engine.Use(measureRequests())
+
+	apiGroup := Engine.Group("api", Authentication())
+	Api(apiGroup)
 
-	Api(Engine.Group("api"))
+	doc := openapi.Build("onest", config.VERSION, Engine.Routes(), api.Operations)
+	apiGroup.GET("openapi.json", api.GetOpenAPI(doc))
+
+	// served outside of api for scrapers, not documented by openapi
+	Engine.GET("metrics", Authentication(), requireScope(auth.ScopeMetricsRead, auth.ScopeMetricsRead), gin.WrapH(metrics.Handler()))
 
 	return Engine
 }
 
+// Run starts the application with engine served, and shuts down gracefully on SIGINT or SIGTERM
 func Run(engine *gin.Engine) {
+	httpSrv := &http.Server{
+		Handler: engine,
+	}
+
+	app.New(
+		app.Component{Name: "config", Start: func(context.Context) error {
+			return config.Validate()
+		}},
+		app.Component{Name: "database", Start: database.Connect, Stop: database.Close},
+		app.Component{Name: "telegram", Start: source.ConnectTelegram, Stop: source.CloseTelegram},
+		app.Component{Name: "real search", Start: source.InitRealSearch},
+		app.Component{Name: "queue", Start: queue.Start, Stop: queue.Stop},
+		app.Component{Name: "config watcher", Start: func(context.Context) error {
+			config.Watch()
+			return nil
+		}},
+		app.Component{Name: "http server", Start: func(context.Context) error {
+			return serve(httpSrv)
+		}, Stop: httpSrv.Shutdown},
+	).Run()
+}
+
+func serve(httpSrv *http.Server) error {
 	logger := logfield.New(logfield.ComServer)
 	serverConfig := config.Server.Get()
 
-	// create http server
-
 	addr := fmt.Sprintf("%s:%d", serverConfig.Host, serverConfig.Port)
 	listen, err := net.Listen("tcp", addr)
 	if err != nil {
-		logger.Fatalf("listen on %s failed: %v", addr, err)
+		return fmt.Errorf("listen on %s failed: %w", addr, err)
 	}
 	logger.Infof("listening on %s", listen.Addr())
 
-	httpSrv := http.Server{
-		Handler: engine,
-	}
 	go func(listen net.Listener) {
 		if err := httpSrv.Serve(listen); err != nil {
 			if errors.Is(err, http.ErrServerClosed) {
@@ -49,17 +88,5 @@ func Run(engine *gin.Engine) {
 			logger.Fatalln("start server failed:", err)
 		}
 	}(listen)
-
-	// shutdown
-
-	quit := make(chan os.Signal)
-	signal.Notify(quit, os.Interrupt, os.Kill, syscall.SIGTERM)
-	<-quit
-	logger.Infoln("Shutdown Server...")
-
-	ctx, cancel := context.WithTimeout(context.Background(), time.Second*30)
-	defer cancel()
-	if err := httpSrv.Shutdown(ctx); err != nil {
-		logger.Errorln("shutdown http server failed:", err)
-	}
+	return nil
 }
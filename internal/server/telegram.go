@@ -0,0 +1,18 @@
+package server
+
+import (
+	"github.com/acgn-org/onest/internal/server/response"
+	"github.com/acgn-org/onest/internal/source"
+	"github.com/gin-gonic/gin"
+)
+
+// requireTelegram rejects requests depending on telegram before login completes
+func requireTelegram() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		if !source.TelegramReady() {
+			response.Error(ctx, response.ErrTelegramUnauthorized)
+			return
+		}
+		ctx.Next()
+	}
+}
@@ -1,16 +1,202 @@
 package source
 
 import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"sync"
+	"time"
+
 	"github.com/acgn-org/onest/internal/config"
 	"github.com/acgn-org/onest/internal/logfield"
+	"github.com/acgn-org/onest/internal/metrics"
 	"github.com/acgn-org/onest/telegram"
 	log "github.com/sirupsen/logrus"
 	"github.com/zelenin/go-tdlib/client"
+	"golang.org/x/term"
+)
+
+var (
+	ErrAccountNotFound = errors.New("telegram account not found")
+	ErrNoAccountReady  = errors.New("no telegram account is logged in")
+	ErrChannelNotFound = errors.New("no telegram account can see the channel")
 )
 
-var Telegram *telegram.Telegram
+// channels not seen by an account are checked again after it
+const channelInvisibleTTL = time.Minute * 10
+
+// Account is a TDLib session with its own data folder
+type Account struct {
+	Name string
+	// set once authorized, check Ready before use
+	Telegram *telegram.Telegram
+	// inputs of login are submitted to it from api or terminal
+	Auth *telegram.Authorizer
+	// proxies work before authorization
+	Proxy *telegram.ProxyManager
+
+	// closed when authorization finished, err is set if failed
+	done chan struct{}
+	err  error
+
+	// channel id => time.Time checked invisible, or true if visible
+	channels sync.Map
+}
+
+// Accounts in order of config, the default account is the first
+var Accounts []*Account
+
+// Ready reports whether the account is authorized
+func (a *Account) Ready() bool {
+	select {
+	case <-a.done:
+		if a.err != nil {
+			return false
+		}
+		state, _ := a.Auth.State()
+		return state.State == telegram.AuthStateReady
+	default:
+		return false
+	}
+}
+
+// Wait blocks until authorization finished
+func (a *Account) Wait(ctx context.Context) error {
+	select {
+	case <-a.done:
+		return a.err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// MaxParallelDownload is read from config every time, as it changes at runtime
+func (a *Account) MaxParallelDownload() int {
+	return int(config.Telegram.Get().MaxParallelDownloadOf(a.Name))
+}
 
-func init() {
+// CanSee reports whether the account is ready and the channel is accessible by it
+func (a *Account) CanSee(ctx context.Context, channelID int64) bool {
+	if !a.Ready() {
+		return false
+	}
+	if value, ok := a.channels.Load(channelID); ok {
+		if checkedAt, ok := value.(time.Time); !ok || time.Since(checkedAt) < channelInvisibleTTL {
+			return !ok
+		}
+	}
+	if _, err := a.Telegram.GetChat(ctx, channelID); err != nil {
+		if ctx.Err() == nil {
+			a.channels.Store(channelID, time.Now())
+		}
+		return false
+	}
+	a.channels.Store(channelID, true)
+	return true
+}
+
+// GetAccount finds account by name, the default account if name is empty
+func GetAccount(name string) (*Account, error) {
+	if name == "" {
+		name = telegram.DefaultAccount
+	}
+	for _, account := range Accounts {
+		if account.Name == name {
+			return account, nil
+		}
+	}
+	return nil, fmt.Errorf("%w: %s", ErrAccountNotFound, name)
+}
+
+// GetReadyAccount finds account by name like GetAccount, and requires it ready
+func GetReadyAccount(name string) (*Account, error) {
+	account, err := GetAccount(name)
+	if err != nil {
+		return nil, err
+	} else if !account.Ready() {
+		return nil, fmt.Errorf("%w: %s", ErrNoAccountReady, account.Name)
+	}
+	return account, nil
+}
+
+// AccountForChannel returns the preferred account if it can see the channel, otherwise the first one can
+func AccountForChannel(ctx context.Context, channelID int64, preferred string) (*Account, error) {
+	var candidates = make([]*Account, 0, len(Accounts))
+	if account, err := GetAccount(preferred); err == nil {
+		candidates = append(candidates, account)
+	}
+	for _, account := range Accounts {
+		if account.Name != preferred {
+			candidates = append(candidates, account)
+		}
+	}
+
+	var ready bool
+	for _, account := range candidates {
+		if account.CanSee(ctx, channelID) {
+			return account, nil
+		}
+		ready = ready || account.Ready()
+	}
+	if ctx.Err() != nil {
+		return nil, ctx.Err()
+	} else if !ready {
+		return nil, ErrNoAccountReady
+	}
+	return nil, ErrChannelNotFound
+}
+
+// TelegramReady reports whether the default account is authorized, the rest of the application runs degraded until then
+func TelegramReady() bool {
+	return len(Accounts) != 0 && Accounts[0].Ready()
+}
+
+// WaitTelegram blocks until authorization of the default account finished
+func WaitTelegram(ctx context.Context) error {
+	return Accounts[0].Wait(ctx)
+}
+
+func connectAccount(name, dataFolder string, proxyRequests []*client.AddProxyRequest, opts []client.Option) *Account {
+	telegramConfig := config.Telegram.Get()
+	account := &Account{
+		Name:  name,
+		Auth:  telegram.NewAuthorizer(logfield.New(logfield.ComTelegram).WithAction("auth").WithField("account", name)),
+		Proxy: telegram.NewProxyManager(logfield.New(logfield.ComTelegram).WithAction("proxy").WithField("account", name), proxyRequests),
+		done:  make(chan struct{}),
+	}
+	go func() {
+		defer close(account.done)
+		defer func() {
+			// authorization canceled ends this goroutine without returning
+			if account.Telegram == nil && account.err == nil {
+				account.err = telegram.ErrAuthCanceled
+				logfield.New(logfield.ComSource).WithField("account", name).Debugln("telegram authorization canceled")
+			}
+		}()
+		account.Telegram, account.err = telegram.New(&telegram.Config{
+			Logger:     logfield.New(logfield.ComTelegram).WithField("account", name),
+			Version:    config.VERSION,
+			DataFolder: dataFolder,
+			ApiId:      telegramConfig.ApiId,
+			ApiHash:    telegramConfig.ApiHash,
+			OnFloodWait: func(d time.Duration) {
+				metrics.FloodWaits.WithLabelValues(name).Inc()
+				metrics.FloodWaitSeconds.WithLabelValues(name).Add(d.Seconds())
+			},
+		}, account.Auth, append(slices.Clip(opts), account.Proxy.Option())...)
+		if account.err != nil {
+			logfield.New(logfield.ComSource).WithField("account", name).Errorln("telegram authorization failed:", account.err)
+		}
+	}()
+	return account
+}
+
+// ConnectTelegram creates TDLib clients of every account and authorizes them in background.
+// Inputs of login are asked on terminal if attached, and can be submitted from api as well.
+func ConnectTelegram(_ context.Context) error {
 	logger := logfield.New(logfield.ComSource).WithAction("init:telegram")
 
 	// options
@@ -26,26 +212,80 @@ func init() {
 			NewVerbosityLevel: 0,
 		}))
 	}
-	proxyRequest, ok := telegram.ProxyFromEnvironment(logger)
-	if ok {
-		opts = append(opts, client.WithProxy(proxyRequest))
-	} else {
-		opts = append(opts, client.WithProxy(&client.AddProxyRequest{
-			Enable: false,
-		}))
-	}
 
-	// connect client
+	// proxies
 	var telegramConfig = config.Telegram.Get()
-	var err error
-	Telegram, err = telegram.New(&telegram.Config{
-		Logger:     logfield.New(logfield.ComTelegram),
-		Version:    config.VERSION,
-		DataFolder: telegramConfig.DataFolder,
-		ApiId:      telegramConfig.ApiId,
-		ApiHash:    telegramConfig.ApiHash,
-	}, opts...)
-	if err != nil {
-		logger.Fatalln("failed to create Telegram client:", err)
+	var proxyRequests = make([]*client.AddProxyRequest, 0, len(telegramConfig.Proxy))
+	for i, proxyUrl := range telegramConfig.Proxy {
+		proxyRequest, err := telegram.ParseProxyUrl(proxyUrl)
+		if err != nil {
+			return fmt.Errorf("invalid telegram.proxy[%d]: %w", i, err)
+		}
+		proxyRequests = append(proxyRequests, proxyRequest)
+	}
+	if len(proxyRequests) == 0 {
+		if proxyRequest, ok := telegram.ProxyFromEnvironment(logger); ok {
+			proxyRequests = append(proxyRequests, proxyRequest)
+		}
+	}
+
+	// a data folder is locked by TDLib
+	var dataFolders = map[string]string{filepath.Clean(telegramConfig.DataFolder): telegram.DefaultAccount}
+	for _, account := range telegramConfig.Accounts {
+		if name, ok := dataFolders[filepath.Clean(account.DataFolder)]; ok {
+			return fmt.Errorf("data folder of telegram account %s is used by %s", account.Name, name)
+		}
+		dataFolders[filepath.Clean(account.DataFolder)] = account.Name
+	}
+
+	// connect clients
+	Accounts = []*Account{connectAccount(telegram.DefaultAccount, telegramConfig.DataFolder, proxyRequests, opts)}
+	for _, account := range telegramConfig.Accounts {
+		Accounts = append(Accounts, connectAccount(account.Name, account.DataFolder, proxyRequests, opts))
+	}
+
+	if term.IsTerminal(int(os.Stdin.Fd())) {
+		go interactAccounts(Accounts)
+	}
+	return nil
+}
+
+// interactAccounts asks for inputs of accounts one by one
+func interactAccounts(accounts []*Account) {
+	lines := telegram.ReadLines(os.Stdin)
+	for _, account := range accounts {
+		if state, _ := account.Auth.State(); state.State == telegram.AuthStateReady {
+			continue
+		}
+		if len(accounts) > 1 {
+			fmt.Printf("Logging in telegram account %s\n", account.Name)
+		}
+		if !account.Auth.Interact(lines, os.Stdout) {
+			return
+		}
+	}
+}
+
+// CloseTelegram closes clients authorized, clients still authorizing are closed by canceling it
+func CloseTelegram(ctx context.Context) error {
+	var errs []error
+	for _, account := range Accounts {
+		account.Auth.Cancel()
+		account.Proxy.Close()
+	}
+	for _, account := range Accounts {
+		select {
+		case <-account.done:
+		case <-ctx.Done():
+			errs = append(errs, fmt.Errorf("close account %s failed: %w", account.Name, ctx.Err()))
+			continue
+		}
+		if account.err != nil {
+			continue
+		}
+		if err := account.Telegram.Close(); err != nil {
+			errs = append(errs, fmt.Errorf("close account %s failed: %w", account.Name, err))
+		}
 	}
+	return errors.Join(errs...)
 }
@@ -24,11 +24,11 @@ func (c Client) GetTimeMachineRules() ([]TimeMachineItem, error) {
 	return data, c.Do(req, &data)
 }
 
-func (c Client) GetTimeMachineItemRaws(itemID uint) ([]RawInfo, error) {
+func (c Client) GetTimeMachineItemRaws(itemID uint) (*TimeMachineItemRaws, error) {
 	req, err := c.NewRequest("GET", fmt.Sprintf("time_machine/item/%d/raws", itemID), nil)
 	if err != nil {
 		return nil, err
 	}
-	var data []RawInfo
-	return data, c.Do(req, &data)
+	var data TimeMachineItemRaws
+	return &data, c.Do(req, &data)
 }
@@ -17,6 +17,11 @@ type ScheduleV2 struct {
 	Status string `json:"status"`
 }
 
+type TimeMachineItemRaws struct {
+	Item TimeMachineItem `json:"item"`
+	Data []RawInfo       `json:"data"`
+}
+
 type RawInfo struct {
 	ID                uint   `json:"id"`
 	ItemID            uint   `json:"item_id,omitempty"`
@@ -0,0 +1,121 @@
+package repository
+
+import (
+	"gorm.io/gorm"
+)
+
+const (
+	AuditActorSession = "session"
+	AuditActorToken   = "token"
+	// authentication disabled, actor is the remote address
+	AuditActorAnonymous = "anonymous"
+	// command line, actor is the user of the system
+	AuditActorCli = "cli"
+)
+
+const (
+	AuditTargetItem     = "item"
+	AuditTargetDownload = "download"
+	AuditTargetToken    = "token"
+	AuditTargetConfig   = "config"
+	AuditTargetTelegram = "telegram"
+)
+
+type AuditLog struct {
+	ID uint `gorm:"primarykey" json:"id"`
+
+	ActorType  string `gorm:"not null" json:"actor_type"`
+	Actor      string `gorm:"index;not null" json:"actor"`
+	ActorID    uint   `gorm:"index;not null;default:0" json:"actor_id"`
+	RemoteAddr string `gorm:"not null" json:"remote_addr"`
+
+	Action     string `gorm:"index;not null" json:"action"`
+	TargetType string `gorm:"not null" json:"target_type"`
+	TargetIDs  []uint `gorm:"serializer:json" json:"target_ids"`
+
+	Before any `gorm:"serializer:json" json:"before"`
+	After  any `gorm:"serializer:json" json:"after"`
+
+	CreatedAt int64 `gorm:"autoCreateTime;index;not null" json:"created_at"`
+}
+
+// AuditTarget indexes target ids of audit logs for filtering
+type AuditTarget struct {
+	AuditLogID uint   `gorm:"primarykey;autoIncrement:false"`
+	TargetType string `gorm:"index:idx_audit_target,priority:1;not null"`
+	TargetID   uint   `gorm:"primarykey;autoIncrement:false;index:idx_audit_target,priority:2"`
+}
+
+type AuditFilter struct {
+	Actor      string `json:"actor" form:"actor"`
+	ActorID    uint   `json:"actor_id" form:"actor_id"`
+	Action     string `json:"action" form:"action"`
+	TargetType string `json:"target_type" form:"target_type" binding:"omitempty,oneof=item download token config telegram"`
+	TargetID   uint   `json:"target_id" form:"target_id"`
+	From       int64  `json:"from" form:"from" binding:"min=0"`
+	To         int64  `json:"to" form:"to" binding:"min=0"`
+
+	Cursor string `json:"cursor" form:"cursor"`
+	Limit  int    `json:"limit" form:"limit" binding:"min=0"`
+}
+
+// newest first
+var auditSort = keyset[AuditLog]{
+	columns: []SortColumn{{Column: "id", Desc: true}},
+	values: func(model *AuditLog) []int64 {
+		return []int64{int64(model.ID)}
+	},
+}
+
+type AuditRepository struct {
+	Repository
+}
+
+func (repo AuditRepository) Create(model *AuditLog) error {
+	if err := repo.DB.Model(&AuditLog{}).Create(model).Error; err != nil {
+		return err
+	}
+	if len(model.TargetIDs) == 0 {
+		return nil
+	}
+	var targets = make([]AuditTarget, len(model.TargetIDs))
+	for i, id := range model.TargetIDs {
+		targets[i] = AuditTarget{AuditLogID: model.ID, TargetType: model.TargetType, TargetID: id}
+	}
+	return repo.DB.Model(&AuditTarget{}).Create(&targets).Error
+}
+
+func (repo AuditRepository) applyFilter(tx *gorm.DB, filter *AuditFilter) *gorm.DB {
+	if filter.Actor != "" {
+		tx = tx.Where("actor = ?", filter.Actor)
+	}
+	if filter.ActorID != 0 {
+		tx = tx.Where("actor_id = ?", filter.ActorID)
+	}
+	if filter.Action != "" {
+		// actions are dot separated, e.g. download matches download.delete
+		tx = tx.Where("(action = ? OR action LIKE ? ESCAPE '!')", filter.Action, likeEscaper.Replace(filter.Action)+".%")
+	}
+	if filter.TargetType != "" {
+		tx = tx.Where("target_type = ?", filter.TargetType)
+	}
+	if filter.TargetID != 0 {
+		targets := repo.DB.Model(&AuditTarget{}).Select("audit_log_id").Where("target_id = ?", filter.TargetID)
+		if filter.TargetType != "" {
+			targets = targets.Where("target_type = ?", filter.TargetType)
+		}
+		tx = tx.Where("id IN (?)", targets)
+	}
+	if filter.From != 0 {
+		tx = tx.Where("created_at >= ?", filter.From)
+	}
+	if filter.To != 0 {
+		tx = tx.Where("created_at <= ?", filter.To)
+	}
+	return tx
+}
+
+func (repo AuditRepository) GetPage(filter *AuditFilter) (*Page[AuditLog], error) {
+	tx := repo.applyFilter(repo.DB.Model(&AuditLog{}), filter)
+	return findPage(tx, auditSort, filter.Cursor, filter.Limit, nil)
+}
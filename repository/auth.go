@@ -0,0 +1,76 @@
+package repository
+
+import (
+	"time"
+)
+
+type Session struct {
+	ID        uint   `gorm:"primarykey"`
+	TokenHash string `gorm:"uniqueIndex;size:64;not null"`
+	ExpiresAt int64  `gorm:"index;not null"`
+	CreatedAt int64  `gorm:"autoCreateTime"`
+}
+
+type ApiToken struct {
+	ID         uint     `gorm:"primarykey" json:"id"`
+	Name       string   `gorm:"not null" json:"name"`
+	TokenHash  string   `gorm:"uniqueIndex;size:64;not null" json:"-"`
+	Scopes     []string `gorm:"serializer:json" json:"scopes"`
+	CreatedAt  int64    `gorm:"autoCreateTime" json:"created_at"`
+	LastUsedAt int64    `gorm:"not null;default:0" json:"last_used_at"`
+}
+
+type AuthRepository struct {
+	Repository
+}
+
+func (repo AuthRepository) CreateSession(model *Session) error {
+	return repo.DB.Model(&Session{}).Create(model).Error
+}
+
+func (repo AuthRepository) FirstValidSession(tokenHash string) (*Session, error) {
+	var session Session
+	return &session, repo.DB.Model(&Session{}).Where("token_hash = ? AND expires_at > ?", tokenHash, time.Now().Unix()).
+		First(&session).Error
+}
+
+func (repo AuthRepository) DeleteSession(tokenHash string) error {
+	return repo.DB.Model(&Session{}).Where("token_hash = ?", tokenHash).Delete(nil).Error
+}
+
+func (repo AuthRepository) DeleteExpiredSessions() error {
+	return repo.DB.Model(&Session{}).Where("expires_at <= ?", time.Now().Unix()).Delete(nil).Error
+}
+
+// DeleteAllSessions signs out every browser, e.g. after password changed
+func (repo AuthRepository) DeleteAllSessions() error {
+	return repo.DB.Model(&Session{}).Where("1 = 1").Delete(nil).Error
+}
+
+func (repo AuthRepository) CreateToken(model *ApiToken) error {
+	return repo.DB.Model(&ApiToken{}).Create(model).Error
+}
+
+func (repo AuthRepository) FirstToken(tokenHash string) (*ApiToken, error) {
+	var token ApiToken
+	return &token, repo.DB.Model(&ApiToken{}).Where("token_hash = ?", tokenHash).First(&token).Error
+}
+
+func (repo AuthRepository) FirstTokenByID(id uint) (*ApiToken, error) {
+	var token ApiToken
+	return &token, repo.DB.Model(&ApiToken{}).Where("id = ?", id).First(&token).Error
+}
+
+func (repo AuthRepository) UpdateTokenLastUsed(id uint, at int64) error {
+	return repo.DB.Model(&ApiToken{ID: id}).Update("last_used_at", at).Error
+}
+
+func (repo AuthRepository) GetTokens() ([]ApiToken, error) {
+	var tokens = make([]ApiToken, 0)
+	return tokens, repo.DB.Model(&ApiToken{}).Order("id ASC").Find(&tokens).Error
+}
+
+func (repo AuthRepository) DeleteToken(id uint) (bool, error) {
+	result := repo.DB.Model(&ApiToken{}).Where("id = ?", id).Delete(nil)
+	return result.RowsAffected > 0, result.Error
+}
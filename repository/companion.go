@@ -0,0 +1,104 @@
+package repository
+
+import (
+	"gorm.io/gorm/clause"
+)
+
+// CompanionRule matches document messages posted right after a video, e.g. subtitles or font archives
+type CompanionRule struct {
+	// matched against file name of the document
+	Regexp string `json:"regexp" yaml:"regexp" binding:"required"`
+	// max number of messages after the video, 0 to disable
+	MsgWindow int64 `json:"msg_window" yaml:"msg_window" binding:"min=0"`
+	// max seconds after the video, 0 to disable
+	TimeWindow int32 `json:"time_window" yaml:"time_window" binding:"min=0"`
+}
+
+type Companion struct {
+	ID uint `gorm:"primarykey" json:"id"`
+
+	DownloadID uint   `gorm:"uniqueIndex:idx_companion_unique;not null" json:"download_id"`
+	MsgID      int64  `gorm:"uniqueIndex:idx_companion_unique;not null" json:"msg_id"`
+	FileName   string `json:"file_name"`
+	Size       int64  `gorm:"not null" json:"size"`
+
+	Downloaded bool   `gorm:"index;default:false;not null" json:"downloaded"`
+	Path       string `json:"path"`
+	Error      string `json:"error"`
+	// failed times, retried after a backoff growing with it
+	Retries int   `gorm:"not null;default:0" json:"retries"`
+	RetryAt int64 `gorm:"not null;default:0" json:"retry_at"`
+}
+
+type CompanionWithChannelID struct {
+	Companion
+	ChannelID int64
+	VideoPath string
+	// account of the item
+	ItemAccount string
+}
+
+type CompanionRepository struct {
+	Repository
+}
+
+func (repo CompanionRepository) Create(model *Companion) error {
+	return repo.DB.Model(&Companion{}).Clauses(clause.OnConflict{DoNothing: true}).Create(model).Error
+}
+
+// GetPending returns companions not yet downloaded and due to retry, whose video is already in place
+func (repo CompanionRepository) GetPending(limit int, retryBefore int64) ([]CompanionWithChannelID, error) {
+	var companions []CompanionWithChannelID
+	return companions, repo.DB.Model(&Companion{}).
+		Select("companions.*", "items.channel_id", "downloads.path AS video_path", "items.account AS item_account").
+		Joins("INNER JOIN downloads ON downloads.id = companions.download_id").
+		Joins("INNER JOIN items ON items.id = downloads.item_id").
+		Where("companions.downloaded = ? AND companions.retry_at <= ?", false, retryBefore).
+		Where("downloads.downloaded = ? AND downloads.fatal_error = ? AND downloads.path <> ?", true, false, "").
+		Order("companions.id ASC").Limit(limit).Find(&companions).Error
+}
+
+func (repo CompanionRepository) GetByDownloadID(ids ...uint) ([]Companion, error) {
+	var companions []Companion
+	return companions, repo.DB.Model(&Companion{}).Where("download_id IN ?", ids).Order("id ASC").Find(&companions).Error
+}
+
+func (repo CompanionRepository) GetDownloadedByDownloadIDForUpdates(ids ...uint) ([]Companion, error) {
+	var companions []Companion
+	return companions, repo.DB.Model(&Companion{}).Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("download_id IN ? AND downloaded = ?", ids, true).Order("id ASC").Find(&companions).Error
+}
+
+func (repo CompanionRepository) UpdateComplete(id uint, path string) error {
+	model := Companion{
+		ID:         id,
+		Downloaded: true,
+		Path:       path,
+		Error:      "",
+	}
+	return repo.DB.Model(&model).Select("downloaded", "path", "error").Updates(&model).Error
+}
+
+func (repo CompanionRepository) UpdateError(id uint, err string, retries int, retryAt int64) error {
+	model := Companion{
+		ID:      id,
+		Error:   err,
+		Retries: retries,
+		RetryAt: retryAt,
+	}
+	return repo.DB.Model(&model).Select("error", "retries", "retry_at").Updates(&model).Error
+}
+
+func (repo CompanionRepository) UpdatePath(id uint, path string) error {
+	return repo.DB.Model(&Companion{ID: id}).Update("path", path).Error
+}
+
+// ResetByDownloadID marks companions to be downloaded again along with the video
+func (repo CompanionRepository) ResetByDownloadID(ids ...uint) error {
+	return repo.DB.Model(&Companion{}).Where("download_id IN ?", ids).Select("downloaded", "path", "error", "retries", "retry_at").
+		Updates(&Companion{}).Error
+}
+
+func (repo CompanionRepository) DeleteByDownloadID(ids ...uint) error {
+	return repo.DB.Model(&Companion{}).Where("download_id IN ?", ids).Delete(nil).Error
+}
@@ -14,19 +14,26 @@ type Download struct {
 	Text   string
 	Size   int64 `gorm:"not null"`
 	Date   int32 `gorm:"index:idx_global_queue,priority:4,sort:asc;not null"`
+	Path   string
 
 	Priority    int32 `gorm:"index:idx_global_queue,priority:3,sort:desc;not null"`
 	Downloading bool  `gorm:"index:idx_global_queue,priority:2;default:false"`
+	Paused      bool  `gorm:"default:false;not null"`
 	Downloaded  bool  `gorm:"index:idx_global_queue,priority:1;index:idx_item_status;default:false;not null"`
 
-	FatalError bool `gorm:"index:idx_item_status;default:0;not null"`
+	FatalError bool `gorm:"index:idx_item_status;default:false;not null"`
 	Error      string
 	ErrorAt    int64 `gorm:"index:idx_item_status;default:0;not null"`
+
+	// telegram account served the download last time
+	Account string `gorm:"not null;default:''"`
 }
 
 type DownloadWithChannelID struct {
 	Download
 	ChannelID int64
+	// account of the item
+	ItemAccount string
 }
 
 type DownloadTask struct {
@@ -36,12 +43,15 @@ type DownloadTask struct {
 	Text        string       `json:"text"`
 	Size        int64        `json:"size"`
 	Date        int32        `json:"date"`
+	Path        string       `json:"path"`
 	Priority    int32        `json:"priority"`
 	Downloading bool         `json:"downloading"`
+	Paused      bool         `json:"paused"`
 	Downloaded  bool         `json:"downloaded"`
 	FatalError  bool         `json:"fatal_error"`
 	Error       string       `json:"error"`
 	ErrorAt     int64        `json:"error_at"`
+	Account     string       `json:"account"`
 	File        *client.File `json:"file,omitempty" gorm:"-"`
 }
 
@@ -50,16 +60,136 @@ type DownloadForm struct {
 	Priority int32 `json:"priority" form:"priority" binding:"min=1,max=32"`
 }
 
+const (
+	DownloadStateQueued      = "queued" // waiting or downloading
+	DownloadStateWaiting     = "waiting"
+	DownloadStatePaused      = "paused"
+	DownloadStateDownloading = "downloading"
+	DownloadStateDone        = "done"
+	DownloadStateError       = "error"
+	DownloadStateFatal       = "fatal"
+)
+
+type DownloadFilter struct {
+	State     string `json:"state" form:"state" binding:"omitempty,oneof=queued waiting paused downloading done error fatal"`
+	ItemID    uint   `json:"item_id" form:"item_id"`
+	ChannelID int64  `json:"channel_id" form:"channel_id"`
+	DateFrom  int32  `json:"date_from" form:"date_from" binding:"min=0"`
+	DateTo    int32  `json:"date_to" form:"date_to" binding:"min=0"`
+	Search    string `json:"search" form:"search"`
+
+	Sort   string `json:"sort" form:"sort" binding:"omitempty,oneof=queue id date priority size"`
+	Desc   bool   `json:"desc" form:"desc"`
+	Cursor string `json:"cursor" form:"cursor"`
+	Limit  int    `json:"limit" form:"limit" binding:"min=0"`
+}
+
+// HasCriteria reports whether any field narrowing downloads is set, paging and sorting are not counted
+func (filter *DownloadFilter) HasCriteria() bool {
+	return filter.State != "" || filter.ItemID != 0 || filter.ChannelID != 0 ||
+		filter.DateFrom != 0 || filter.DateTo != 0 || filter.Search != ""
+}
+
+var downloadSorts = map[string]keyset[DownloadTask]{
+	// same order as GetForDownload
+	"queue": {
+		columns: []SortColumn{
+			{Column: "CASE WHEN downloads.downloading THEN 1 ELSE 0 END", Desc: true},
+			{Column: "downloads.priority", Desc: true},
+			{Column: "downloads.date"},
+			{Column: "downloads.id"},
+		},
+		values: func(task *DownloadTask) []int64 {
+			return []int64{boolInt(task.Downloading), int64(task.Priority), int64(task.Date), int64(task.ID)}
+		},
+	},
+	"id": {
+		columns: []SortColumn{{Column: "downloads.id"}},
+		values: func(task *DownloadTask) []int64 {
+			return []int64{int64(task.ID)}
+		},
+	},
+	"date": {
+		columns: []SortColumn{{Column: "downloads.date"}, {Column: "downloads.id"}},
+		values: func(task *DownloadTask) []int64 {
+			return []int64{int64(task.Date), int64(task.ID)}
+		},
+	},
+	"priority": {
+		columns: []SortColumn{{Column: "downloads.priority"}, {Column: "downloads.id"}},
+		values: func(task *DownloadTask) []int64 {
+			return []int64{int64(task.Priority), int64(task.ID)}
+		},
+	},
+	"size": {
+		columns: []SortColumn{{Column: "downloads.size"}, {Column: "downloads.id"}},
+		values: func(task *DownloadTask) []int64 {
+			return []int64{task.Size, int64(task.ID)}
+		},
+	},
+}
+
 type DownloadRepository struct {
 	Repository
 }
 
+func (repo DownloadRepository) applyFilter(tx *gorm.DB, filter *DownloadFilter) *gorm.DB {
+	switch filter.State {
+	case DownloadStateQueued:
+		tx = tx.Where("downloads.downloaded = ?", false)
+	case DownloadStateWaiting:
+		tx = tx.Where("downloads.downloading = ? AND downloads.downloaded = ? AND downloads.paused = ?", false, false, false)
+	case DownloadStatePaused:
+		tx = tx.Where("downloads.downloaded = ? AND downloads.paused = ?", false, true)
+	case DownloadStateDownloading:
+		tx = tx.Where("downloads.downloading = ? AND downloads.downloaded = ?", true, false)
+	case DownloadStateDone:
+		tx = tx.Where("downloads.downloaded = ? AND downloads.fatal_error = ?", true, false)
+	case DownloadStateError:
+		tx = tx.Where("downloads.downloaded = ? AND downloads.fatal_error = ? AND downloads.error_at > ?", false, false, 0)
+	case DownloadStateFatal:
+		tx = tx.Where("downloads.downloaded = ? AND downloads.fatal_error = ?", true, true)
+	}
+	if filter.ItemID != 0 {
+		tx = tx.Where("downloads.item_id = ?", filter.ItemID)
+	}
+	if filter.ChannelID != 0 {
+		tx = tx.Where("downloads.item_id IN (?)", repo.DB.Model(&Item{}).Select("id").Where("channel_id = ?", filter.ChannelID))
+	}
+	if filter.DateFrom != 0 {
+		tx = tx.Where("downloads.date >= ?", filter.DateFrom)
+	}
+	if filter.DateTo != 0 {
+		tx = tx.Where("downloads.date <= ?", filter.DateTo)
+	}
+	if filter.Search != "" {
+		tx = repo.whereContains(tx, "downloads.text", filter.Search)
+	}
+	return tx
+}
+
+// GetPage sorts by id if sort key is not specified
+func (repo DownloadRepository) GetPage(filter *DownloadFilter) (*Page[DownloadTask], error) {
+	sort, ok := downloadSorts[filter.Sort]
+	if !ok {
+		sort = downloadSorts["id"]
+	}
+	if filter.Desc {
+		sort = sort.reverse()
+	}
+
+	tx := repo.applyFilter(repo.DB.Model(&Download{}), filter)
+	return findPage(tx, sort, filter.Cursor, filter.Limit, func(tx *gorm.DB) *gorm.DB {
+		return tx.Select("downloads.*")
+	})
+}
+
 func (repo DownloadRepository) joinInnerItems(tx *gorm.DB) *gorm.DB {
 	return tx.Joins("INNER JOIN items ON items.id = downloads.item_id")
 }
 
 func (repo DownloadRepository) modelWithChannelID(tx *gorm.DB) *gorm.DB {
-	tx = tx.Model(&Download{}).Select("downloads.*", "items.channel_id")
+	tx = tx.Model(&Download{}).Select("downloads.*", "items.channel_id", "items.account AS item_account")
 	return repo.joinInnerItems(tx)
 }
 
@@ -109,13 +239,22 @@ func (repo DownloadRepository) FirstByIDForUpdate(id uint) (*Download, error) {
 func (repo DownloadRepository) GetForDownload(limit *int) ([]DownloadWithChannelID, error) {
 	var models []DownloadWithChannelID
 	tx := repo.modelWithChannelID(repo.DB)
-	tx = tx.Where("downloading=? AND downloaded=?", false, false).Order("downloads.priority DESC,date ASC,downloads.id ASC")
+	tx = tx.Where("downloading=? AND downloaded=? AND paused=?", false, false, false).Order("downloads.priority DESC,date ASC,downloads.id ASC")
 	if limit != nil {
 		tx = tx.Limit(*limit)
 	}
 	return models, tx.Find(&models).Error
 }
 
+// FirstPrecedingByItemID returns the nearest download before msgID, that is posted after minMsgID or minDate
+func (repo DownloadRepository) FirstPrecedingByItemID(itemID uint, msgID, minMsgID int64, minDate int32) (*Download, error) {
+	var download Download
+	return &download, repo.DB.Model(&Download{}).
+		Where("item_id = ? AND msg_id < ?", itemID, msgID).
+		Where("msg_id >= ? OR date >= ?", minMsgID, minDate).
+		Order("msg_id DESC").First(&download).Error
+}
+
 func (repo DownloadRepository) GetIDByItemForUpdates(itemID uint) ([]uint, error) {
 	var ids []uint
 	return ids, repo.DB.Model(&Download{}).Clauses(clause.Locking{Strength: "UPDATE"}).Select("id").Where("item_id = ?", itemID).Find(&ids).Error
@@ -127,6 +266,21 @@ func (repo DownloadRepository) GetDownloading() ([]DownloadWithChannelID, error)
 	return downloads, tx.Where("downloads.downloaded=? AND downloads.downloading=?", false, true).Find(&downloads).Error
 }
 
+type ItemDownloadCount struct {
+	ItemID uint
+	Name   string
+	Count  int64
+}
+
+// CountWaitingByItem counts downloads not started yet of every item has them
+func (repo DownloadRepository) CountWaitingByItem() ([]ItemDownloadCount, error) {
+	var counts []ItemDownloadCount
+	tx := repo.applyFilter(repo.DB.Model(&Download{}), &DownloadFilter{State: DownloadStateWaiting})
+	return counts, repo.joinInnerItems(tx).
+		Select("downloads.item_id", "items.name", "COUNT(*) AS count").
+		Group("downloads.item_id, items.name").Find(&counts).Error
+}
+
 func (repo DownloadRepository) GetByID(ids ...uint) ([]DownloadTask, error) {
 	var tasks []DownloadTask
 	return tasks, repo.DB.Model(&Download{}).Where("id IN ?", ids).Find(&tasks).Error
@@ -137,8 +291,25 @@ func (repo DownloadRepository) GetByItemID(id uint) ([]DownloadTask, error) {
 	return tasks, repo.DB.Model(&Download{}).Where("item_id = ?", id).Find(&tasks).Error
 }
 
-func (repo DownloadRepository) SetDownloading(id uint) error {
-	return repo.DB.Model(&Download{}).Where("id=?", id).Update("downloading", true).Error
+func (repo DownloadRepository) GetDownloadedByItemID(id uint) ([]Download, error) {
+	var downloads []Download
+	return downloads, repo.DB.Model(&Download{}).
+		Where("item_id = ? AND downloaded = ? AND fatal_error = ?", id, true, false).
+		Order("id ASC").Find(&downloads).Error
+}
+
+func (repo DownloadRepository) GetDownloadedByItemIDForUpdates(id uint) ([]Download, error) {
+	var downloads []Download
+	return downloads, repo.DB.Model(&Download{}).Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("item_id = ? AND downloaded = ? AND fatal_error = ?", id, true, false).
+		Order("id ASC").Find(&downloads).Error
+}
+
+func (repo DownloadRepository) SetDownloading(id uint, account string) error {
+	return repo.DB.Model(&Download{}).Where("id=?", id).Updates(map[string]any{
+		"downloading": true,
+		"account":     account,
+	}).Error
 }
 
 func (repo DownloadRepository) UpdatePriority(id uint, priority int32) (bool, error) {
@@ -183,14 +354,69 @@ func (repo DownloadRepository) UpdateResetDownloadState(id uint) (bool, error) {
 	return result.RowsAffected > 0, result.Error
 }
 
-func (repo DownloadRepository) UpdateDownloadComplete(id uint) error {
+func (repo DownloadRepository) UpdateDownloadComplete(id uint, path string) error {
 	model := Download{
 		ID:          id,
+		Path:        path,
 		Downloading: false,
 		Downloaded:  true,
 		FatalError:  false,
 	}
-	return repo.DB.Model(&model).Select("downloading", "downloaded", "fatal_error").Updates(&model).Error
+	return repo.DB.Model(&model).Select("path", "downloading", "downloaded", "fatal_error").Updates(&model).Error
+}
+
+func (repo DownloadRepository) UpdatePath(id uint, path string) error {
+	return repo.DB.Model(&Download{ID: id}).Update("path", path).Error
+}
+
+// GetWithChannelIDForUpdates locks downloads in ids or matched by filter, at least one of them should be given
+func (repo DownloadRepository) GetWithChannelIDForUpdates(ids []uint, filter *DownloadFilter) ([]DownloadWithChannelID, error) {
+	var downloads []DownloadWithChannelID
+	tx := repo.modelWithChannelID(repo.DB).Clauses(clause.Locking{Strength: "UPDATE"})
+	switch {
+	case len(ids) != 0 && filter != nil:
+		tx = tx.Where("downloads.id IN ? OR downloads.id IN (?)", ids, repo.applyFilter(repo.DB.Model(&Download{}).Select("downloads.id"), filter))
+	case len(ids) != 0:
+		tx = tx.Where("downloads.id IN ?", ids)
+	case filter != nil:
+		tx = repo.applyFilter(tx, filter)
+	default:
+		return nil, nil
+	}
+	return downloads, tx.Order("downloads.id ASC").Find(&downloads).Error
+}
+
+func (repo DownloadRepository) UpdateResetDownloadStateByIDs(ids ...uint) error {
+	return repo.DB.Model(&Download{}).Where("id IN ?", ids).Select(
+		"downloading", "downloaded", "fatal_error", "error", "error_at",
+	).Updates(&Download{}).Error
+}
+
+func (repo DownloadRepository) UpdatePriorityByIDs(priority int32, ids ...uint) error {
+	return repo.DB.Model(&Download{}).Where("id IN ?", ids).Update("priority", priority).Error
+}
+
+// UpdatePausedByIDs also clears downloading state, paused downloads are skipped by GetForDownload
+func (repo DownloadRepository) UpdatePausedByIDs(ids ...uint) error {
+	return repo.DB.Model(&Download{}).Where("id IN ? AND downloaded = ?", ids, false).Select(
+		"paused", "downloading",
+	).Updates(&Download{Paused: true, Downloading: false}).Error
+}
+
+// UpdateWaitingByIDs clears downloading state, downloads are started again by GetForDownload
+func (repo DownloadRepository) UpdateWaitingByIDs(ids ...uint) error {
+	return repo.DB.Model(&Download{}).Where("id IN ?", ids).Update("downloading", false).Error
+}
+
+// UpdateStartByIDs unpauses downloads and clears errors of failed ones, completed downloads are left
+func (repo DownloadRepository) UpdateStartByIDs(ids ...uint) error {
+	return repo.DB.Model(&Download{}).Where("id IN ? AND (downloaded = ? OR fatal_error = ?)", ids, false, true).Select(
+		"paused", "downloaded", "fatal_error", "error", "error_at",
+	).Updates(&Download{}).Error
+}
+
+func (repo DownloadRepository) DeleteByIDs(ids ...uint) error {
+	return repo.DB.Model(&Download{}).Where("id IN ?", ids).Delete(nil).Error
 }
 
 func (repo DownloadRepository) DeleteByID(id uint) (bool, error) {
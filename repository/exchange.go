@@ -0,0 +1,161 @@
+package repository
+
+import (
+	"github.com/jinzhu/copier"
+	"gorm.io/gorm/clause"
+)
+
+// ItemExportVersion is increased on incompatible changes of ItemExport
+const ItemExportVersion = 1
+
+const (
+	ImportConflictSkip      = "skip"
+	ImportConflictOverwrite = "overwrite"
+	ImportConflictRename    = "rename"
+)
+
+type ItemExport struct {
+	Version    int              `json:"version" yaml:"version" binding:"required"`
+	ExportedAt int64            `json:"exported_at" yaml:"exported_at"`
+	Items      []ItemDefinition `json:"items" yaml:"items" binding:"dive"`
+}
+
+// ItemDefinition is an item without instance specific id, items are identified by channel and name
+type ItemDefinition struct {
+	ChannelID    int64  `json:"channel_id" yaml:"channel_id" binding:"required"`
+	Name         string `json:"name" yaml:"name" binding:"required"`
+	Regexp       string `json:"regexp" yaml:"regexp" binding:"required"`
+	Pattern      string `json:"pattern" yaml:"pattern" binding:"required"`
+	MatchPattern string `json:"match_pattern" yaml:"match_pattern" binding:"required"`
+	MatchContent string `json:"match_content" yaml:"match_content" binding:"required"`
+	DateEnd      int32  `json:"date_end" yaml:"date_end" binding:"required"`
+	Process      int64  `json:"process" yaml:"process"`
+	Priority     int32  `json:"priority" yaml:"priority" binding:"min=1,max=32"`
+	TargetPath   string `json:"target_path" yaml:"target_path" binding:"required"`
+
+	RealSearchID   uint   `json:"realsearch_id,omitempty" yaml:"realsearch_id,omitempty"`
+	Nfo            bool   `json:"nfo,omitempty" yaml:"nfo,omitempty"`
+	EpisodePattern string `json:"episode_pattern,omitempty" yaml:"episode_pattern,omitempty"`
+
+	MatchRules     *MatchRules     `json:"match_rules,omitempty" yaml:"match_rules,omitempty"`
+	CompanionRules []CompanionRule `json:"companion_rules,omitempty" yaml:"companion_rules,omitempty" binding:"dive"`
+
+	// bound again on scan if not configured in this instance
+	Account string `json:"account,omitempty" yaml:"account,omitempty"`
+
+	Downloads []DownloadDefinition `json:"downloads,omitempty" yaml:"downloads,omitempty" binding:"dive"`
+}
+
+type DownloadDefinition struct {
+	MsgID      int64  `json:"msg_id" yaml:"msg_id" binding:"required"`
+	Text       string `json:"text" yaml:"text"`
+	Size       int64  `json:"size" yaml:"size"`
+	Date       int32  `json:"date" yaml:"date"`
+	Priority   int32  `json:"priority" yaml:"priority" binding:"min=1,max=32"`
+	Downloaded bool   `json:"downloaded,omitempty" yaml:"downloaded,omitempty"`
+	Path       string `json:"path,omitempty" yaml:"path,omitempty"`
+}
+
+func NewItemDefinition(item *Item, downloads []Download) ItemDefinition {
+	var definition ItemDefinition
+	if err := copier.Copy(&definition, item); err != nil {
+		panic(err)
+	}
+	definition.Downloads = nil
+	for _, download := range downloads {
+		definition.Downloads = append(definition.Downloads, DownloadDefinition{
+			MsgID:      download.MsgID,
+			Text:       download.Text,
+			Size:       download.Size,
+			Date:       download.Date,
+			Priority:   download.Priority,
+			Downloaded: download.Downloaded,
+			Path:       download.Path,
+		})
+	}
+	return definition
+}
+
+func (definition ItemDefinition) Item() Item {
+	var item Item
+	if err := copier.Copy(&item, &definition); err != nil {
+		panic(err)
+	}
+	return item
+}
+
+func (definition ItemDefinition) DownloadModels(itemID uint) []Download {
+	var models = make([]Download, len(definition.Downloads))
+	for i, download := range definition.Downloads {
+		models[i] = Download{
+			ItemID:     itemID,
+			MsgID:      download.MsgID,
+			Text:       download.Text,
+			Size:       download.Size,
+			Date:       download.Date,
+			Priority:   download.Priority,
+			Downloaded: download.Downloaded,
+			Path:       download.Path,
+		}
+	}
+	return models
+}
+
+// GetForExport returns items in ids, or all items if ids is empty
+func (repo ItemRepository) GetForExport(ids ...uint) ([]Item, error) {
+	var items []Item
+	tx := repo.DB.Model(&Item{})
+	if len(ids) != 0 {
+		tx = tx.Where("id IN ?", ids)
+	}
+	return items, tx.Order("id ASC").Find(&items).Error
+}
+
+func (repo ItemRepository) FirstByChannelAndNameForUpdates(channelID int64, name string) (*Item, error) {
+	var item Item
+	return &item, repo.DB.Model(&item).Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("channel_id = ? AND name = ?", channelID, name).First(&item).Error
+}
+
+func (repo ItemRepository) FirstByChannelAndName(channelID int64, name string) (*Item, error) {
+	var item Item
+	return &item, repo.DB.Model(&item).Where("channel_id = ? AND name = ?", channelID, name).First(&item).Error
+}
+
+func (repo ItemRepository) GetNamesByChannelAndPrefix(channelID int64, prefix string) ([]string, error) {
+	var names []string
+	return names, repo.DB.Model(&Item{}).Where("channel_id = ? AND name LIKE ? ESCAPE '!'", channelID, likeEscaper.Replace(prefix)+"%").Pluck("name", &names).Error
+}
+
+func (repo ItemRepository) Create(item *Item) error {
+	return repo.DB.Model(&Item{}).Create(item).Error
+}
+
+// UpdateDefinition overwrites columns of the item defined by rules, including zero values.
+// Progress of scans and the account bound are state of this instance, they are kept.
+func (repo ItemRepository) UpdateDefinition(item *Item) error {
+	return repo.DB.Model(item).Select("*").Omit("id", "process", "date_end", "account").Updates(item).Error
+}
+
+func (repo DownloadRepository) GetByItemIDs(ids ...uint) ([]Download, error) {
+	var downloads []Download
+	return downloads, repo.DB.Model(&Download{}).Where("item_id IN ?", ids).Order("item_id ASC,msg_id ASC").Find(&downloads).Error
+}
+
+// CountByItemIDAndMsgIDs counts downloads of messages already added to the item
+func (repo DownloadRepository) CountByItemIDAndMsgIDs(itemID uint, msgIDs []int64) (int64, error) {
+	if len(msgIDs) == 0 {
+		return 0, nil
+	}
+	var count int64
+	return count, repo.DB.Model(&Download{}).Where("item_id = ? AND msg_id IN ?", itemID, msgIDs).Count(&count).Error
+}
+
+// CreateAllSkipExisting creates downloads, messages already added to the item are skipped
+func (repo DownloadRepository) CreateAllSkipExisting(models []Download) (int64, error) {
+	if len(models) == 0 {
+		return 0, nil
+	}
+	result := repo.DB.Model(&Download{}).Clauses(clause.OnConflict{DoNothing: true}).Create(&models)
+	return result.RowsAffected, result.Error
+}
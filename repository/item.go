@@ -2,6 +2,7 @@ package repository
 
 import (
 	"github.com/jinzhu/copier"
+	"gorm.io/gorm"
 	"gorm.io/gorm/clause"
 )
 
@@ -21,6 +22,16 @@ type Item struct {
 
 	Priority   int32  `gorm:"not null" json:"priority"`
 	TargetPath string `gorm:"not null" json:"target_path"`
+
+	RealSearchID   uint   `gorm:"not null;default:0" json:"realsearch_id"`
+	Nfo            bool   `gorm:"not null;default:false" json:"nfo"`
+	EpisodePattern string `gorm:"not null;default:''" json:"episode_pattern"`
+
+	MatchRules     *MatchRules     `gorm:"serializer:json" json:"match_rules"`
+	CompanionRules []CompanionRule `gorm:"serializer:json" json:"companion_rules"`
+
+	// telegram account scanning the channel
+	Account string `gorm:"not null;default:'default'" json:"account"`
 }
 
 type NewItemForm struct {
@@ -34,6 +45,16 @@ type NewItemForm struct {
 	Process      int64  `json:"process" form:"process"`
 	Priority     int32  `json:"priority" form:"priority" binding:"min=1,max=32"`
 	TargetPath   string `json:"target_path" form:"target_path" binding:"required"`
+
+	RealSearchID   uint   `json:"realsearch_id" form:"realsearch_id"`
+	Nfo            bool   `json:"nfo" form:"nfo"`
+	EpisodePattern string `json:"episode_pattern" form:"episode_pattern"`
+
+	MatchRules     *MatchRules     `json:"match_rules" form:"match_rules"`
+	CompanionRules []CompanionRule `json:"companion_rules" form:"companion_rules" binding:"dive"`
+
+	// the first account can see the channel if empty
+	Account string `json:"account" form:"account"`
 }
 
 type UpdateItemForm struct {
@@ -44,12 +65,118 @@ type UpdateItemForm struct {
 	MatchContent string `json:"match_content" form:"match_content" binding:"required"`
 	Priority     int32  `json:"priority" form:"priority" binding:"min=1,max=32"`
 	TargetPath   string `json:"target_path" form:"target_path"`
+
+	// fields below are kept if not sent, and cleared with zero values
+	RealSearchID   *uint   `json:"realsearch_id" form:"realsearch_id"`
+	Nfo            *bool   `json:"nfo" form:"nfo"`
+	EpisodePattern *string `json:"episode_pattern" form:"episode_pattern"`
+
+	MatchRules     *MatchRules     `json:"match_rules" form:"match_rules"`
+	CompanionRules []CompanionRule `json:"companion_rules" form:"companion_rules" binding:"dive"`
+}
+
+type ItemWithStats struct {
+	Item
+	Done    int64 `json:"done"`
+	Pending int64 `json:"pending"`
+	Failed  int64 `json:"failed"`
+}
+
+const (
+	ItemStatusActive   = "active" // has pending downloads or updated recently
+	ItemStatusInactive = "inactive"
+	ItemStatusPending  = "pending"
+	ItemStatusError    = "error"
+)
+
+type ItemFilter struct {
+	Search     string `json:"search" form:"search"`
+	ChannelID  int64  `json:"channel_id" form:"channel_id"`
+	TargetPath string `json:"target_path" form:"target_path"`
+	Status     string `json:"status" form:"status" binding:"omitempty,oneof=active inactive pending error"`
+	// used by active and inactive status
+	ActiveAfter int32 `json:"active_after" form:"active_after" binding:"min=0"`
+
+	Sort   string `json:"sort" form:"sort" binding:"omitempty,oneof=id date_end"`
+	Desc   bool   `json:"desc" form:"desc"`
+	Cursor string `json:"cursor" form:"cursor"`
+	Limit  int    `json:"limit" form:"limit" binding:"min=0"`
+}
+
+var itemSorts = map[string]keyset[ItemWithStats]{
+	"id": {
+		columns: []SortColumn{{Column: "items.id"}},
+		values: func(item *ItemWithStats) []int64 {
+			return []int64{int64(item.ID)}
+		},
+	},
+	"date_end": {
+		columns: []SortColumn{{Column: "items.date_end"}, {Column: "items.id"}},
+		values: func(item *ItemWithStats) []int64 {
+			return []int64{int64(item.DateEnd), int64(item.ID)}
+		},
+	},
 }
 
 type ItemRepository struct {
 	Repository
 }
 
+func (repo ItemRepository) downloadsOfItem() *gorm.DB {
+	return repo.DB.Model(&Download{}).Where("downloads.item_id = items.id")
+}
+
+func (repo ItemRepository) pendingDownloadsOfItem() *gorm.DB {
+	return repo.downloadsOfItem().Where("downloads.downloaded = ?", false)
+}
+
+func (repo ItemRepository) errorDownloadsOfItem() *gorm.DB {
+	return repo.downloadsOfItem().Where(
+		"(downloads.downloaded = ? AND downloads.fatal_error = ? AND downloads.error_at > ?) OR (downloads.downloaded = ? AND downloads.fatal_error = ?)",
+		false, false, 0, true, true,
+	)
+}
+
+func (repo ItemRepository) GetPage(filter *ItemFilter) (*Page[ItemWithStats], error) {
+	sort, ok := itemSorts[filter.Sort]
+	if !ok {
+		sort = itemSorts["id"]
+	}
+	if filter.Desc {
+		sort = sort.reverse()
+	}
+
+	tx := repo.DB.Model(&Item{})
+	if filter.Search != "" {
+		tx = repo.whereContains(tx, "items.name", filter.Search)
+	}
+	if filter.ChannelID != 0 {
+		tx = tx.Where("items.channel_id = ?", filter.ChannelID)
+	}
+	if filter.TargetPath != "" {
+		tx = repo.whereContains(tx, "items.target_path", filter.TargetPath)
+	}
+	switch filter.Status {
+	case ItemStatusActive:
+		tx = tx.Where("(EXISTS (?) OR items.date_end > ?)", repo.pendingDownloadsOfItem().Select("1"), filter.ActiveAfter)
+	case ItemStatusInactive:
+		tx = tx.Where("NOT EXISTS (?) AND items.date_end <= ?", repo.pendingDownloadsOfItem().Select("1"), filter.ActiveAfter)
+	case ItemStatusPending:
+		tx = tx.Where("EXISTS (?)", repo.pendingDownloadsOfItem().Select("1"))
+	case ItemStatusError:
+		tx = tx.Where("EXISTS (?)", repo.errorDownloadsOfItem().Select("1"))
+	}
+
+	return findPage(tx, sort, filter.Cursor, filter.Limit, func(tx *gorm.DB) *gorm.DB {
+		return tx.Select(
+			"items.*, (?) AS done, (?) AS pending, (?) AS failed",
+			repo.downloadsOfItem().Select("COUNT(*)").Where("downloads.downloaded = ? AND downloads.fatal_error = ?", true, false),
+			repo.pendingDownloadsOfItem().Select("COUNT(*)"),
+			repo.downloadsOfItem().Select("COUNT(*)").Where("downloads.fatal_error = ?", true),
+		)
+	})
+}
+
 func (repo ItemRepository) CreateWithForm(form *NewItemForm) (*Item, error) {
 	var item Item
 	if err := copier.Copy(&item, form); err != nil {
@@ -68,9 +195,16 @@ func (repo ItemRepository) FirstItemByIDForUpdates(id uint) (*Item, error) {
 	return &item, repo.DB.Model(&item).Clauses(clause.Locking{Strength: "UPDATE"}).Where("id = ?", id).First(&item).Error
 }
 
-func (repo ItemRepository) GetForUpdates(dateEndAfter int32, channelIds ...int64) ([]Item, error) {
+// GetForUpdates locks items ended after dateEndAfter, of accounts in accounts and not in exceptAccounts if not nil
+func (repo ItemRepository) GetForUpdates(dateEndAfter int32, accounts, exceptAccounts []string, channelIds ...int64) ([]Item, error) {
 	var items []Item
 	tx := repo.DB.Model(&Item{})
+	if accounts != nil {
+		tx = tx.Where("account IN ?", accounts)
+	}
+	if len(exceptAccounts) != 0 {
+		tx = tx.Where("account NOT IN ?", exceptAccounts)
+	}
 	if len(channelIds) != 0 {
 		tx = tx.Where("channel_id IN ?", channelIds)
 	}
@@ -104,13 +238,15 @@ func (repo ItemRepository) GetActive(dateEnd int32) ([]Item, error) {
 	return result, nil
 }
 
+// GetError returns items have downloads in error or fatal state
 func (repo ItemRepository) GetError() ([]Item, error) {
 	var items []Item
-	return items, repo.DB.Model(&Item{}).Where(
-		"EXISTS (?)", repo.DB.Model(&Download{}).Where(
-			"downloads.item_id = items.id",
-		).Where(
-			"(downloaded = FALSE AND fatal_error = FALSE AND error_at > 0) OR (downloaded = TRUE AND fatal_error = TRUE)",
+	downloadRepo := DownloadRepository{Repository: repo.Repository}
+	return items, repo.DB.Model(&Item{}).Where("EXISTS (?)",
+		repo.DB.Model(&Download{}).Select("1").Where("downloads.item_id = items.id").Where(
+			downloadRepo.applyFilter(repo.DB, &DownloadFilter{State: DownloadStateError}).Or(
+				downloadRepo.applyFilter(repo.DB, &DownloadFilter{State: DownloadStateFatal}),
+			),
 		),
 	).Find(&items).Error
 }
@@ -119,6 +255,10 @@ func (repo ItemRepository) UpdateProcess(id uint, process int64) error {
 	return repo.DB.Model(&Item{ID: id}).Update("process", process).Error
 }
 
+func (repo ItemRepository) UpdateAccount(id uint, account string) error {
+	return repo.DB.Model(&Item{ID: id}).Update("account", account).Error
+}
+
 func (repo ItemRepository) UpdateDateEnd(id uint, dateEnd int32) error {
 	return repo.DB.Model(&Item{ID: id}).Update("date_end", dateEnd).Error
 }
@@ -130,7 +270,26 @@ func (repo ItemRepository) UpdatesItemWithForm(id uint, form *UpdateItemForm) (b
 	}
 	item.ID = id
 	result := repo.DB.Model(&item).Updates(&item)
-	return result.RowsAffected > 0, result.Error
+	if result.Error != nil {
+		return false, result.Error
+	}
+	// zero values are skipped by Updates, fields sent are cleared with them
+	var columns []string
+	if form.RealSearchID != nil {
+		columns = append(columns, "real_search_id")
+	}
+	if form.Nfo != nil {
+		columns = append(columns, "nfo")
+	}
+	if form.EpisodePattern != nil {
+		columns = append(columns, "episode_pattern")
+	}
+	if len(columns) != 0 {
+		if err := repo.DB.Model(&item).Select(columns).Updates(&item).Error; err != nil {
+			return false, err
+		}
+	}
+	return result.RowsAffected > 0, nil
 }
 
 func (repo ItemRepository) DeleteByID(id uint) error {
@@ -0,0 +1,48 @@
+package repository
+
+import "testing"
+
+func TestUpdatesItemWithFormKeepsFieldsNotSent(t *testing.T) {
+	repo := ItemRepository{Repository: newTestRepository(t)}
+	item := Item{
+		Name:           "item",
+		Regexp:         `(\d+)`,
+		Pattern:        "E$1",
+		MatchPattern:   "$1",
+		MatchContent:   "01",
+		Priority:       1,
+		RealSearchID:   42,
+		Nfo:            true,
+		EpisodePattern: "$1",
+	}
+	if err := repo.DB.Create(&item).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	form := UpdateItemForm{MatchPattern: "$1", MatchContent: "02", Priority: 2}
+	if ok, err := repo.UpdatesItemWithForm(item.ID, &form); err != nil || !ok {
+		t.Fatalf("update got %v, %v", ok, err)
+	}
+	got, err := repo.FirstItemByID(item.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.MatchContent != "02" || got.RealSearchID != 42 || !got.Nfo || got.EpisodePattern != "$1" {
+		t.Errorf("fields not sent are changed: %+v", got)
+	}
+
+	// cleared when sent with zero values
+	var realSearchID uint
+	var nfo bool
+	var episodePattern string
+	form.RealSearchID, form.Nfo, form.EpisodePattern = &realSearchID, &nfo, &episodePattern
+	if ok, err := repo.UpdatesItemWithForm(item.ID, &form); err != nil || !ok {
+		t.Fatalf("update got %v, %v", ok, err)
+	}
+	if got, err = repo.FirstItemByID(item.ID); err != nil {
+		t.Fatal(err)
+	}
+	if got.RealSearchID != 0 || got.Nfo || got.EpisodePattern != "" {
+		t.Errorf("fields sent with zero values are not cleared: %+v", got)
+	}
+}
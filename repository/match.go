@@ -0,0 +1,33 @@
+package repository
+
+const (
+	MatchModeAnd = "and"
+	MatchModeOr  = "or"
+)
+
+const (
+	MatchCaption    = "caption"
+	MatchFileName   = "file_name"
+	MatchHashtag    = "hashtag"
+	MatchSize       = "size"
+	MatchDuration   = "duration"
+	MatchResolution = "resolution"
+)
+
+// MatchRules are checked in addition to match pattern and match content.
+// A message matches when include conditions are satisfied and none of exclude conditions is satisfied.
+type MatchRules struct {
+	// how include conditions are combined, defaults to and
+	Mode    string           `json:"mode" yaml:"mode" binding:"omitempty,oneof=and or"`
+	Include []MatchCondition `json:"include" yaml:"include" binding:"dive"`
+	Exclude []MatchCondition `json:"exclude" yaml:"exclude" binding:"dive"`
+}
+
+type MatchCondition struct {
+	Type string `json:"type" yaml:"type" binding:"required,oneof=caption file_name hashtag size duration resolution"`
+	// used by caption, file_name and hashtag
+	Regexp string `json:"regexp,omitempty" yaml:"regexp,omitempty"`
+	// used by size in bytes, duration in seconds and resolution in height, 0 means unlimited
+	Min int64 `json:"min,omitempty" yaml:"min,omitempty" binding:"min=0"`
+	Max int64 `json:"max,omitempty" yaml:"max,omitempty" binding:"min=0"`
+}
@@ -0,0 +1,86 @@
+package repository
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// SchemaMigration is a migration applied to the database
+type SchemaMigration struct {
+	Version   uint   `gorm:"primarykey;autoIncrement:false" json:"version"`
+	Name      string `gorm:"not null" json:"name"`
+	AppliedAt int64  `gorm:"not null" json:"applied_at"`
+}
+
+// Migration moves the schema from the previous version to Version.
+// It runs in a transaction, but mysql commits DDL statements implicitly, so keep them last there.
+type Migration struct {
+	Version uint
+	Name    string
+	// statements by dialect name, every one of sqlite, mysql and postgres is required if set
+	SQL map[string][]string
+	// runs after SQL, e.g. migrating data, optional
+	Run func(tx *gorm.DB) error
+}
+
+// Migrations in order of version, only append to it and change models along.
+// A database without version is created or upgraded by AutoMigrate of models, and marked at the latest version.
+var Migrations = []Migration{
+	{Version: 1, Name: "baseline"},
+	{Version: 2, Name: "retry failed companions", SQL: map[string][]string{
+		"sqlite": {
+			"ALTER TABLE companions ADD COLUMN retries integer NOT NULL DEFAULT 0",
+			"ALTER TABLE companions ADD COLUMN retry_at integer NOT NULL DEFAULT 0",
+		},
+		"mysql": {
+			"ALTER TABLE companions ADD COLUMN retries bigint NOT NULL DEFAULT 0, ADD COLUMN retry_at bigint NOT NULL DEFAULT 0",
+		},
+		"postgres": {
+			"ALTER TABLE companions ADD COLUMN retries bigint NOT NULL DEFAULT 0, ADD COLUMN retry_at bigint NOT NULL DEFAULT 0",
+		},
+	}},
+	{Version: 3, Name: "actor id of audit logs", SQL: map[string][]string{
+		"sqlite": {
+			"ALTER TABLE audit_logs ADD COLUMN actor_id integer NOT NULL DEFAULT 0",
+			"CREATE INDEX idx_audit_logs_actor_id ON audit_logs(actor_id)",
+		},
+		"mysql": {
+			"ALTER TABLE audit_logs ADD COLUMN actor_id bigint unsigned NOT NULL DEFAULT 0, ADD INDEX idx_audit_logs_actor_id (actor_id)",
+		},
+		"postgres": {
+			"ALTER TABLE audit_logs ADD COLUMN actor_id bigint NOT NULL DEFAULT 0",
+			"CREATE INDEX idx_audit_logs_actor_id ON audit_logs(actor_id)",
+		},
+	}},
+}
+
+// SchemaVersion is the version models describe
+func SchemaVersion() uint {
+	return Migrations[len(Migrations)-1].Version
+}
+
+type MigrationRepository struct {
+	Repository
+}
+
+// Applied returns migrations applied in order of version, none if the database is not versioned yet
+func (repo MigrationRepository) Applied() ([]SchemaMigration, error) {
+	var migrations []SchemaMigration
+	if !repo.DB.Migrator().HasTable(&SchemaMigration{}) {
+		return migrations, nil
+	}
+	return migrations, repo.DB.Model(&SchemaMigration{}).Order("version ASC").Find(&migrations).Error
+}
+
+func (repo MigrationRepository) Create(migrations ...Migration) error {
+	var models = make([]SchemaMigration, len(migrations))
+	for i, migration := range migrations {
+		models[i] = SchemaMigration{
+			Version:   migration.Version,
+			Name:      migration.Name,
+			AppliedAt: time.Now().Unix(),
+		}
+	}
+	return repo.DB.Model(&SchemaMigration{}).Create(&models).Error
+}
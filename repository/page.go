@@ -0,0 +1,142 @@
+package repository
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"gorm.io/gorm"
+)
+
+const (
+	DefaultPageLimit = 100
+	MaxPageLimit     = 1000
+)
+
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+type Page[T any] struct {
+	Total      int64  `json:"total"`
+	Items      []T    `json:"items"`
+	NextCursor string `json:"next_cursor,omitempty"`
+}
+
+type SortColumn struct {
+	Column string
+	Desc   bool
+}
+
+// keyset describes a sort order, the last column must be unique.
+// Cursor values are integers, boolean columns are sorted by a CASE expression, as postgres doesn't compare boolean with them.
+type keyset[T any] struct {
+	columns []SortColumn
+	values  func(model *T) []int64
+}
+
+func (k keyset[T]) reverse() keyset[T] {
+	columns := make([]SortColumn, len(k.columns))
+	for i, column := range k.columns {
+		columns[i] = SortColumn{Column: column.Column, Desc: !column.Desc}
+	}
+	return keyset[T]{columns: columns, values: k.values}
+}
+
+func (k keyset[T]) order(tx *gorm.DB) *gorm.DB {
+	for _, column := range k.columns {
+		if column.Desc {
+			tx = tx.Order(column.Column + " DESC")
+		} else {
+			tx = tx.Order(column.Column + " ASC")
+		}
+	}
+	return tx
+}
+
+// after filters rows behind the cursor, e.g. (a > ?) OR (a = ? AND b > ?)
+func (k keyset[T]) after(tx *gorm.DB, cursor string) (*gorm.DB, error) {
+	if cursor == "" {
+		return tx, nil
+	}
+	parts := strings.Split(cursor, ",")
+	if len(parts) != len(k.columns) {
+		return nil, ErrInvalidCursor
+	}
+	values := make([]any, len(parts))
+	for i, part := range parts {
+		value, err := strconv.ParseInt(part, 10, 64)
+		if err != nil {
+			return nil, ErrInvalidCursor
+		}
+		values[i] = value
+	}
+
+	var conditions = make([]string, len(k.columns))
+	var args = make([]any, 0, len(k.columns)*(len(k.columns)+1)/2)
+	for i, column := range k.columns {
+		var condition strings.Builder
+		condition.WriteString("(")
+		for j := 0; j < i; j++ {
+			condition.WriteString(k.columns[j].Column + " = ? AND ")
+			args = append(args, values[j])
+		}
+		if column.Desc {
+			condition.WriteString(column.Column + " < ?)")
+		} else {
+			condition.WriteString(column.Column + " > ?)")
+		}
+		args = append(args, values[i])
+		conditions[i] = condition.String()
+	}
+	return tx.Where("("+strings.Join(conditions, " OR ")+")", args...), nil
+}
+
+func (k keyset[T]) cursor(model *T) string {
+	values := k.values(model)
+	parts := make([]string, len(values))
+	for i, value := range values {
+		parts[i] = fmt.Sprint(value)
+	}
+	return strings.Join(parts, ",")
+}
+
+// findPage counts total rows of tx, and loads rows after cursor with columns applied by selects
+func findPage[T any](tx *gorm.DB, k keyset[T], cursor string, limit int, selects func(tx *gorm.DB) *gorm.DB) (*Page[T], error) {
+	if limit <= 0 {
+		limit = DefaultPageLimit
+	} else if limit > MaxPageLimit {
+		limit = MaxPageLimit
+	}
+
+	var page = Page[T]{
+		Items: make([]T, 0),
+	}
+	tx = tx.Session(&gorm.Session{})
+	if err := tx.Count(&page.Total).Error; err != nil {
+		return nil, err
+	}
+
+	if selects != nil {
+		tx = selects(tx)
+	}
+	tx, err := k.after(tx, cursor)
+	if err != nil {
+		return nil, err
+	}
+	if err := k.order(tx).Limit(limit + 1).Find(&page.Items).Error; err != nil {
+		return nil, err
+	}
+
+	if len(page.Items) > limit {
+		page.Items = page.Items[:limit]
+		page.NextCursor = k.cursor(&page.Items[limit-1])
+	}
+	return &page, nil
+}
+
+func boolInt(b bool) int64 {
+	if b {
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,73 @@
+package repository
+
+import (
+	"errors"
+	"slices"
+	"testing"
+)
+
+func TestDownloadPageCursor(t *testing.T) {
+	repo := DownloadRepository{Repository: newTestRepository(t)}
+	if err := repo.DB.Create(&Item{Name: "item"}).Error; err != nil {
+		t.Fatal(err)
+	}
+	// ties in every sort column but id
+	for i := 1; i <= 9; i++ {
+		err := repo.DB.Create(&Download{
+			ItemID:      1,
+			MsgID:       int64(i),
+			Size:        int64(i % 3),
+			Date:        int32(i % 4),
+			Priority:    int32(i % 2),
+			Downloading: i%3 == 0,
+		}).Error
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for sort := range downloadSorts {
+		for _, desc := range []bool{false, true} {
+			all, err := repo.GetPage(&DownloadFilter{Sort: sort, Desc: desc})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if all.Total != 9 || len(all.Items) != 9 || all.NextCursor != "" {
+				t.Fatalf("sort %s desc %v: total %d, %d items, next cursor %q", sort, desc, all.Total, len(all.Items), all.NextCursor)
+			}
+
+			var paged []uint
+			var cursor string
+			for range 10 {
+				page, err := repo.GetPage(&DownloadFilter{Sort: sort, Desc: desc, Cursor: cursor, Limit: 2})
+				if err != nil {
+					t.Fatalf("sort %s desc %v: %v", sort, desc, err)
+				}
+				for _, task := range page.Items {
+					paged = append(paged, task.ID)
+				}
+				if cursor = page.NextCursor; cursor == "" {
+					break
+				}
+			}
+
+			var want = make([]uint, len(all.Items))
+			for i, task := range all.Items {
+				want[i] = task.ID
+			}
+			if !slices.Equal(paged, want) {
+				t.Errorf("sort %s desc %v: paged %v, want %v", sort, desc, paged, want)
+			}
+		}
+	}
+}
+
+func TestDownloadPageInvalidCursor(t *testing.T) {
+	repo := DownloadRepository{Repository: newTestRepository(t)}
+	for _, cursor := range []string{"x", "1", "1,2,3", "1,a"} {
+		_, err := repo.GetPage(&DownloadFilter{Sort: "date", Cursor: cursor})
+		if !errors.Is(err, ErrInvalidCursor) {
+			t.Errorf("cursor %q got error %v, want %v", cursor, err, ErrInvalidCursor)
+		}
+	}
+}
@@ -1,11 +1,21 @@
 package repository
 
-import "gorm.io/gorm"
+import (
+	"strings"
+
+	"gorm.io/gorm"
+)
 
 func AutoMigrate(db *gorm.DB) error {
 	return db.AutoMigrate(
 		&Item{},
 		&Download{},
+		&Companion{},
+		&Session{},
+		&ApiToken{},
+		&AuditLog{},
+		&AuditTarget{},
+		&SchemaMigration{},
 	)
 }
 
@@ -28,3 +38,19 @@ func (repo *Repository) Rollback() *gorm.DB {
 func (repo *Repository) Commit() *gorm.DB {
 	return repo.DB.Commit()
 }
+
+// like is the case-insensitive LIKE operator of the dialect, LIKE of sqlite and mysql is already
+func (repo *Repository) like() string {
+	if repo.DB.Dialector.Name() == "postgres" {
+		return "ILIKE"
+	}
+	return "LIKE"
+}
+
+// likeEscaper escapes wildcards in LIKE patterns, '!' is used as backslash is quoted differently among dialects
+var likeEscaper = strings.NewReplacer("!", "!!", "%", "!%", "_", "!_")
+
+// whereContains matches rows whose column contains s literally, case-insensitive
+func (repo *Repository) whereContains(tx *gorm.DB, column, s string) *gorm.DB {
+	return tx.Where(column+" "+repo.like()+" ? ESCAPE '!'", "%"+likeEscaper.Replace(s)+"%")
+}
@@ -0,0 +1,54 @@
+package repository
+
+import (
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+func newTestRepository(t *testing.T) Repository {
+	t.Helper()
+	db, err := gorm.Open(sqlite.Open(filepath.Join(t.TempDir(), "onest.sqlite")), &gorm.Config{
+		Logger: logger.Default.LogMode(logger.Silent),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := AutoMigrate(db); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		if sqlDB, err := db.DB(); err == nil {
+			_ = sqlDB.Close()
+		}
+	})
+	return Repository{DB: db}
+}
+
+func TestWhereContainsEscapesWildcards(t *testing.T) {
+	repo := ItemRepository{Repository: newTestRepository(t)}
+	for _, name := range []string{"100% done", "1000 done", "a_b", "axb", "c!d"} {
+		if err := repo.DB.Create(&Item{Name: name}).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for search, want := range map[string][]string{
+		"0%":   {"100% done"},
+		"a_b":  {"a_b"},
+		"!":    {"c!d"},
+		"done": {"100% done", "1000 done"},
+	} {
+		var names []string
+		if err := repo.whereContains(repo.DB.Model(&Item{}), "items.name", search).Order("id").Pluck("name", &names).Error; err != nil {
+			t.Fatal(err)
+		}
+		if !slices.Equal(names, want) {
+			t.Errorf("search %q got %v, want %v", search, names, want)
+		}
+	}
+}
@@ -0,0 +1,320 @@
+package telegram
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"runtime"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/zelenin/go-tdlib/client"
+)
+
+const (
+	AuthStateConnecting      = "connecting"
+	AuthStateWaitPhoneNumber = "wait_phone_number"
+	AuthStateWaitCode        = "wait_code"
+	AuthStateWaitPassword    = "wait_password"
+	// qr code is shown, waiting for confirmation on a logged in device
+	AuthStateWaitQrConfirmation = "wait_qr_confirmation"
+	// states like email or registration, which have to be completed in an official app first
+	AuthStateUnsupported = "unsupported"
+	AuthStateReady       = "ready"
+	AuthStateLoggingOut  = "logging_out"
+	AuthStateClosed      = "closed"
+)
+
+const (
+	AuthInputPhoneNumber = "phone_number"
+	AuthInputCode        = "code"
+	AuthInputPassword    = "password"
+	// switches to qr code login, without value
+	AuthInputQr = "qr"
+)
+
+// authInputs lists inputs accepted in each state
+var authInputs = map[string][]string{
+	AuthStateWaitPhoneNumber: {AuthInputPhoneNumber, AuthInputQr},
+	AuthStateWaitCode:        {AuthInputCode, AuthInputPhoneNumber, AuthInputQr},
+	AuthStateWaitPassword:    {AuthInputPassword},
+}
+
+var (
+	ErrAuthInput    = errors.New("input is not expected")
+	ErrAuthCanceled = errors.New("authorization canceled, shutting down")
+)
+
+type AuthState struct {
+	State string `json:"state"`
+	// inputs accepted in current state
+	Inputs []string `json:"inputs"`
+	// tg:// link to be encoded in qr code, refreshed by TDLib frequently
+	QrLink string `json:"qr_link,omitempty"`
+	// phone number and the way the code is sent, e.g. telegram_message or sms
+	CodePhoneNumber string `json:"code_phone_number,omitempty"`
+	CodeType        string `json:"code_type,omitempty"`
+	PasswordHint    string `json:"password_hint,omitempty"`
+	// failure of last input or authorization, cleared on state change
+	Error string `json:"error,omitempty"`
+}
+
+type authInput struct {
+	kind   string
+	value  string
+	result chan error
+}
+
+// Authorizer drives authorization of TDLib with inputs submitted from api or terminal.
+// Failed inputs are reported in state and asked again, instead of closing the client.
+type Authorizer struct {
+	logger log.FieldLogger
+	params *client.SetTdlibParametersRequest
+
+	lock  sync.Mutex
+	state AuthState
+	// closed and replaced on every change of state
+	changed chan struct{}
+
+	inputs     chan authInput
+	cancel     chan struct{}
+	cancelOnce sync.Once
+}
+
+func NewAuthorizer(logger log.FieldLogger) *Authorizer {
+	return &Authorizer{
+		logger:  logger,
+		state:   AuthState{State: AuthStateConnecting},
+		changed: make(chan struct{}),
+		inputs:  make(chan authInput),
+		cancel:  make(chan struct{}),
+	}
+}
+
+// codeType converts e.g. authenticationCodeTypeTelegramMessage to telegram_message
+func codeType(t client.AuthenticationCodeType) string {
+	if t == nil {
+		return ""
+	}
+	var b strings.Builder
+	for i, r := range strings.TrimPrefix(t.AuthenticationCodeTypeType(), "authenticationCodeType") {
+		if unicode.IsUpper(r) {
+			if i != 0 {
+				b.WriteByte('_')
+			}
+			r = unicode.ToLower(r)
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+func newAuthState(state client.AuthorizationState) AuthState {
+	switch s := state.(type) {
+	case *client.AuthorizationStateWaitTdlibParameters:
+		return AuthState{State: AuthStateConnecting}
+	case *client.AuthorizationStateWaitPhoneNumber:
+		return AuthState{State: AuthStateWaitPhoneNumber}
+	case *client.AuthorizationStateWaitCode:
+		return AuthState{
+			State:           AuthStateWaitCode,
+			CodePhoneNumber: s.CodeInfo.PhoneNumber,
+			CodeType:        codeType(s.CodeInfo.Type),
+		}
+	case *client.AuthorizationStateWaitPassword:
+		return AuthState{State: AuthStateWaitPassword, PasswordHint: s.PasswordHint}
+	case *client.AuthorizationStateWaitOtherDeviceConfirmation:
+		return AuthState{State: AuthStateWaitQrConfirmation, QrLink: s.Link}
+	case *client.AuthorizationStateReady:
+		return AuthState{State: AuthStateReady}
+	case *client.AuthorizationStateLoggingOut:
+		return AuthState{State: AuthStateLoggingOut}
+	case *client.AuthorizationStateClosing, *client.AuthorizationStateClosed:
+		return AuthState{State: AuthStateClosed}
+	}
+	return AuthState{
+		State: AuthStateUnsupported,
+		Error: fmt.Sprintf("authorization state '%s' is not supported, please complete it in an official app and restart", state.AuthorizationStateType()),
+	}
+}
+
+// setState keeps error of last input if state is not changed, e.g. the code was wrong
+func (a *Authorizer) setState(state AuthState, err error) {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+
+	if err != nil {
+		state.Error = err.Error()
+	} else if state.State == a.state.State && state.Error == "" {
+		state.Error = a.state.Error
+	}
+	state.Inputs = authInputs[state.State]
+	if state.State != a.state.State {
+		a.logger.Debugf("authorization state: %s", state.State)
+	}
+	a.state = state
+	close(a.changed)
+	a.changed = make(chan struct{})
+}
+
+// State returns current state, and a channel closed on next change
+func (a *Authorizer) State() (AuthState, <-chan struct{}) {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+	return a.state, a.changed
+}
+
+// Submit passes input to TDLib, and waits for the state changed by it
+func (a *Authorizer) Submit(ctx context.Context, kind, value string) (AuthState, error) {
+	state, changed := a.State()
+	if !slices.Contains(state.Inputs, kind) {
+		return state, fmt.Errorf("%w: '%s' in state %s", ErrAuthInput, kind, state.State)
+	}
+
+	input := authInput{kind: kind, value: value, result: make(chan error, 1)}
+	select {
+	case a.inputs <- input:
+	case <-a.cancel:
+		return state, ErrAuthCanceled
+	case <-ctx.Done():
+		return state, ctx.Err()
+	}
+
+	var err error
+	select {
+	case err = <-input.result:
+	case <-ctx.Done():
+		return state, ctx.Err()
+	}
+	if err == nil {
+		// state is polled again by client after input accepted
+		select {
+		case <-changed:
+		case <-time.After(time.Second * 5):
+		case <-ctx.Done():
+		}
+	}
+	state, _ = a.State()
+	return state, err
+}
+
+func (a *Authorizer) apply(c *client.Client, stateType string, input authInput) error {
+	if !slices.Contains(authInputs[stateType], input.kind) {
+		return fmt.Errorf("%w: '%s' in state %s", ErrAuthInput, input.kind, stateType)
+	}
+
+	var err error
+	switch input.kind {
+	case AuthInputPhoneNumber:
+		_, err = c.SetAuthenticationPhoneNumber(&client.SetAuthenticationPhoneNumberRequest{
+			PhoneNumber: input.value,
+			Settings:    &client.PhoneNumberAuthenticationSettings{},
+		})
+	case AuthInputCode:
+		_, err = c.CheckAuthenticationCode(&client.CheckAuthenticationCodeRequest{
+			Code: input.value,
+		})
+	case AuthInputPassword:
+		_, err = c.CheckAuthenticationPassword(&client.CheckAuthenticationPasswordRequest{
+			Password: input.value,
+		})
+	case AuthInputQr:
+		_, err = c.RequestQrCodeAuthentication(&client.RequestQrCodeAuthenticationRequest{})
+	}
+	return err
+}
+
+// Handle implements client.AuthorizationStateHandler, it is called until authorization is ready or closed
+func (a *Authorizer) Handle(c *client.Client, state client.AuthorizationState) error {
+	authState := newAuthState(state)
+	a.setState(authState, nil)
+
+	switch authState.State {
+	case AuthStateConnecting:
+		_, err := c.SetTdlibParameters(a.params)
+		return err
+
+	case AuthStateWaitPhoneNumber, AuthStateWaitCode, AuthStateWaitPassword:
+		var input authInput
+		select {
+		case input = <-a.inputs:
+		case <-a.cancel:
+			return a.exitCanceled(c)
+		}
+		err := a.apply(c, authState.State, input)
+		if err != nil {
+			a.logger.Warnf("authorization input %s failed: %v", input.kind, err)
+			a.setState(authState, err)
+		}
+		input.result <- err
+		return nil
+
+	case AuthStateWaitQrConfirmation:
+		// the link is refreshed by polling the state again
+		select {
+		case <-time.After(time.Second):
+			return nil
+		case <-a.cancel:
+			return a.exitCanceled(c)
+		}
+
+	case AuthStateUnsupported:
+		a.logger.Errorln(authState.Error)
+		// waits for restart or shutdown
+		<-a.cancel
+		return a.exitCanceled(c)
+	}
+	return nil
+}
+
+// exitCanceled closes the client and ends the goroutine authorizing, deferred calls of it still run.
+// On error go-tdlib closes the client and polls the state again, the response of a closed client panics its receiver.
+func (a *Authorizer) exitCanceled(c *client.Client) error {
+	listener := c.GetListener()
+	defer listener.Close()
+	if _, err := c.Close(); err != nil {
+		return err
+	}
+	for update := range listener.Updates {
+		if update, ok := update.(*client.UpdateAuthorizationState); ok &&
+			update.AuthorizationState.AuthorizationStateType() == client.TypeAuthorizationStateClosed {
+			break
+		}
+	}
+	a.Fail(ErrAuthCanceled)
+	runtime.Goexit()
+	return ErrAuthCanceled
+}
+
+// Close implements client.AuthorizationStateHandler, called once authorization finished
+func (a *Authorizer) Close() {}
+
+// Cancel rejects inputs submitted later, and closes the client if authorization is waiting for input
+func (a *Authorizer) Cancel() {
+	a.cancelOnce.Do(func() {
+		close(a.cancel)
+	})
+}
+
+// Fail reports failure of authorization, e.g. invalid api hash
+func (a *Authorizer) Fail(err error) {
+	a.setState(AuthState{State: AuthStateClosed}, err)
+}
+
+// watch keeps state updated after authorized, e.g. session terminated from another device
+func (a *Authorizer) watch(listener *client.Listener) {
+	for update := range listener.Updates {
+		if update.GetType() != client.TypeUpdateAuthorizationState {
+			continue
+		}
+		state := newAuthState(update.(*client.UpdateAuthorizationState).AuthorizationState)
+		if state.State == AuthStateLoggingOut || state.State == AuthStateClosed {
+			a.logger.Warnf("telegram session %s, restart to log in again", strings.ReplaceAll(state.State, "_", " "))
+		}
+		a.setState(state, nil)
+	}
+}
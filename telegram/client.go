@@ -9,11 +9,15 @@ import (
 	"strings"
 	"time"
 
+	"github.com/acgn-org/onest/telegram/tdconfig"
 	"github.com/acgn-org/onest/tools"
 	log "github.com/sirupsen/logrus"
 	"github.com/zelenin/go-tdlib/client"
 )
 
+// DefaultAccount is the name of the account configured by the telegram scope itself
+const DefaultAccount = tdconfig.DefaultAccount
+
 type Config struct {
 	Logger  log.FieldLogger
 	Version string
@@ -21,13 +25,21 @@ type Config struct {
 	DataFolder string
 	ApiId      int32
 	ApiHash    string
+
+	// called before waiting for flood control, optional
+	OnFloodWait func(time.Duration)
 }
 
-func New(c *Config, opts ...client.Option) (*Telegram, error) {
+// New authorizes with inputs submitted to authorizer, it blocks until ready
+func New(c *Config, authorizer *Authorizer, opts ...client.Option) (*Telegram, error) {
+	if c.Logger == nil {
+		c.Logger = log.StandardLogger()
+	}
+
 	var databaseDirectory = filepath.Join(c.DataFolder, "database")
 	var filesDirectory = filepath.Join(c.DataFolder, "files")
 
-	authorizer := client.ClientAuthorizer(&client.SetTdlibParametersRequest{
+	authorizer.params = &client.SetTdlibParametersRequest{
 		DatabaseDirectory:   databaseDirectory,
 		FilesDirectory:      filesDirectory,
 		UseChatInfoDatabase: true,
@@ -37,38 +49,44 @@ func New(c *Config, opts ...client.Option) (*Telegram, error) {
 		SystemLanguageCode:  "en-US",
 		DeviceModel:         "onest",
 		ApplicationVersion:  c.Version,
-	})
+	}
 	c.Logger.Infoln("authorizing...")
-	go client.CliInteractor(authorizer)
 	_client, err := client.NewClient(authorizer, opts...)
 	if err != nil {
+		authorizer.Fail(err)
 		return nil, err
 	}
 
-	if c.Logger == nil {
-		c.Logger = log.StandardLogger()
-	}
-
 	user, err := _client.GetMe()
 	if err != nil {
+		authorizer.Fail(err)
 		return nil, err
 	}
 	c.Logger.Debugf("user GetMe: %+v", user)
 
+	authorizer.setState(AuthState{State: AuthStateReady}, nil)
+	authListener := _client.GetListener()
+	go authorizer.watch(authListener)
+
 	return &Telegram{
 		logger:            c.Logger,
 		client:            _client,
+		authListener:      authListener,
 		databaseDirectory: databaseDirectory,
 		filesDirectory:    filesDirectory,
+		onFloodWait:       c.OnFloodWait,
 	}, nil
 }
 
 type Telegram struct {
-	logger log.FieldLogger
-	client *client.Client
+	logger       log.FieldLogger
+	client       *client.Client
+	authListener *client.Listener
 
 	databaseDirectory string
 	filesDirectory    string
+
+	onFloodWait func(time.Duration)
 }
 
 // WithRetry retry if getting 429 errors
@@ -87,6 +105,9 @@ start:
 		coolDownSeconds, err := strconv.ParseInt(coolDown, 10, 64)
 		if err == nil {
 			t.logger.Warnf("reached telegram flood control, will recover after %d seconds", coolDownSeconds)
+			if t.onFloodWait != nil {
+				t.onFloodWait(time.Duration(coolDownSeconds) * time.Second)
+			}
 			select {
 			case <-time.After(time.Duration(coolDownSeconds) * time.Second):
 			case <-ctx.Done():
@@ -100,6 +121,17 @@ start:
 	return fnErr
 }
 
+// Close closes TDLib, pending database changes are flushed
+func (t Telegram) Close() error {
+	t.authListener.Close()
+	_, err := t.client.Close()
+	return err
+}
+
+func (t Telegram) GetMe() (*client.User, error) {
+	return t.client.GetMe()
+}
+
 func (t Telegram) GetListener() *client.Listener {
 	return t.client.GetListener()
 }
@@ -139,6 +171,21 @@ func (t Telegram) GetMessage(ctx context.Context, chatId, messageId int64) (*cli
 	})
 }
 
+func (t Telegram) GetMessageLink(ctx context.Context, chatId, messageId int64) (string, error) {
+	var link *client.MessageLink
+	err := t.WithRetry(ctx, func() (err error) {
+		link, err = t.client.GetMessageLink(&client.GetMessageLinkRequest{
+			ChatId:    chatId,
+			MessageId: messageId,
+		})
+		return err
+	})
+	if err != nil {
+		return "", err
+	}
+	return link.Link, nil
+}
+
 func (t Telegram) GetMessageVideo(msg *client.Message) (*client.MessageVideo, bool) {
 	msgVideo, ok := msg.Content.(*client.MessageVideo)
 	if !ok {
@@ -147,6 +194,14 @@ func (t Telegram) GetMessageVideo(msg *client.Message) (*client.MessageVideo, bo
 	return msgVideo, true
 }
 
+func (t Telegram) GetMessageDocument(msg *client.Message) (*client.MessageDocument, bool) {
+	msgDocument, ok := msg.Content.(*client.MessageDocument)
+	if !ok {
+		return nil, false
+	}
+	return msgDocument, true
+}
+
 func (t Telegram) GetFile(fileID int32) (*client.File, error) {
 	return t.client.GetFile(&client.GetFileRequest{
 		FileId: fileID,
@@ -202,11 +257,14 @@ func (t Telegram) CleanDownloadDirectory() error {
 	if err := tools.CleanDirectory(path.Join(t.filesDirectory, "videos")); err != nil {
 		return err
 	}
+	if err := tools.CleanDirectory(path.Join(t.filesDirectory, "documents")); err != nil {
+		return err
+	}
 	if err := tools.CleanDirectory(path.Join(t.filesDirectory, "temp")); err != nil {
 		return err
 	}
 
-	t.logger.Debugln("removed all video and temp files in download directory")
+	t.logger.Debugln("removed all video, document and temp files in download directory")
 
 	return nil
 }
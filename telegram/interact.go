@@ -0,0 +1,78 @@
+package telegram
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/skip2/go-qrcode"
+)
+
+// ReadLines reads trimmed lines of in, so that they can be shared by several authorizers
+func ReadLines(in io.Reader) <-chan string {
+	lines := make(chan string)
+	go func() {
+		defer close(lines)
+		scanner := bufio.NewScanner(in)
+		for scanner.Scan() {
+			lines <- strings.TrimSpace(scanner.Text())
+		}
+	}()
+	return lines
+}
+
+// Interact asks for inputs of authorization on terminal, it returns true once ready or closed, or false if lines are closed
+func (a *Authorizer) Interact(lines <-chan string, out io.Writer) bool {
+	var lastLink string
+	for {
+		state, changed := a.State()
+
+		var prompt, kind string
+		switch state.State {
+		case AuthStateReady, AuthStateClosed, AuthStateUnsupported:
+			return true
+		case AuthStateWaitPhoneNumber:
+			prompt, kind = "Enter phone number, or 'qr' to log in with QR code: ", AuthInputPhoneNumber
+		case AuthStateWaitCode:
+			prompt, kind = fmt.Sprintf("Enter code sent to %s by %s: ", state.CodePhoneNumber, state.CodeType), AuthInputCode
+		case AuthStateWaitPassword:
+			prompt, kind = "Enter password", AuthInputPassword
+			if state.PasswordHint != "" {
+				prompt += " (hint: " + state.PasswordHint + ")"
+			}
+			prompt += ": "
+		case AuthStateWaitQrConfirmation:
+			if state.QrLink != lastLink {
+				lastLink = state.QrLink
+				if qr, err := qrcode.New(state.QrLink, qrcode.Low); err == nil {
+					_, _ = fmt.Fprintf(out, "Scan the QR code in Settings > Devices > Link Desktop Device of Telegram:\n%s\n", qr.ToSmallString(false))
+				}
+			}
+		}
+
+		if kind == "" {
+			<-changed
+			continue
+		}
+
+		_, _ = fmt.Fprint(out, prompt)
+		select {
+		case <-changed:
+			// answered from the web UI
+			_, _ = fmt.Fprintln(out)
+			continue
+		case line, ok := <-lines:
+			if !ok {
+				return false
+			}
+			if kind == AuthInputPhoneNumber && strings.EqualFold(line, "qr") {
+				kind, line = AuthInputQr, ""
+			}
+			if _, err := a.Submit(context.Background(), kind, line); err != nil {
+				_, _ = fmt.Fprintln(out, "Failed:", err)
+			}
+		}
+	}
+}
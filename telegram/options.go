@@ -1,14 +1,32 @@
 package telegram
 
 import (
-	"net/url"
-	"strconv"
-
+	"github.com/acgn-org/onest/telegram/tdconfig"
 	log "github.com/sirupsen/logrus"
 	"github.com/zelenin/go-tdlib/client"
 	"golang.org/x/net/http/httpproxy"
 )
 
+// ParseProxyUrl parses url by tdconfig.ParseProxyUrl into the request of TDLib
+func ParseProxyUrl(proxyUrlStr string) (*client.AddProxyRequest, error) {
+	proxy, err := tdconfig.ParseProxyUrl(proxyUrlStr)
+	if err != nil {
+		return nil, err
+	}
+
+	var req = client.AddProxyRequest{Server: proxy.Server, Port: proxy.Port}
+	switch proxy.Type {
+	case tdconfig.ProxyHttp:
+		req.Type = &client.ProxyTypeHttp{Username: proxy.Username, Password: proxy.Password}
+	case tdconfig.ProxySocks5:
+		req.Type = &client.ProxyTypeSocks5{Username: proxy.Username, Password: proxy.Password}
+	case tdconfig.ProxyMtproto:
+		req.Type = &client.ProxyTypeMtproto{Secret: proxy.Secret}
+	}
+	return &req, nil
+}
+
+// ProxyFromEnvironment reads HTTP_PROXY or HTTPS_PROXY, which may be a socks5 url as well
 func ProxyFromEnvironment(logger log.FieldLogger) (*client.AddProxyRequest, bool) {
 	env := httpproxy.FromEnvironment()
 	var proxyUrlStr string
@@ -21,38 +39,10 @@ func ProxyFromEnvironment(logger log.FieldLogger) (*client.AddProxyRequest, bool
 		return nil, false
 	}
 
-	proxyUrl, err := url.Parse(proxyUrlStr)
+	req, err := ParseProxyUrl(proxyUrlStr)
 	if err != nil {
 		logger.WithError(err).WithField("url", proxyUrlStr).Errorln("invalid proxy url")
 		return nil, false
-	} else if proxyUrl.Scheme != "http" {
-		logger.Warnf("unsupported scheme '%s'", proxyUrl.Scheme)
-		return nil, false
-	}
-
-	var portStr = proxyUrl.Port()
-	var port int32
-	if portStr == "" {
-		port = 80
-	} else {
-		port64, err := strconv.ParseInt(portStr, 10, 32)
-		if err != nil {
-			logger.WithError(err).WithField("port", portStr).Errorln("invalid proxy port")
-			return nil, false
-		}
-		port = int32(port64)
 	}
-
-	passwd, _ := proxyUrl.User.Password()
-
-	return &client.AddProxyRequest{
-		Enable: true,
-		Server: proxyUrl.Hostname(),
-		Port:   port,
-		Type: &client.ProxyTypeHttp{
-			Username: proxyUrl.User.Username(),
-			Password: passwd,
-			HttpOnly: false,
-		},
-	}, true
+	return req, true
 }
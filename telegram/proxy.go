@@ -0,0 +1,252 @@
+package telegram
+
+import (
+	"errors"
+	"strings"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/zelenin/go-tdlib/client"
+)
+
+// ProxyCheckInterval is the interval of pinging proxies, the enabled one is switched to the next reachable if it fails
+const ProxyCheckInterval = time.Minute
+
+var (
+	ErrProxyNotFound = errors.New("proxy not found")
+	ErrProxyNotReady = errors.New("proxies are not set up yet")
+)
+
+type ProxyState struct {
+	ID int32 `json:"id"`
+	// http, socks5 or mtproto
+	Type    string `json:"type"`
+	Server  string `json:"server"`
+	Port    int32  `json:"port"`
+	Enabled bool   `json:"enabled"`
+	// seconds of the last ping, 0 if failed or not pinged yet
+	Ping      float64 `json:"ping"`
+	Error     string  `json:"error,omitempty"`
+	CheckedAt int64   `json:"checked_at,omitempty"`
+}
+
+// ProxyManager replaces proxies saved in TDLib with the configured ones,
+// and keeps the first reachable of them enabled
+type ProxyManager struct {
+	logger   log.FieldLogger
+	requests []*client.AddProxyRequest
+
+	// closed once proxies are added
+	ready    chan struct{}
+	stop     chan struct{}
+	stopOnce sync.Once
+	done     chan struct{}
+	client   *client.Client
+
+	lock    sync.Mutex
+	proxies []ProxyState
+}
+
+func NewProxyManager(logger log.FieldLogger, requests []*client.AddProxyRequest) *ProxyManager {
+	return &ProxyManager{
+		logger:   logger,
+		requests: requests,
+		ready:    make(chan struct{}),
+		stop:     make(chan struct{}),
+		done:     make(chan struct{}),
+	}
+}
+
+// Option sets up proxies as soon as TDLib is created, which runs along with authorization
+func (m *ProxyManager) Option() client.Option {
+	return func(c *client.Client) {
+		defer close(m.done)
+
+		m.client = c
+		if err := m.setup(); err != nil {
+			m.logger.Errorln("set up proxies failed:", err)
+		}
+		close(m.ready)
+		if len(m.proxies) == 0 {
+			return
+		}
+
+		ticker := time.NewTicker(ProxyCheckInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				m.check()
+			case <-m.stop:
+				return
+			}
+		}
+	}
+}
+
+func proxyType(t client.ProxyType) string {
+	return strings.ToLower(strings.TrimPrefix(t.ProxyTypeType(), "proxyType"))
+}
+
+func (m *ProxyManager) setup() error {
+	saved, err := m.client.GetProxies()
+	if err != nil {
+		return err
+	}
+	for _, proxy := range saved.Proxies {
+		if _, err := m.client.RemoveProxy(&client.RemoveProxyRequest{ProxyId: proxy.Id}); err != nil {
+			return err
+		}
+	}
+
+	var proxies = make([]ProxyState, 0, len(m.requests))
+	for _, req := range m.requests {
+		proxy, err := m.client.AddProxy(&client.AddProxyRequest{
+			Server: req.Server,
+			Port:   req.Port,
+			Type:   req.Type,
+		})
+		if err != nil {
+			m.logger.WithError(err).Errorf("add proxy %s:%d failed", req.Server, req.Port)
+			continue
+		}
+		proxies = append(proxies, ProxyState{
+			ID:     proxy.Id,
+			Type:   proxyType(proxy.Type),
+			Server: proxy.Server,
+			Port:   proxy.Port,
+		})
+	}
+	m.lock.Lock()
+	m.proxies = proxies
+	m.lock.Unlock()
+
+	if len(proxies) == 0 {
+		return nil
+	}
+	m.check()
+	return nil
+}
+
+// check pings every proxy, and switches to the first reachable in order if the enabled one is not
+func (m *ProxyManager) check() {
+	m.lock.Lock()
+	var proxies = make([]ProxyState, len(m.proxies))
+	copy(proxies, m.proxies)
+	m.lock.Unlock()
+
+	var wg sync.WaitGroup
+	for i := range proxies {
+		wg.Add(1)
+		go func(proxy *ProxyState) {
+			defer wg.Done()
+			seconds, err := m.client.PingProxy(&client.PingProxyRequest{ProxyId: proxy.ID})
+			proxy.CheckedAt = time.Now().Unix()
+			if err != nil {
+				proxy.Ping, proxy.Error = 0, err.Error()
+				m.logger.Debugf("ping proxy %s:%d failed: %v", proxy.Server, proxy.Port, err)
+				return
+			}
+			proxy.Ping, proxy.Error = seconds.Seconds, ""
+		}(&proxies[i])
+	}
+	wg.Wait()
+
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	var enabled = -1
+	for i := range m.proxies {
+		m.proxies[i].Ping = proxies[i].Ping
+		m.proxies[i].Error = proxies[i].Error
+		m.proxies[i].CheckedAt = proxies[i].CheckedAt
+		if m.proxies[i].Enabled {
+			enabled = i
+		}
+	}
+	if enabled != -1 && m.proxies[enabled].Error == "" {
+		return
+	}
+
+	var next = -1
+	for i := range m.proxies {
+		if m.proxies[i].Error == "" {
+			next = i
+			break
+		}
+	}
+	if next == -1 {
+		if enabled != -1 {
+			m.logger.Warnln("no proxy is reachable, keep using the enabled one")
+			return
+		}
+		// keep trying through proxies instead of connecting directly
+		m.logger.Warnln("no proxy is reachable, enabling the first one")
+		next = 0
+	}
+	select {
+	case <-m.stop:
+		return
+	default:
+	}
+	if err := m.enable(next); err != nil {
+		m.logger.Errorln("enable proxy failed:", err)
+		return
+	}
+	m.logger.Infof("switched to proxy %s:%d", m.proxies[next].Server, m.proxies[next].Port)
+}
+
+// enable is called with lock held
+func (m *ProxyManager) enable(index int) error {
+	if _, err := m.client.EnableProxy(&client.EnableProxyRequest{ProxyId: m.proxies[index].ID}); err != nil {
+		return err
+	}
+	for i := range m.proxies {
+		m.proxies[i].Enabled = i == index
+	}
+	return nil
+}
+
+// List returns proxies with results of the last check
+func (m *ProxyManager) List() []ProxyState {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	var proxies = make([]ProxyState, len(m.proxies))
+	copy(proxies, m.proxies)
+	return proxies
+}
+
+// Enable switches to proxy of id, it is kept until it fails a check
+func (m *ProxyManager) Enable(id int32) ([]ProxyState, error) {
+	select {
+	case <-m.ready:
+	default:
+		return nil, ErrProxyNotReady
+	}
+
+	m.lock.Lock()
+	var err = ErrProxyNotFound
+	for i := range m.proxies {
+		if m.proxies[i].ID == id {
+			err = m.enable(i)
+			break
+		}
+	}
+	m.lock.Unlock()
+	if err != nil {
+		return nil, err
+	}
+	return m.List(), nil
+}
+
+// Close stops checking, it should be called before closing TDLib and is safe to be called more than once
+func (m *ProxyManager) Close() {
+	m.stopOnce.Do(func() {
+		close(m.stop)
+	})
+	select {
+	case <-m.done:
+	case <-time.After(time.Second):
+		// pings in flight are abandoned
+	}
+}
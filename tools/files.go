@@ -1,7 +1,9 @@
 package tools
 
 import (
+	"errors"
 	"fmt"
+	"io"
 	"os"
 	"path/filepath"
 )
@@ -24,3 +26,75 @@ func CleanDirectory(pathname string) error {
 	}
 	return nil
 }
+
+// ErrRemoveSource is returned by MoveFile when the file has been copied but the source could not be removed
+var ErrRemoveSource = errors.New("remove source file failed")
+
+// MoveFile renames src to dst, falls back to copy and remove when rename is not possible (e.g. across devices)
+func MoveFile(src, dst string, perm os.FileMode) error {
+	return moveFile(src, dst, perm, false)
+}
+
+// MoveFileExclusive is MoveFile failing with os.ErrExist if dst exists, instead of overwriting it
+func MoveFileExclusive(src, dst string, perm os.FileMode) error {
+	return moveFile(src, dst, perm, true)
+}
+
+func moveFile(src, dst string, perm os.FileMode, exclusive bool) error {
+	var flag = os.O_RDWR | os.O_CREATE | os.O_TRUNC
+	if exclusive {
+		// rename overwrites, the window between the check and it is left
+		if _, err := os.Lstat(dst); err == nil {
+			return fmt.Errorf("target '%s': %w", dst, os.ErrExist)
+		} else if !os.IsNotExist(err) {
+			return err
+		}
+		flag = os.O_RDWR | os.O_CREATE | os.O_EXCL
+	}
+
+	if err := os.Rename(src, dst); err == nil {
+		return nil
+	}
+
+	fileSource, err := os.OpenFile(src, os.O_RDONLY, 0600)
+	if err != nil {
+		return fmt.Errorf("open source file failed: %w", err)
+	}
+	defer fileSource.Close()
+
+	fileTarget, err := os.OpenFile(dst, flag, perm)
+	if err != nil {
+		return fmt.Errorf("create file failed: %w", err)
+	}
+
+	buffer := BufferCopy.Get().([]byte)
+	defer BufferCopy.Put(buffer)
+	_, err = io.CopyBuffer(fileTarget, fileSource, buffer)
+	if closeErr := fileTarget.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		// a truncated target would be taken as existing
+		_ = os.Remove(dst)
+		return fmt.Errorf("copy file failed: %w", err)
+	}
+
+	if err := os.Remove(src); err != nil {
+		return fmt.Errorf("%w: %w", ErrRemoveSource, err)
+	}
+	return nil
+}
+
+// EnsureDirectory creates the directory if not exists
+func EnsureDirectory(pathname string, perm os.FileMode) error {
+	info, err := os.Stat(pathname)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return os.MkdirAll(pathname, perm)
+		}
+		return err
+	} else if !info.IsDir() {
+		return fmt.Errorf("target path '%s' is not a directory", pathname)
+	}
+	return nil
+}
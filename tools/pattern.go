@@ -28,3 +28,21 @@ func ConvertPatternRegexp(s string, reg *regexp.Regexp, pattern string) string {
 		return ""
 	})
 }
+
+// CheckPattern reports references in pattern to groups not defined by reg
+func CheckPattern(reg *regexp.Regexp, pattern string) error {
+	var err error
+	os.Expand(pattern, func(s string) string {
+		if err != nil {
+			return ""
+		}
+		i, e := strconv.Atoi(s)
+		if e != nil {
+			err = fmt.Errorf("pattern '%s' references non numeric group '%s'", pattern, s)
+		} else if i < 0 || i > reg.NumSubexp() {
+			err = fmt.Errorf("pattern '%s' references undefined group %d", pattern, i)
+		}
+		return ""
+	})
+	return err
+}
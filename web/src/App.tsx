@@ -1,4 +1,4 @@
-import { type FC, type ReactNode, useMemo, useState } from "react";
+import { type FC, type ReactNode, useEffect, useMemo, useState } from "react";
 import { Outlet, useNavigate, useMatches } from "react-router";
 import { useDisclosure } from "@mantine/hooks";
 import { Toaster } from "react-hot-toast";
@@ -9,7 +9,9 @@ import { createTheme, MantineProvider } from "@mantine/core";
 const theme = createTheme({});
 
 import Picture from "@component/Picture";
+import TelegramLogin from "@component/TelegramLogin";
 import {
+  Alert,
   AppShell,
   Burger,
   Group,
@@ -21,9 +23,24 @@ import {
   Button,
   Text,
 } from "@mantine/core";
-import { IconCloudDown, IconLogs, IconTemplate } from "@tabler/icons-react";
+import {
+  IconBrandTelegram,
+  IconCloudDown,
+  IconLogout,
+  IconLogs,
+  IconTemplate,
+} from "@tabler/icons-react";
+import toast from "react-hot-toast";
+
+import Login from "@page/Login";
 
 import useConfirmDialog from "@store/confirm-dialog.ts";
+import useAuthStore from "@store/auth.ts";
+import useTelegramAuthStore from "@store/telegram-auth.ts";
+
+import useInterval from "@hook/useInterval.ts";
+
+import api from "@network/api.ts";
 
 type NavItem = {
   label: string;
@@ -55,6 +72,57 @@ export const App: FC = () => {
 
   const [opened, { toggle }] = useDisclosure();
 
+  const authEnabled = useAuthStore((state) => state.enabled);
+  const authenticated = useAuthStore((state) => state.authenticated);
+  useEffect(() => {
+    api
+      .get<{ data: Auth.Status }>("auth/status")
+      .then((res) => useAuthStore.setState(res.data.data))
+      .catch((err) => toast.error(`load auth status failed: ${err}`));
+  }, []);
+  const onLogout = async () => {
+    try {
+      await api.post("auth/logout");
+      useAuthStore.setState({ authenticated: false, principal: undefined });
+    } catch (err: unknown) {
+      toast.error(`logout failed: ${err}`);
+    }
+  };
+
+  // server runs degraded until the default telegram account is authorized
+  const telegramAccounts = useTelegramAuthStore((state) => state.accounts);
+  const telegramLoginAccount = useTelegramAuthStore((state) => state.account);
+  const isTelegramLoginOpen = useTelegramAuthStore((state) => state.open);
+  const loadTelegramAccounts = () =>
+    api
+      .get<{ data: TelegramAuth.Account[] }>("telegram/accounts")
+      .then((res) => useTelegramAuthStore.setState({ accounts: res.data.data }))
+      .catch(() => {});
+  const loadTelegramAuth = () =>
+    api
+      .get<{ data: TelegramAuth.State }>("telegram/auth/", {
+        params: { account: telegramLoginAccount },
+      })
+      .then((res) => useTelegramAuthStore.setState({ state: res.data.data }))
+      .catch(() => {});
+  const canLoadTelegramAuth = authenticated === true || authEnabled === false;
+  const telegramAccountsNotReady =
+    telegramAccounts?.filter((account) => !account.ready) ?? [];
+  useEffect(() => {
+    if (canLoadTelegramAuth) loadTelegramAccounts();
+  }, [canLoadTelegramAuth]);
+  useEffect(() => {
+    if (canLoadTelegramAuth && isTelegramLoginOpen) loadTelegramAuth();
+  }, [canLoadTelegramAuth, isTelegramLoginOpen, telegramLoginAccount]);
+  useInterval(
+    loadTelegramAccounts,
+    canLoadTelegramAuth && telegramAccountsNotReady.length !== 0 ? 2000 : null,
+  );
+  useInterval(
+    loadTelegramAuth,
+    canLoadTelegramAuth && isTelegramLoginOpen ? 2000 : null,
+  );
+
   const activeNavItem = useMemo(
     () =>
       navItems.find((item) =>
@@ -141,6 +209,13 @@ export const App: FC = () => {
               active={item === activeNavItem}
             />
           ))}
+          {authEnabled && authenticated && (
+            <NavLink
+              label="Logout"
+              leftSection={<IconLogout size={20} stroke={1.5} />}
+              onClick={onLogout}
+            />
+          )}
         </AppShell.Navbar>
 
         <AppShell.Main
@@ -148,7 +223,9 @@ export const App: FC = () => {
             display: "flex",
           }}
         >
+          {authenticated === false && <Login />}
           <Container
+            display={authenticated === false ? "none" : undefined}
             styles={{
               root: {
                 display: "flex",
@@ -158,8 +235,42 @@ export const App: FC = () => {
               },
             }}
           >
+            {telegramAccountsNotReady.map((account) => (
+              <Alert
+                key={account.name}
+                mb="md"
+                color="yellow"
+                title={
+                  account.name === "default"
+                    ? "Telegram is not logged in"
+                    : `Telegram account ${account.name} is not logged in`
+                }
+                icon={<IconBrandTelegram />}
+              >
+                <Group justify="space-between">
+                  <Text size="sm">
+                    {account.name === "default"
+                      ? "Downloads and new items are paused until the account is authorized."
+                      : "Channels only visible to this account are not scanned until it is authorized."}
+                  </Text>
+                  <Button
+                    size="xs"
+                    onClick={() =>
+                      useTelegramAuthStore.setState({
+                        account: account.name,
+                        state: undefined,
+                        open: true,
+                      })
+                    }
+                  >
+                    Log in
+                  </Button>
+                </Group>
+              </Alert>
+            ))}
             <Title order={2}>{activeNavItem?.label ?? "404"}</Title>
             <Outlet />
+            <TelegramLogin />
             <Modal
               opened={!!confirmProps}
               onClose={onConfirmDialogClose}
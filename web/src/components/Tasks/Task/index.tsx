@@ -210,6 +210,11 @@ export const Task: FC<TaskProps> = ({
               <Badge variant="light">
                 {(task.size / 1024 / 1024).toFixed(0)} MB
               </Badge>
+              {task.account && task.account !== "default" && (
+                <Badge variant="light" color="cyan">
+                  {task.account}
+                </Badge>
+              )}
               <Text size="sm">
                 {dayjs.unix(task.date).format("YYYY/MM/DD HH:mm")}
               </Text>
@@ -0,0 +1,160 @@
+import { type FC, useState } from "react";
+import toast from "react-hot-toast";
+
+import {
+  Button,
+  Group,
+  Image,
+  Loader,
+  Modal,
+  PasswordInput,
+  Stack,
+  Text,
+  TextInput,
+} from "@mantine/core";
+
+import useTelegramAuthStore from "@store/telegram-auth.ts";
+
+import api from "@network/api";
+
+const stateText: Partial<Record<TelegramAuth.State["state"], string>> = {
+  connecting: "Connecting to Telegram...",
+  wait_qr_confirmation:
+    "Scan the QR code in Settings > Devices > Link Desktop Device of Telegram.",
+  unsupported:
+    "This authorization method is not supported, log in with command `onest login` instead.",
+  logging_out:
+    "The session is closed by Telegram, restart the server to log in again.",
+  closed:
+    "The session is closed by Telegram, restart the server to log in again.",
+};
+
+export const TelegramLogin: FC = () => {
+  const open = useTelegramAuthStore((state) => state.open);
+  const authState = useTelegramAuthStore((state) => state.state);
+  const account = useTelegramAuthStore((state) => state.account);
+
+  const [value, setValue] = useState("");
+  const [isLoading, setIsLoading] = useState(false);
+
+  const onClose = () => useTelegramAuthStore.setState({ open: false });
+
+  const onSubmit = async (type: TelegramAuth.Input) => {
+    if (isLoading) return;
+    setIsLoading(true);
+    try {
+      const {
+        data: { data: state },
+      } = await api.post<{ data: TelegramAuth.State }>("telegram/auth/", {
+        account,
+        type,
+        value: type === "qr" ? "" : value,
+      });
+      useTelegramAuthStore.setState({ state });
+      setValue("");
+      if (state.state === "ready") {
+        toast.success("logged in to Telegram");
+        onClose();
+      }
+    } catch (err: unknown) {
+      toast.error(`submit failed: ${err}`);
+    }
+    setIsLoading(false);
+  };
+
+  // the first input of state is typed in, others are buttons
+  const input = authState?.inputs?.[0];
+  const renderInput = () => {
+    switch (input) {
+      case "phone_number":
+        return (
+          <TextInput
+            label="Phone number"
+            description="In international format, e.g. +12025550100"
+            autoComplete="tel"
+            value={value}
+            onChange={(ev) => setValue(ev.target.value)}
+          />
+        );
+      case "code":
+        return (
+          <TextInput
+            label="Code"
+            description={`Sent to ${authState?.code_phone_number} by ${authState?.code_type?.replace(/_/g, " ")}`}
+            autoComplete="one-time-code"
+            value={value}
+            onChange={(ev) => setValue(ev.target.value)}
+          />
+        );
+      case "password":
+        return (
+          <PasswordInput
+            label="Password"
+            description={
+              authState?.password_hint
+                ? `Hint: ${authState.password_hint}`
+                : undefined
+            }
+            autoComplete="current-password"
+            value={value}
+            onChange={(ev) => setValue(ev.target.value)}
+          />
+        );
+    }
+  };
+
+  return (
+    <Modal
+      opened={open}
+      onClose={onClose}
+      title={
+        account === "default"
+          ? "Log in to Telegram"
+          : `Log in to Telegram account ${account}`
+      }
+      centered
+    >
+      <form
+        onSubmit={(ev) => {
+          ev.preventDefault();
+          if (input) onSubmit(input);
+        }}
+      >
+        <Stack>
+          {!authState && <Loader mx="auto" />}
+          {authState && stateText[authState.state] && (
+            <Text>{stateText[authState.state]}</Text>
+          )}
+          {authState?.qr_code && (
+            <Image src={authState.qr_code} alt="qr code" w={256} mx="auto" />
+          )}
+          {renderInput()}
+          {authState?.error && (
+            <Text c="red" size="sm">
+              {authState.error}
+            </Text>
+          )}
+          {!!authState?.inputs?.length && (
+            <Group justify="flex-end">
+              {input !== "qr" && authState.inputs.includes("qr") && (
+                <Button
+                  variant="outline"
+                  disabled={isLoading}
+                  onClick={() => onSubmit("qr")}
+                >
+                  Use QR code
+                </Button>
+              )}
+              {input !== "qr" && (
+                <Button type="submit" loading={isLoading}>
+                  Next
+                </Button>
+              )}
+            </Group>
+          )}
+        </Stack>
+      </form>
+    </Modal>
+  );
+};
+export default TelegramLogin;
@@ -1,7 +1,12 @@
 import axios, { AxiosError } from "axios";
 
+import useAuthStore from "@store/auth.ts";
+
 export const baseUrl = "/api/";
 
+// code of response.ErrUnauthorized
+const codeUnauthorized = 9;
+
 const api = axios.create({
   baseURL: baseUrl,
 });
@@ -10,6 +15,11 @@ api.interceptors.response.use(undefined, (err: AxiosError<any>) => {
   if (err.name === "CanceledError") return new Promise(() => {});
   if (err && err.response && err.response.data && err.response.data.msg)
     err.toString = () => err.response!.data.msg;
+  if (
+    err.response?.data?.code === codeUnauthorized &&
+    !err.config?.url?.startsWith("auth/")
+  )
+    useAuthStore.setState({ authenticated: false });
   return Promise.reject(err);
 });
 
@@ -2,22 +2,78 @@ import { type FC } from "react";
 
 import Empty from "@component/Empty";
 import Tasks from "@component/Tasks";
-import { Flex, Loader } from "@mantine/core";
+import { Button, Flex, Loader } from "@mantine/core";
 
-import useSWR from "swr";
+import useSWRInfinite from "swr/infinite";
 import api from "@network/api.ts";
+import useWebsocket from "@hook/useWebsocket.ts";
+
+type TasksPage = Download.Page<Download.Task>;
+
+// pages are chained by next_cursor, null stops loading
+const getPageKey = (index: number, previous: TasksPage | null) => {
+  if (index === 0) return "download/tasks";
+  if (!previous?.next_cursor) return null;
+  return `download/tasks?cursor=${encodeURIComponent(previous.next_cursor)}`;
+};
+
+// updateTask applies fn to the task at index of all loaded pages
+const updateTask = (
+  pages: TasksPage[],
+  index: number,
+  fn: (items: Download.Task[], index: number) => void,
+): TasksPage[] => {
+  for (const page of pages) {
+    if (index < page.items.length) {
+      fn(page.items, index);
+      break;
+    }
+    index -= page.items.length;
+  }
+  return pages.map((page) => ({ ...page, items: [...page.items] }));
+};
 
 export const Downloads: FC = () => {
-  const { data: tasks, mutate } = useSWR<Download.Task[]>(
-    "download/tasks",
+  const { connected } = useWebsocket("download/watch", {
+    onMessage: (msg) => {
+      const event: Download.Event = JSON.parse(msg.data as string);
+      if (event.type === "progress" && event.file) {
+        const file = event.file;
+        mutate((pages) => {
+          if (!pages) return pages;
+          const index = pages
+            .flatMap((page) => page.items)
+            .findIndex((task) => task.id === event.id);
+          if (index === -1) return pages;
+          return updateTask(pages, index, (items, i) => {
+            items[i] = { ...items[i], file };
+          });
+        }, false);
+      } else {
+        mutate();
+      }
+    },
+  });
+
+  const {
+    data: pages,
+    mutate,
+    size,
+    setSize,
+    isValidating,
+  } = useSWRInfinite<TasksPage>(
+    getPageKey,
     (url: string) => api.get(url).then((res) => res.data.data),
     {
       revalidateOnFocus: true,
-      refreshInterval: 3000,
+      // events are pushed by websocket once connected
+      refreshInterval: connected ? 30000 : 3000,
       refreshWhenHidden: false,
       refreshWhenOffline: true,
     },
   );
+  const tasks = pages?.flatMap((page) => page.items);
+  const hasMore = !!pages?.[pages.length - 1]?.next_cursor;
 
   return (
     <>
@@ -26,16 +82,19 @@ export const Downloads: FC = () => {
           tasks={tasks}
           onTasksMutate={() => mutate()}
           onTaskDeleted={(index) =>
-            mutate((data) => {
-              if (!data) return data;
-              return [...data.splice(index, 1)];
+            mutate((pages) => {
+              if (!pages) return pages;
+              return updateTask(pages, index, (items, i) => {
+                items.splice(i, 1);
+              });
             })
           }
           onSetPriority={(index, priority) =>
-            mutate((data) => {
-              if (!data) return data;
-              data[index].priority = priority;
-              return [...data];
+            mutate((pages) => {
+              if (!pages) return pages;
+              return updateTask(pages, index, (items, i) => {
+                items[i].priority = priority;
+              });
             })
           }
           style={{
@@ -44,6 +103,18 @@ export const Downloads: FC = () => {
         />
       )}
 
+      {hasMore && (
+        <Flex justify="center" my="md">
+          <Button
+            variant="light"
+            loading={isValidating && size > (pages?.length ?? 0)}
+            onClick={() => setSize(size + 1)}
+          >
+            Load more ({tasks?.length} of {pages?.[0]?.total})
+          </Button>
+        </Flex>
+      )}
+
       {(!tasks || tasks.length === 0) && (
         <Flex flex={1} align="center" justify="center">
           {!tasks && <Loader />}
@@ -11,6 +11,7 @@ import {
   NumberInput,
   Group,
   Text,
+  Checkbox,
 } from "@mantine/core";
 
 import useEditItemStore from "@store/edit.ts";
@@ -132,6 +133,23 @@ export const EditItemModal: FC<EditItemModalProps> = ({ onItemMutate }) => {
             value={item?.pattern}
             onChange={(ev) => onUpdateItem("pattern", ev.target.value)}
           />
+          <Group align="end">
+            <TextInput
+              flex={1}
+              label="Episode Pattern"
+              placeholder="Pattern for episode number in nfo. e.g. ${1}"
+              value={item?.episode_pattern}
+              onChange={(ev) =>
+                onUpdateItem("episode_pattern", ev.target.value)
+              }
+            />
+            <Checkbox
+              mb={8}
+              label="Write NFO"
+              checked={!!item?.nfo}
+              onChange={(ev) => onUpdateItem("nfo", ev.currentTarget.checked)}
+            />
+          </Group>
         </Stack>
 
         <Flex justify="end" gap="md" mt={30}>
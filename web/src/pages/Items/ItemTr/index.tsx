@@ -55,8 +55,10 @@ export const ItemTr = memo<ItemTrProps>(
     );
 
     const { data: tasks, mutate } = useSWR<Download.Task[]>(
-      isItemCollapsed ? `item/${item.id}/downloads` : null,
-      (url: string) => api.get(url).then((res) => res.data.data),
+      isItemCollapsed
+        ? `item/${item.id}/downloads?sort=date&desc=true&limit=1000`
+        : null,
+      (url: string) => api.get(url).then((res) => res.data.data.items),
       {
         refreshInterval: 3000,
       },
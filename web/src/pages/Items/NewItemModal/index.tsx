@@ -300,6 +300,7 @@ export const NewItemModal: FC<NewItemModalProps> = ({ onItemMutate }) => {
         match_pattern: matchPattern,
         match_content: matchContent,
         priority,
+        realsearch_id: itemInfo?.id,
         downloads: itemRawsMatched
           ?.filter((raw) => raw.matched && raw.selected)
           .map((raw) => ({
@@ -52,8 +52,15 @@ export const Items: FC = () => {
     isLoading,
     isValidating,
   } = useSWR<Item.Local[]>(
-    `item/${viewMode === "error" ? "error" : `active?active_after=${activeAfterDebounced}`}`,
-    (url: string) => api.get(url).then((res) => res.data.data),
+    viewMode === "all"
+      ? "item/?limit=1000"
+      : `item/${viewMode === "error" ? "error" : `active?active_after=${activeAfterDebounced}`}`,
+    (url: string) =>
+      api
+        .get(url)
+        .then((res) =>
+          Array.isArray(res.data.data) ? res.data.data : res.data.data.items,
+        ),
     {
       revalidateOnFocus: true,
       refreshWhenHidden: false,
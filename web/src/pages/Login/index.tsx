@@ -0,0 +1,70 @@
+import { type FC, useState } from "react";
+import toast from "react-hot-toast";
+
+import {
+  Button,
+  Flex,
+  Paper,
+  PasswordInput,
+  Stack,
+  TextInput,
+  Title,
+} from "@mantine/core";
+
+import useAuthStore from "@store/auth.ts";
+
+import api from "@network/api";
+
+export const Login: FC = () => {
+  const [username, setUsername] = useState("");
+  const [password, setPassword] = useState("");
+
+  const [isLoading, setIsLoading] = useState(false);
+  const onSubmit = async () => {
+    if (isLoading) return;
+    setIsLoading(true);
+    try {
+      await api.post("auth/login", { username, password });
+      const {
+        data: { data: status },
+      } = await api.get<{ data: Auth.Status }>("auth/status");
+      useAuthStore.setState(status);
+    } catch (err: unknown) {
+      toast.error(`login failed: ${err}`);
+    }
+    setIsLoading(false);
+  };
+
+  return (
+    <Flex flex={1} align="center" justify="center">
+      <Paper withBorder p="xl" w="22rem">
+        <form
+          onSubmit={(ev) => {
+            ev.preventDefault();
+            onSubmit();
+          }}
+        >
+          <Stack>
+            <Title order={3}>Login</Title>
+            <TextInput
+              label="Username"
+              autoComplete="username"
+              value={username}
+              onChange={(ev) => setUsername(ev.target.value)}
+            />
+            <PasswordInput
+              label="Password"
+              autoComplete="current-password"
+              value={password}
+              onChange={(ev) => setPassword(ev.target.value)}
+            />
+            <Button type="submit" loading={isLoading}>
+              Login
+            </Button>
+          </Stack>
+        </form>
+      </Paper>
+    </Flex>
+  );
+};
+export default Login;
@@ -0,0 +1,11 @@
+import { create } from "zustand/react";
+
+interface AuthState {
+  // undefined before status is loaded
+  enabled?: boolean;
+  authenticated?: boolean;
+  principal?: Auth.Principal;
+}
+
+export const useAuthStore = create<AuthState>()(() => ({}));
+export default useAuthStore;
@@ -0,0 +1,16 @@
+import { create } from "zustand/react";
+
+interface TelegramAuthState {
+  // undefined before loaded
+  accounts?: TelegramAuth.Account[];
+  // state of the account logging in
+  state?: TelegramAuth.State;
+  account: string;
+  open: boolean;
+}
+
+export const useTelegramAuthStore = create<TelegramAuthState>()(() => ({
+  account: "default",
+  open: false,
+}));
+export default useTelegramAuthStore;
@@ -0,0 +1,14 @@
+namespace Auth {
+  type Principal = {
+    type: "session" | "token";
+    id: number;
+    name: string;
+    scopes: string[];
+  };
+
+  type Status = {
+    enabled: boolean;
+    authenticated: boolean;
+    principal?: Principal;
+  };
+}
@@ -6,12 +6,38 @@ namespace Download {
     text: string;
     size: number;
     date: number;
+    path: string;
     priority: number;
     downloading: boolean;
+    paused: boolean;
     downloaded: boolean;
     fatal_error: boolean;
     error: string;
     error_at: number;
+    // telegram account served the download last time
+    account: string;
     file?: Telegram.File;
   };
+
+  type Event = {
+    type:
+      | "queued"
+      | "started"
+      | "progress"
+      | "completed"
+      | "error"
+      | "fatal"
+      | "removed";
+    id: number;
+    at: number;
+    file?: Telegram.File;
+    path?: string;
+    error?: string;
+  };
+
+  type Page<T> = {
+    total: number;
+    items: T[];
+    next_cursor?: string;
+  };
 }
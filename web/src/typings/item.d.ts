@@ -11,6 +11,44 @@ namespace Item {
     process: number;
     priority: number;
     target_path: string;
+    realsearch_id: number;
+    nfo: boolean;
+    episode_pattern: string;
+    match_rules: MatchRules | null;
+    companion_rules: CompanionRule[] | null;
+    // telegram account scanning the channel
+    account: string;
+  };
+
+  type MatchCondition = {
+    type:
+      | "caption"
+      | "file_name"
+      | "hashtag"
+      | "size"
+      | "duration"
+      | "resolution";
+    regexp?: string;
+    min?: number;
+    max?: number;
+  };
+
+  type MatchRules = {
+    mode: "and" | "or";
+    include: MatchCondition[] | null;
+    exclude: MatchCondition[] | null;
+  };
+
+  type CompanionRule = {
+    regexp: string;
+    msg_window: number;
+    time_window: number;
+  };
+
+  type WithStats = Local & {
+    done: number;
+    pending: number;
+    failed: number;
   };
 
   type Remote = {
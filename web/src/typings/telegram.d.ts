@@ -87,3 +87,36 @@ namespace Telegram {
     content: MessageVideo | (Meta & unknown);
   };
 }
+
+namespace TelegramAuth {
+  type Input = "phone_number" | "code" | "password" | "qr";
+
+  type State = {
+    state:
+      | "connecting"
+      | "wait_phone_number"
+      | "wait_code"
+      | "wait_password"
+      | "wait_qr_confirmation"
+      | "unsupported"
+      | "ready"
+      | "logging_out"
+      | "closed";
+    inputs: Input[];
+    qr_link?: string;
+    // png data url of qr_link
+    qr_code?: string;
+    code_phone_number?: string;
+    code_type?: string;
+    password_hint?: string;
+    error?: string;
+  };
+
+  type Account = {
+    name: string;
+    state: State["state"];
+    ready: boolean;
+    running: number;
+    max_parallel_download: number;
+  };
+}